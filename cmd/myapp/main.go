@@ -2,40 +2,115 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"os"
 	"time"
 
 	"iDevopzAgent/configs"
+	"iDevopzAgent/httpclient"
+	"iDevopzAgent/internal/alerting"
+	"iDevopzAgent/internal/authlog"
+	"iDevopzAgent/internal/fim"
 	"iDevopzAgent/internal/healthreport"
+	"iDevopzAgent/internal/localapi"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/internal/logtail"
 	"iDevopzAgent/internal/metrics"
+	"iDevopzAgent/internal/notify"
 	"iDevopzAgent/internal/processdetails"
+	"iDevopzAgent/internal/remote"
+	"iDevopzAgent/internal/sockets"
 	"iDevopzAgent/internal/systeminfo"
+	"iDevopzAgent/internal/telemetry"
+	"iDevopzAgent/internal/update"
 	"iDevopzAgent/internal/utilization"
 	"iDevopzAgent/internal/utils"
 	"iDevopzAgent/security"
 	"iDevopzAgent/sender"
 )
 
+var log = logging.For("agent")
+
+// Collector schedules, registered before main so a stored remote config
+// can be applied as soon as the poller starts.
+var (
+	metricsJob        = remote.RegisterJob("metrics", 10*time.Second)
+	utilizationJob    = remote.RegisterJob("utilization", 10*time.Second)
+	healthReportJob   = remote.RegisterJob("health_report", 10*time.Second)
+	processDetailsJob = remote.RegisterJob("process_details", 1*time.Minute)
+	systemInfoJob     = remote.RegisterJob("system_info", 1*time.Minute)
+	inventoryJob      = remote.RegisterJob("inventory", 15*time.Minute)
+	packagesJob       = remote.RegisterJob("packages", 1*time.Hour)
+	socketsJob        = remote.RegisterJob("sockets", 1*time.Minute)
+	logsJob           = remote.RegisterJob("logs", 1*time.Minute)
+	logForwardJob     = remote.RegisterJob("log_forward", 5*time.Second)
+	authJob           = remote.RegisterJob("auth", 1*time.Minute)
+	fileIntegrityJob  = remote.RegisterJob("file_integrity", 30*time.Second)
+)
+
 func main() {
+	deviceKey := flag.String("device-key", "", "device key to register this machine with")
+	rotateKey := flag.Bool("rotate-key", false, "generate a new config encryption key and exit")
+	showVersion := flag.Bool("version", false, "print the agent version and exit")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println(configs.Version)
+		return
+	}
+
+	if err := setupLogging(); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid logging configuration, logging to stdout:", err)
+	}
+
+	// roll back a freshly installed version that keeps failing
+	update.Startup()
+
+	if *rotateKey {
+		err := configs.RotateKey()
+		if errors.Is(err, configs.ErrDerivedKey) {
+			err = rotateServerSecret()
+		}
+		if err != nil {
+			fmt.Println("Key rotation failed:", err)
+			os.Exit(1)
+		}
+		fmt.Println("✔ Encryption key rotated")
+		return
+	}
+
+	if err := setupTransport(); err != nil {
+		log.Error("invalid transport configuration", "err", err)
+		os.Exit(1)
+	}
+
 	userID, machineID, err := configs.LoadUserID()
 	if err != nil || userID == "" {
-		encUserID, encMachineID := configs.PromptAndSaveUserID()
+		var encUserID, encMachineID string
+		if *deviceKey != "" {
+			encUserID, encMachineID = configs.SaveUserID(*deviceKey)
+		} else {
+			encUserID, encMachineID = configs.PromptAndSaveUserID()
+		}
 
 		// Decrypt immediately for runtime use
-		decUserID, _ := security.Decrypt(encUserID)
-		decMachineID, _ := security.Decrypt(encMachineID)
+		decUserID, _ := security.Decrypt(configs.KeyProvider(), encUserID)
+		decMachineID, _ := security.Decrypt(configs.KeyProvider(), encMachineID)
 
 		userID = decUserID
 		machineID = decMachineID
 	}
 
+	if err := configs.SealSettingsSecrets(); err != nil {
+		log.Warn("failed to encrypt notifier passwords in settings", "err", err)
+	}
+
 	hostname, _ := utils.GetHostName()
-	os := utils.GetOS()
+	osName := utils.GetOS()
 
-	fmt.Println("UserID:", userID)
-	fmt.Println("MachineID:", machineID)
-	fmt.Println("Hostname:", hostname)
-	fmt.Println("OS:", os)
+	log.Info("agent starting", "version", configs.Version, "machine_id", machineID, "hostname", hostname, "os", osName)
 
 	// ----------------------------
 	// Send startup API once
@@ -44,7 +119,7 @@ func main() {
 		"monitorId": userID,
 		"hostname":  hostname,
 		"machineId": machineID,
-		"os":        os,
+		"os":        osName,
 	}
 
 	// Call startup API (errors are handled inside the function)
@@ -55,126 +130,405 @@ func main() {
 	// go collectUtilization(userID, machineID)
 	go collectHealthReport(userID, machineID)
 	go collectProcessDetails(userID, machineID)
+	go collectInventory(userID, machineID)
+	go collectPackages(userID, machineID, hostname)
+	go collectSockets(userID, machineID, hostname)
+	go logtail.GetTailer().Run()
+	go logtail.GetJournal().Run()
+	go collectLogs(userID, machineID, hostname)
+	go forwardLogs(userID, machineID, hostname)
+	go collectAuth(userID, machineID, hostname)
+	go collectFileIntegrity(userID, machineID, hostname)
 	go collectSystemInfo(userID, machineID)
 
+	remote.GetPoller().Handle("rotate_logs", func(map[string]string) error {
+		return logging.Rotate()
+	})
+	remote.GetPoller().Handle("check_update", func(map[string]string) error {
+		go func() {
+			if err := update.GetUpdater().CheckAndInstall(); err != nil {
+				log.Error("self-update failed", "err", err)
+			}
+		}()
+		return nil
+	})
+	go remote.GetPoller().Run(machineID)
+	go update.GetUpdater().Run(machineID)
+
+	go telemetry.RunHeartbeat(userID, machineID, hostname)
+	if t := configs.LoadSettings().Telemetry; t.Listen != "" {
+		if err := telemetry.Serve(t.Listen, t.Token); err != nil {
+			log.Error("telemetry listener failed", "addr", t.Listen, "err", err)
+		}
+	}
+	if api := configs.LoadSettings().LocalAPI; api.Listen != "" {
+		if err := localapi.Serve(api.Listen, api.Token); err != nil {
+			log.Error("local api failed", "addr", api.Listen, "err", err)
+		}
+	}
+
 	// Prevent the main function from exiting
 	select {}
 }
 
+// setupLogging installs the logger configured in settings.json, writing to
+// logs.txt in the data directory.
+func setupLogging() error {
+	l := configs.LoadSettings().Logging
+	return logging.Setup(logging.Options{
+		Dir:        configs.DataDir(),
+		Format:     l.Format,
+		Level:      l.Level,
+		Levels:     l.Levels,
+		MaxSizeMB:  l.MaxSizeMB,
+		MaxBackups: l.MaxBackups,
+		Stdout:     l.Stdout,
+	})
+}
+
+// setupTransport applies the TLS settings to backend connections and the
+// proxy settings to every outbound request.
+func setupTransport() error {
+	t := configs.LoadSettings().TLS
+	tlsConfig, err := httpclient.NewTLSConfig(httpclient.TLSOptions{
+		CAFile:     t.CAFile,
+		CertFile:   t.CertFile,
+		KeyFile:    t.KeyFile,
+		MinVersion: t.MinVersion,
+		ServerName: t.ServerName,
+		Pins:       t.Pins,
+	})
+	if err != nil {
+		return err
+	}
+	httpclient.SetBackendTLS(configs.LoadConfig().APIEndpoint, tlsConfig)
+
+	p := configs.LoadSettings().Proxy
+	return httpclient.SetProxy(httpclient.ProxyOptions{
+		URL:      p.URL,
+		Username: p.Username,
+		Password: p.Password,
+		NoProxy:  p.NoProxy,
+	})
+}
+
+// rotateServerSecret rotates a key derived from the server secret by asking
+// the backend for a new secret.
+func rotateServerSecret() error {
+	if err := setupTransport(); err != nil {
+		return err
+	}
+	_, machineID, err := configs.LoadUserID()
+	if err != nil {
+		return err
+	}
+	return sender.RotateServerSecret(machineID)
+}
+
 func collectMetrics(userID string, machineId string) {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
 	collector := metrics.GetCollector()
 
 	for {
-		select {
-		case <-ticker.C:
-			y, err := collector.MetricsCollect(userID, machineId)
-			sender.SendToMetricsAPI(y)
-			fmt.Println("collected metrics", y, err)
+		metricsJob.Wait()
+
+		start := time.Now()
+		y, err := collector.MetricsCollect(userID, machineId)
+		telemetry.ObserveCollection("metrics", time.Since(start), err)
+		if err != nil {
+			log.Error("collect metrics failed", "err", err)
+			continue
+		}
+		sender.SendToMetricsAPI(y)
+		localapi.Record("metrics", y)
+		log.Debug("collected metrics", "cpu_percent", y.CPUPercent, "memory_percent", y.MemoryPercent, "disk_used_percent", y.DiskUsedPercent, "status", y.Status)
+
+		if events := alerting.GetEngine().Evaluate(y); len(events) > 0 {
+			log.Info("alert state changed", "events", len(events))
+			sender.SendAlertEvents(events)
+			notify.GetDispatcher().Notify(events)
 		}
 	}
 }
 
 func collectUtilization(userID string, machineId string) {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
 	u := utilization.UtilizationCollector()
 
 	for {
-		select {
-		case <-ticker.C:
-			if cpuUtil, err := u.CpuUtilization(userID, machineId); err == nil {
-				fmt.Println("cpu utilization", cpuUtil)
-				sender.SendCpuUtilizationToAPI(cpuUtil)
+		utilizationJob.Wait()
 
-			}
-			if memUtil, err := u.MemoryUtilization(userID, machineId); err == nil {
-				fmt.Println("memory utilization", memUtil)
-				sender.SendMemmoryUtilizationToAPI(memUtil)
+		start := time.Now()
+		if cpuUtil, err := u.CpuUtilization(userID, machineId); err == nil {
+			sender.SendCpuUtilizationToAPI(cpuUtil)
 
-			}
-			if diskUtil, err := u.DiskUtilization(userID, machineId); err == nil {
-				fmt.Println("disk utilization", diskUtil)
-				sender.SendDiskUtilizationToAPI(diskUtil)
+		}
+		if memUtil, err := u.MemoryUtilization(userID, machineId); err == nil {
+			sender.SendMemmoryUtilizationToAPI(memUtil)
 
-			}
 		}
+		if diskUtil, err := u.DiskUtilization(userID, machineId); err == nil {
+			sender.SendDiskUtilizationToAPI(diskUtil)
+
+		}
+		telemetry.ObserveCollection("utilization", time.Since(start), nil)
 	}
 }
 
 func collectHealthReport(userID string, machineId string) {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
 	h := healthreport.GetHealthReportCollector()
 
 	for {
-		select {
-		case <-ticker.C:
-			health, err := h.GenerateHealthReport(userID, machineId)
-			if err != nil {
-				fmt.Println("Error collecting healthReport:", err)
-			} else {
-				fmt.Println("healthReport", health)
-				sender.SendToHealthReportAPI(health)
+		healthReportJob.Wait()
+
+		start := time.Now()
+		health, err := h.GenerateHealthReport(userID, machineId)
+		telemetry.ObserveCollection("health_report", time.Since(start), err)
+		if err != nil {
+			log.Error("collect health report failed", "err", err)
+		} else {
+			sender.SendToHealthReportAPI(health)
+			localapi.Record("health_report", health)
+			notify.GetDispatcher().NotifyHealth(health)
 
-			}
 		}
 	}
 }
 
 func collectProcessDetails(userID string, machineId string) {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
 	processUtil := processdetails.GetProcessCollector()
 
 	for {
-		select {
-		case <-ticker.C:
-			if p, err := processUtil.ListAllProcesses(userID, machineId); err == nil {
-				fmt.Printf("Collected %d processes, sending to API\n", len(p))
-				sender.SendProcessList(p)
-			} else {
-				fmt.Println("Error collecting process list:", err)
-			}
+		processDetailsJob.Wait()
 
-			if top5Cpu, err := processUtil.ListTop5CpuProcess(userID, machineId); err == nil {
-				fmt.Printf("Collected top 5 CPU processes, sending to API\n")
-				sender.Top5Cpu(top5Cpu)
-			} else {
-				fmt.Println("Error collecting top 5 CPU processes:", err)
-			}
+		start := time.Now()
+		p, err := processUtil.ListAllProcesses(userID, machineId)
+		telemetry.ObserveCollection("process_details", time.Since(start), err)
+		if err == nil {
+			log.Debug("collected processes", "count", len(p))
+			sender.SendProcessList(p)
+			localapi.Record("processes", p)
+		} else {
+			log.Error("collect process list failed", "err", err)
+		}
 
-			if top5Mem, err := processUtil.ListTop5MemoryProcess(userID, machineId); err == nil {
-				fmt.Printf("Collected top 5 memory processes, sending to API\n")
-				sender.Top5Memory(top5Mem)
-			} else {
-				fmt.Println("Error collecting top 5 memory processes:", err)
+		if groups, err := processUtil.ListProcessGroups(userID, machineId); err == nil {
+			log.Debug("collected process groups", "count", len(groups))
+			sender.SendProcessGroups(groups)
+			localapi.Record("process_groups", groups)
+		} else {
+			log.Error("collect process groups failed", "err", err)
+		}
+
+		if top5Cpu, err := processUtil.ListTop5CpuProcess(userID, machineId); err == nil {
+			sender.Top5Cpu(top5Cpu)
+			localapi.Record("top_cpu", top5Cpu)
+		} else {
+			log.Error("collect top 5 CPU processes failed", "err", err)
+		}
+
+		if top5Mem, err := processUtil.ListTop5MemoryProcess(userID, machineId); err == nil {
+			sender.Top5Memory(top5Mem)
+			localapi.Record("top_memory", top5Mem)
+		} else {
+			log.Error("collect top 5 memory processes failed", "err", err)
+		}
+
+		if count, err := utils.GetProcessCount(); err == nil {
+			log.Debug("process count", "count", count)
+		}
+	}
+}
+
+// collectInventory sends the static inventory at startup and whenever it
+// changed, together with the fields that changed. The changes are sent and
+// the baseline advances only once the backend accepted the inventory.
+func collectInventory(userID string, machineId string) {
+	systemInfoCollector := systeminfo.GetSystemInfoCollector()
+
+	for first := true; ; first = false {
+		if !first {
+			inventoryJob.Wait()
+		}
+
+		start := time.Now()
+		inv, changes, send, err := systeminfo.CheckInventory(systemInfoCollector, userID, machineId)
+		telemetry.ObserveCollection("inventory", time.Since(start), err)
+		if err != nil {
+			log.Error("collect inventory failed", "err", err)
+			continue
+		}
+		// the changes are diffed against the acked baseline, so they go out
+		// only with an accepted inventory; a failed upload recomputes them
+		// next time instead of sending them twice
+		if send && sender.SendInventory(inv) {
+			if len(changes) > 0 {
+				log.Info("inventory changed", "fields", len(changes), "hash", inv.Hash)
+				sender.SendInventoryChanges(changes)
 			}
+			systeminfo.AckInventory(inv)
+		}
+		localapi.Record("inventory", inv)
+	}
+}
+
+// collectPackages sends the installed packages at startup and then every
+// packages interval; only changes are uploaded after the first list.
+func collectPackages(userID, machineId, hostname string) {
+	for first := true; ; first = false {
+		if !first {
+			packagesJob.Wait()
+		}
+
+		start := time.Now()
+		pkgs, err := systeminfo.ListPackages()
+		telemetry.ObserveCollection("packages", time.Since(start), err)
+		if err != nil {
+			log.Error("collect packages failed", "err", err)
+			continue
+		}
+		sender.SendPackageList(userID, machineId, hostname, pkgs)
+		localapi.Record("packages", pkgs)
+	}
+}
+
+func collectSockets(userID, machineId, hostname string) {
+	for {
+		socketsJob.Wait()
+
+		start := time.Now()
+		inv, events, err := sockets.Collect(userID, machineId, hostname)
+		telemetry.ObserveCollection("sockets", time.Since(start), err)
+		if err != nil {
+			log.Error("collect sockets failed", "err", err)
+			continue
+		}
+		for _, e := range events {
+			log.Info("listener "+e.Event, "protocol", e.Socket.Protocol, "address", e.Socket.Address,
+				"port", e.Socket.Port, "process", e.Socket.Process)
+		}
+		if len(events) > 0 {
+			sender.SendListenerEvents(events)
+		}
+		sender.SendSocketInventory(inv)
+		localapi.Record("sockets", inv)
+	}
+}
+
+// collectLogs reports what the log tailer and the journal matched since the
+// previous report, and forwards the buffered journal entries. Intervals
+// without any new lines are not sent.
+func collectLogs(userID, machineId, hostname string) {
+	if !configs.LoadSettings().LogTail.Enabled {
+		return
+	}
+	for {
+		logsJob.Wait()
 
-			if count, err := utils.GetProcessCount(); err == nil {
-				fmt.Println("Process count:", count)
+		if entries := logtail.GetJournal().Forwarded(userID, machineId, hostname); entries != nil {
+			sender.SendJournalEntries(entries)
+		}
+
+		report := logtail.GetTailer().Report(userID, machineId, hostname)
+		if len(report.Lines) == 0 {
+			continue
+		}
+		sender.SendLogReport(report)
+		localapi.Record("logs", report)
+	}
+}
+
+// forwardLogs uploads the records queued by the log forwarder, a batch at
+// a time until the queue is empty or an upload fails. Records stay queued
+// until the backend accepts them.
+func forwardLogs(userID, machineId, hostname string) {
+	fwd := logtail.GetForwarder()
+	if !configs.LoadSettings().LogTail.Enabled || !fwd.Enabled() {
+		return
+	}
+	for {
+		logForwardJob.Wait()
+
+		for {
+			records := fwd.Pending(userID, machineId, hostname)
+			if records == nil || !sender.SendLogRecords(records) {
+				break
 			}
+			fwd.Ack(len(records.Records))
 		}
 	}
 }
 
+// collectAuth reports logins, failed attempts and sudo usage, and raises
+// the brute-force alerts. Intervals without activity send no report.
+func collectAuth(userID, machineId, hostname string) {
+	monitor := authlog.GetMonitor()
+	if !monitor.Enabled() {
+		return
+	}
+	monitor.Start()
+	for {
+		authJob.Wait()
+
+		start := time.Now()
+		report, alerts := monitor.Report(userID, machineId, hostname)
+		telemetry.ObserveCollection("auth", time.Since(start), nil)
+		if len(alerts) > 0 {
+			log.Info("brute-force alert state changed", "events", len(alerts))
+			sender.SendAlertEvents(alerts)
+			notify.GetDispatcher().Notify(alerts)
+		}
+		if report == nil {
+			continue
+		}
+		sender.SendAuthReport(report)
+		localapi.Record("auth", report)
+	}
+}
+
+// collectFileIntegrity sends the changes to the monitored files found by
+// the watcher or the periodic rescans.
+func collectFileIntegrity(userID, machineId, hostname string) {
+	monitor := fim.GetMonitor()
+	if !monitor.Enabled() {
+		return
+	}
+	monitor.Start()
+	for {
+		fileIntegrityJob.Wait()
+
+		start := time.Now()
+		events := monitor.Check(userID, machineId, hostname)
+		telemetry.ObserveCollection("file_integrity", time.Since(start), nil)
+		if len(events) == 0 {
+			continue
+		}
+		sender.SendFileChanges(events)
+		localapi.Record("file_changes", events)
+	}
+}
+
+// collectSystemInfo sends the volatile counters, combined with the last
+// inventory into the system summary.
 func collectSystemInfo(userID string, machineId string) {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
 	systemInfoCollector := systeminfo.GetSystemInfoCollector()
 
 	for {
-		select {
-		case <-ticker.C:
-			sys, err := systemInfoCollector.GetSystemSummary(userID, machineId)
-			if err != nil {
-				fmt.Println("error collecting system info:", err)
-			} else {
-				fmt.Println("systemInfo", sys)
-				sender.SendSystemSummaryToAPI(sys)
+		systemInfoJob.Wait()
 
-			}
+		start := time.Now()
+		counters, err := systemInfoCollector.GetCounters(userID, machineId)
+		telemetry.ObserveCollection("system_info", time.Since(start), err)
+		if err != nil {
+			log.Error("collect system info failed", "err", err)
+			continue
+		}
+
+		inv := systeminfo.LatestInventory()
+		if inv == nil {
+			log.Debug("no inventory yet, skipping system summary")
+			continue
 		}
+		sys := systeminfo.Summary(inv, counters)
+		sender.SendSystemSummaryToAPI(sys)
+		localapi.Record("system_info", sys)
 	}
 }
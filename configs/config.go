@@ -5,9 +5,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"iDevopzAgent/internal/logging"
 	"iDevopzAgent/models"
 	"iDevopzAgent/security"
-	"log"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -15,6 +15,8 @@ import (
 	"github.com/denisbrodbeck/machineid"
 )
 
+var log = logging.For("configs")
+
 func getConfigPath() string {
 	var baseDir string
 	switch runtime.GOOS {
@@ -37,15 +39,21 @@ func LoadUserID() (string, string, error) {
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return "", "", err
 	}
-	fmt.Printf("Raw JSON: %s\n", string(data))
-	fmt.Printf("Parsed struct: %+v\n", cfg)
 	// Decrypt both UserID and MachineID before returning
-	decUserID, err := security.Decrypt(cfg.UserID)
+	decUserID, err := security.Decrypt(KeyProvider(), cfg.UserID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to decrypt UserID: %w", err)
+		// written by an older agent with the built-in key, or by an
+		// interrupted key rotation
+		if merr := migrateConfig(&cfg); merr != nil {
+			return "", "", fmt.Errorf("failed to decrypt UserID: %w", err)
+		}
+		decUserID, err = security.Decrypt(KeyProvider(), cfg.UserID)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to decrypt UserID: %w", err)
+		}
 	}
 
-	decMachineID, err := security.Decrypt(cfg.MachineID)
+	decMachineID, err := security.Decrypt(KeyProvider(), cfg.MachineID)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to decrypt MachineID: %w", err)
 	}
@@ -63,11 +71,7 @@ func PromptAndSaveUserID() (string, string) {
 			var cfg models.Config
 			if json.Unmarshal(data, &cfg) == nil && cfg.UserID != "" && cfg.MachineID != "" {
 				//  Decrypt stored values
-				decryptedUserID, _ := security.Decrypt(cfg.UserID)
-				decryptedMachineID, _ := security.Decrypt(cfg.MachineID)
-
-				fmt.Println("✔ Using stored UserID:", decryptedUserID)
-				fmt.Println("✔ Using stored MachineID:", decryptedMachineID)
+				log.Info("using stored device key and machine ID", "path", configPath)
 
 				return cfg.UserID, cfg.MachineID // still return encrypted values
 			}
@@ -80,21 +84,35 @@ func PromptAndSaveUserID() (string, string) {
 	userID, _ := reader.ReadString('\n')
 	userID = string(bytes.TrimSpace([]byte(userID)))
 
+	return SaveUserID(userID)
+}
+
+// SaveUserID registers userID on this machine: it generates the per-install
+// key and stores the encrypted UserID and MachineID in config.json.
+func SaveUserID(userID string) (string, string) {
 	// 3. Get MachineID
 	machineID, err := machineid.ID()
 	if err != nil {
-		log.Fatalf("Failed to get machine ID: %v", err)
+		log.Error("failed to get machine ID", "err", err)
+		os.Exit(1)
 	}
 
-	// 4. Encrypt both
-	encryptedUserID, err := security.Encrypt(userID)
+	// 4. Encrypt both with the per-install key
+	if err := EnsureKey(); err != nil {
+		log.Error("failed to create encryption key", "err", err)
+		os.Exit(1)
+	}
+
+	encryptedUserID, err := security.Encrypt(KeyProvider(), userID)
 	if err != nil {
-		log.Fatalf("Failed to encrypt UserID: %v", err)
+		log.Error("failed to encrypt device key", "err", err)
+		os.Exit(1)
 	}
 
-	encryptedMachineID, err := security.Encrypt(machineID)
+	encryptedMachineID, err := security.Encrypt(KeyProvider(), machineID)
 	if err != nil {
-		log.Fatalf("Failed to encrypt MachineID: %v", err)
+		log.Error("failed to encrypt machine ID", "err", err)
+		os.Exit(1)
 	}
 
 	// 5. Save config
@@ -102,13 +120,11 @@ func PromptAndSaveUserID() (string, string) {
 		UserID:    encryptedUserID,
 		MachineID: encryptedMachineID,
 	}
-	data, _ := json.MarshalIndent(config, "", "  ")
-
-	os.MkdirAll(filepath.Dir(configPath), 0700)
-	_ = os.WriteFile(configPath, data, 0600)
-
-	fmt.Println("✔ User ID stored (encrypted):", encryptedUserID)
-	fmt.Println("✔ Machine ID stored (encrypted):", encryptedMachineID)
+	if err := writeConfigFile(&config); err != nil {
+		log.Error("failed to save config", "err", err)
+	} else {
+		log.Info("stored encrypted device key and machine ID", "path", getConfigPath())
+	}
 
 	return encryptedUserID, encryptedMachineID
 }
@@ -41,7 +41,6 @@
 package configs
 
 import (
-	"fmt"
 	"os"
 )
 
@@ -87,7 +86,7 @@ func LoadConfig() *AppConfig {
 		cfg.Bucket = "test-bucket"
 
 	default:
-		fmt.Printf("⚠ Unknown APP_ENV: %s, defaulting to production config\n", env)
+		log.Warn("unknown APP_ENV, defaulting to production config", "env", env)
 		cfg.APIEndpoint = "https://api.yourdomain.com"
 		cfg.InfluxURL = "https://influxdb.yourdomain.com"
 		cfg.InfluxToken = "prod-token"
@@ -0,0 +1,327 @@
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iDevopzAgent/models"
+	"iDevopzAgent/security"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/denisbrodbeck/machineid"
+)
+
+var (
+	keyProviderMu sync.Mutex
+	keyProvider   security.KeyProvider
+
+	serverSecretMu     sync.Mutex
+	serverSecret       string
+	serverSecretLoaded bool
+)
+
+func keyPath() string {
+	return filepath.Join(DataDir(), "agent.key")
+}
+
+func serverSecretPath() string {
+	return filepath.Join(DataDir(), "server.secret")
+}
+
+// KeyProvider returns the provider for the config encryption key. By default
+// it is the per-install key file; with key_source "derived" the key is
+// derived from the machine ID and the server-issued secret. Until the
+// backend issued that secret, derived mode uses the key file as well.
+func KeyProvider() security.KeyProvider {
+	keyProviderMu.Lock()
+	defer keyProviderMu.Unlock()
+
+	if keyProvider == nil {
+		keyProvider = security.NewFileKeyProvider(keyPath())
+		if LoadSettings().KeySource == "derived" {
+			if secret := readServerSecret(serverSecretPath()); secret != "" {
+				keyProvider = derivedKeyProvider(secret)
+			}
+		}
+	}
+	return keyProvider
+}
+
+// setKeyProvider replaces the cached provider after the key changed.
+func setKeyProvider(p security.KeyProvider) {
+	keyProviderMu.Lock()
+	keyProvider = p
+	keyProviderMu.Unlock()
+}
+
+func readServerSecret(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ServerSecret returns the server-issued secret, or "" before the backend
+// issued one.
+func ServerSecret() string {
+	serverSecretMu.Lock()
+	defer serverSecretMu.Unlock()
+
+	if !serverSecretLoaded {
+		serverSecret = readServerSecret(serverSecretPath())
+		serverSecretLoaded = true
+	}
+	return serverSecret
+}
+
+func setServerSecret(secret string) {
+	serverSecretMu.Lock()
+	serverSecret, serverSecretLoaded = secret, true
+	serverSecretMu.Unlock()
+}
+
+func derivedKeyProvider(secret string) security.KeyProvider {
+	id, err := machineid.ID()
+	if err != nil {
+		log.Warn("could not read machine ID for key derivation", "err", err)
+	}
+	return &security.DerivedKeyProvider{MachineID: id, Secret: []byte(secret)}
+}
+
+// ErrDerivedKey is returned by RotateKey when the key is derived from the
+// server secret, which only the backend can replace.
+var ErrDerivedKey = errors.New("key is derived from the server secret")
+
+// EnsureKey generates the per-install key if it does not exist yet.
+func EnsureKey() error {
+	fkp, ok := KeyProvider().(*security.FileKeyProvider)
+	if !ok {
+		return nil
+	}
+	_, err := fkp.Key()
+	if err == security.ErrNoKey {
+		return fkp.Generate()
+	}
+	return err
+}
+
+// encryptedFields lists the config values stored encrypted.
+func encryptedFields(cfg *models.Config) []*string {
+	return []*string{&cfg.UserID, &cfg.MachineID, &cfg.AgentToken, &cfg.RefreshToken}
+}
+
+func readConfigFile() (*models.Config, error) {
+	data, err := os.ReadFile(getConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	var cfg models.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// writeConfigFile atomically replaces config.json with 0600 permissions.
+func writeConfigFile(cfg *models.Config) error {
+	path := getConfigPath()
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// reencrypt decrypts every encrypted field of cfg with from and encrypts it
+// again with to.
+func reencrypt(cfg *models.Config, from, to security.KeyProvider) error {
+	for _, field := range encryptedFields(cfg) {
+		if *field == "" {
+			continue
+		}
+		plain, err := security.Decrypt(from, *field)
+		if err != nil {
+			return err
+		}
+		enc, err := security.Encrypt(to, plain)
+		if err != nil {
+			return err
+		}
+		*field = enc
+	}
+	return nil
+}
+
+// migrateConfig re-encrypts a config written with the legacy built-in key,
+// or with the key left behind by an interrupted rotation, under the current
+// key.
+func migrateConfig(cfg *models.Config) error {
+	if err := EnsureKey(); err != nil {
+		return err
+	}
+
+	var candidates []security.KeyProvider
+	if _, err := os.Stat(keyPath() + ".old"); err == nil {
+		candidates = append(candidates, security.NewFileKeyProvider(keyPath()+".old"))
+	}
+	if LoadSettings().KeySource == "derived" {
+		if secret := readServerSecret(serverSecretPath() + ".old"); secret != "" {
+			candidates = append(candidates, derivedKeyProvider(secret))
+		}
+		if _, err := os.Stat(keyPath()); err == nil {
+			// the switch from the bootstrap key file was interrupted
+			candidates = append(candidates, security.NewFileKeyProvider(keyPath()))
+		}
+	}
+	candidates = append(candidates, security.LegacyKey)
+
+	for _, old := range candidates {
+		migrated := *cfg
+		if err := reencrypt(&migrated, old, KeyProvider()); err != nil {
+			continue
+		}
+		if err := writeConfigFile(&migrated); err != nil {
+			return err
+		}
+		*cfg = migrated
+		log.Info("config re-encrypted with the current key")
+		return nil
+	}
+	return fmt.Errorf("config.json cannot be decrypted with any known key")
+}
+
+// SaveServerSecret stores the server-issued secret. With the "derived" key
+// source config.json is re-encrypted under the key derived from it: on the
+// first secret this moves the config off the bootstrap key file, and a new
+// secret from the backend rotates the key. The previous secret is kept as
+// server.secret.old until the config has been rewritten.
+func SaveServerSecret(secret string) error {
+	secret = strings.TrimSpace(secret)
+	path := serverSecretPath()
+	current := readServerSecret(path)
+	if secret == "" || secret == current {
+		return nil
+	}
+	if LoadSettings().KeySource != "derived" {
+		if err := writeSecretFile(path, secret); err != nil {
+			return err
+		}
+		setServerSecret(secret)
+		return nil
+	}
+
+	from := KeyProvider()
+	to := derivedKeyProvider(secret)
+	cfg, err := readConfigFile()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if cfg != nil {
+		if err := reencrypt(cfg, from, to); err != nil {
+			return fmt.Errorf("failed to re-encrypt config: %w", err)
+		}
+	}
+
+	if current != "" {
+		if err := writeSecretFile(path+".old", current); err != nil {
+			return fmt.Errorf("failed to back up server secret: %w", err)
+		}
+	}
+	if err := writeSecretFile(path, secret); err != nil {
+		return err
+	}
+	setServerSecret(secret)
+	if cfg != nil {
+		if err := writeConfigFile(cfg); err != nil {
+			return fmt.Errorf("failed to write config: %w", err)
+		}
+	}
+	setKeyProvider(to)
+	if err := reencryptSettings(from, to); err != nil {
+		return fmt.Errorf("failed to re-encrypt settings: %w", err)
+	}
+
+	if current == "" {
+		log.Info("config re-encrypted with the derived key")
+		return removeIfExists(keyPath())
+	}
+	log.Info("config re-encrypted with the new server secret")
+	return removeIfExists(path + ".old")
+}
+
+func writeSecretFile(path, secret string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(secret+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RotateKey generates a new per-install key and re-encrypts config.json
+// with it. The previous key is kept as agent.key.old until the config has
+// been rewritten, so an interrupted rotation can be recovered.
+//
+// With the "derived" key source the key is rotated by the backend issuing
+// a new server secret instead; see SaveServerSecret.
+func RotateKey() error {
+	current, ok := KeyProvider().(*security.FileKeyProvider)
+	if !ok {
+		return ErrDerivedKey
+	}
+
+	oldKey, err := current.Key()
+	if err != nil {
+		return fmt.Errorf("failed to load current key: %w", err)
+	}
+
+	cfg, err := readConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := security.WriteKeyFile(keyPath()+".old", oldKey); err != nil {
+		return fmt.Errorf("failed to back up current key: %w", err)
+	}
+
+	next := security.NewFileKeyProvider(keyPath() + ".new")
+	if err := next.Generate(); err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	if err := reencrypt(cfg, current, next); err != nil {
+		return fmt.Errorf("failed to re-encrypt config: %w", err)
+	}
+	if err := os.Rename(keyPath()+".new", keyPath()); err != nil {
+		return fmt.Errorf("failed to install new key: %w", err)
+	}
+	if err := writeConfigFile(cfg); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := reencryptSettings(current, security.NewFileKeyProvider(keyPath())); err != nil {
+		return fmt.Errorf("failed to re-encrypt settings: %w", err)
+	}
+
+	setKeyProvider(security.NewFileKeyProvider(keyPath()))
+	return os.Remove(keyPath() + ".old")
+}
@@ -0,0 +1,116 @@
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"iDevopzAgent/security"
+	"os"
+	"path/filepath"
+)
+
+// Notifier passwords are kept in settings.json encrypted with the config
+// key, as password_enc. A plaintext password written by the user is sealed
+// by SealSettingsSecrets on startup.
+
+func settingsPath() string {
+	return filepath.Join(DataDir(), "settings.json")
+}
+
+// SMTPPassword returns the notifier's SMTP password, decrypting
+// password_enc when the password was sealed.
+func (n NotifierConfig) SMTPPassword() (string, error) {
+	if n.PasswordEnc == "" {
+		return n.Password, nil
+	}
+	return security.Decrypt(KeyProvider(), n.PasswordEnc)
+}
+
+// SealSettingsSecrets replaces the plaintext notifier passwords in
+// settings.json with their encrypted form.
+func SealSettingsSecrets() error {
+	return rewriteNotifierSecrets(func(n map[string]any) (bool, error) {
+		plain, _ := n["password"].(string)
+		if plain == "" {
+			return false, nil
+		}
+		enc, err := security.Encrypt(KeyProvider(), plain)
+		if err != nil {
+			return false, err
+		}
+		n["password_enc"] = enc
+		delete(n, "password")
+		return true, nil
+	})
+}
+
+// reencryptSettings moves the sealed notifier passwords from one key to
+// another, along with config.json.
+func reencryptSettings(from, to security.KeyProvider) error {
+	return rewriteNotifierSecrets(func(n map[string]any) (bool, error) {
+		enc, _ := n["password_enc"].(string)
+		if enc == "" {
+			return false, nil
+		}
+		plain, err := security.Decrypt(from, enc)
+		if err != nil {
+			return false, err
+		}
+		if enc, err = security.Encrypt(to, plain); err != nil {
+			return false, err
+		}
+		n["password_enc"] = enc
+		return true, nil
+	})
+}
+
+// rewriteNotifierSecrets applies fn to every notifier in settings.json and
+// writes the file back when one changed. Other settings are kept as they
+// are.
+func rewriteNotifierSecrets(fn func(n map[string]any) (bool, error)) error {
+	path := settingsPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	raw, ok := doc["notifiers"]
+	if !ok {
+		return nil
+	}
+	var notifiers []map[string]any
+	if err := json.Unmarshal(raw, &notifiers); err != nil {
+		return err
+	}
+
+	changed := false
+	for _, n := range notifiers {
+		c, err := fn(n)
+		if err != nil {
+			return err
+		}
+		changed = changed || c
+	}
+	if !changed {
+		return nil
+	}
+
+	if doc["notifiers"], err = json.Marshal(notifiers); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,516 @@
+package configs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ProcessGroupRule assigns every process whose Field matches Pattern to the
+// application group Name. Field is one of "name", "cmdline", "exe" or "user".
+type ProcessGroupRule struct {
+	Name    string `json:"name"`
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+}
+
+// AlertCondition compares the value at Metric (a JSON path into the
+// collected payload, e.g. "cpu_percent" or "disk_partitions.used_percent")
+// with Threshold using Operator (>, >=, <, <=, ==, !=).
+type AlertCondition struct {
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+}
+
+// AlertRule fires with Severity once its condition (and every And condition)
+// has held for the For duration. A firing rule only resolves once the value
+// moved Hysteresis units back past the threshold.
+type AlertRule struct {
+	Name string `json:"name"`
+	AlertCondition
+	And        []AlertCondition `json:"and,omitempty"`
+	For        string           `json:"for"`
+	Severity   string           `json:"severity"` // critical, warning, down
+	Hysteresis float64          `json:"hysteresis"`
+}
+
+// NotifierConfig configures one local notification channel. Type is one of
+// "webhook", "slack", "teams", "email" or "syslog"; only the fields for that
+// type are used.
+type NotifierConfig struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	MinSeverity string `json:"min_severity"` // down, warning, critical
+
+	// webhook, slack, teams
+	URL      string            `json:"url"`
+	Template string            `json:"template"` // text/template over the alert event
+	Headers  map[string]string `json:"headers"`
+
+	// email; a plaintext password is replaced by password_enc on startup
+	SMTPAddr    string   `json:"smtp_addr"` // host:port
+	Username    string   `json:"username"`
+	Password    string   `json:"password,omitempty"`
+	PasswordEnc string   `json:"password_enc,omitempty"`
+	From        string   `json:"from"`
+	To          []string `json:"to"`
+
+	// syslog
+	Network string `json:"network"` // "" for the local daemon, udp or tcp
+	Address string `json:"address"`
+	Tag     string `json:"tag"`
+
+	// delivery: at most RateLimitPerMinute messages, repeats of the state
+	// last sent for a rule and instance within DedupMinutes are dropped,
+	// failed sends are retried Retries times.
+	RateLimitPerMinute int `json:"rate_limit_per_minute"`
+	DedupMinutes       int `json:"dedup_minutes"`
+	Retries            int `json:"retries"`
+}
+
+// TLSSettings configures the TLS connection to the backend API. Pins are
+// SHA-256 digests (hex or base64) of a certificate's public key.
+type TLSSettings struct {
+	CAFile     string   `json:"ca_file"`
+	CertFile   string   `json:"cert_file"`
+	KeyFile    string   `json:"key_file"`
+	MinVersion string   `json:"min_version"`
+	ServerName string   `json:"server_name"`
+	Pins       []string `json:"pins"`
+}
+
+// ProxySettings configures the outbound proxy. Without a URL the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+type ProxySettings struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	NoProxy  string `json:"no_proxy"`
+}
+
+// BatchSettings controls how payloads are combined into ingest batches. A
+// batch is flushed when it holds MaxRecords records or MaxBytes of JSON, and
+// at least every FlushSeconds. Compression is "gzip" or "none". zstd is not
+// supported since the agent carries no zstd encoder; "zstd" and other
+// unknown values fall back to gzip.
+type BatchSettings struct {
+	Enabled      bool   `json:"enabled"`
+	MaxRecords   int    `json:"max_records"`
+	MaxBytes     int    `json:"max_bytes"`
+	FlushSeconds int    `json:"flush_seconds"`
+	MaxQueue     int    `json:"max_queue"`    // oldest records are dropped beyond this, 0 for no limit
+	MaxAttempts  int    `json:"max_attempts"` // per record, 0 for no limit
+	Compression  string `json:"compression"`  // gzip or none
+}
+
+// normalize replaces zero or invalid batch settings with the defaults, so a
+// partial settings.json cannot stall or crash the batcher.
+func (b *BatchSettings) normalize() {
+	def := defaultSettings().Batch
+	if b.MaxRecords <= 0 {
+		b.MaxRecords = def.MaxRecords
+	}
+	if b.MaxBytes <= 0 {
+		b.MaxBytes = def.MaxBytes
+	}
+	if b.FlushSeconds <= 0 {
+		b.FlushSeconds = def.FlushSeconds
+	}
+	switch b.Compression {
+	case "gzip", "none":
+	case "":
+		b.Compression = def.Compression
+	case "zstd":
+		log.Error("zstd batch compression is not supported, using gzip")
+		b.Compression = "gzip"
+	default:
+		log.Warn("unsupported batch compression, using gzip", "compression", b.Compression)
+		b.Compression = "gzip"
+	}
+}
+
+// RemoteSettings controls the remote config and command channel. The agent
+// polls every PollSeconds; with LongPollSeconds set the backend may hold the
+// request open that long until a new document is available. PublicKey
+// (base64 ed25519) overrides the key compiled into the agent.
+type RemoteSettings struct {
+	Enabled         bool   `json:"enabled"`
+	PollSeconds     int    `json:"poll_seconds"`
+	LongPollSeconds int    `json:"long_poll_seconds"`
+	PublicKey       string `json:"public_key"`
+}
+
+// UpdateSettings controls self-update. The manifest is checked every
+// CheckMinutes; an installed version that does not report healthy within
+// HealthCheckMinutes is rolled back. ManifestURL defaults to the backend's
+// manifest endpoint, PublicKey (base64 ed25519) to the release key compiled
+// into the agent.
+type UpdateSettings struct {
+	Enabled            bool   `json:"enabled"`
+	ManifestURL        string `json:"manifest_url"`
+	Channel            string `json:"channel"`
+	CheckMinutes       int    `json:"check_minutes"`
+	HealthCheckMinutes int    `json:"health_check_minutes"`
+	PublicKey          string `json:"public_key"`
+}
+
+// LoggingSettings configures the agent log: logs.txt in the data directory,
+// rotated at MaxSizeMB. Levels overrides Level per component (sender,
+// httpclient, configs, ...).
+type LoggingSettings struct {
+	Format     string            `json:"format"` // text or json
+	Level      string            `json:"level"`
+	Levels     map[string]string `json:"levels"`
+	MaxSizeMB  int               `json:"max_size_mb"`
+	MaxBackups int               `json:"max_backups"`
+	Stdout     bool              `json:"stdout"`
+}
+
+// TelemetrySettings controls the agent's reports about itself: a heartbeat
+// every HeartbeatSeconds and, when Listen is set (e.g. "127.0.0.1:9464"), a
+// local /healthz and /debug/vars listener. Token works as for the local API
+// and must be set for addresses other than loopback. The agent is unhealthy
+// when the backend accepted no data for StaleSeconds.
+type TelemetrySettings struct {
+	HeartbeatSeconds int    `json:"heartbeat_seconds"`
+	Listen           string `json:"listen"`
+	Token            string `json:"token"`
+	StaleSeconds     int    `json:"stale_seconds"`
+}
+
+// LocalAPISettings configures the read-only local HTTP API. Listen is a
+// host:port or "unix:<path>"; the API is off when it is empty. Token is
+// required as a bearer token when set, and must be set for addresses other
+// than loopback or a unix socket.
+type LocalAPISettings struct {
+	Listen string `json:"listen"`
+	Token  string `json:"token"`
+}
+
+// LogRule counts and samples log lines matching Pattern (a Go regexp) under
+// Severity (critical, error, warning or info). A line is counted for the
+// first rule it matches.
+type LogRule struct {
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern"`
+	Severity string `json:"severity"`
+}
+
+// JournalSettings configures the systemd journal source. Entries at
+// MaxPriority or more severe (0 emerg ... 7 debug) are read, only from
+// Units and Identifiers when those are set. Entries are counted and run
+// through the log rules; with Forward they are also sent to the backend, at
+// most MaxForwardPerReport per report. SyslogFiles are the files a syslog
+// daemon writes from the journal: while the journal source runs their lines
+// are not counted again, though they are still forwarded.
+type JournalSettings struct {
+	Enabled             bool     `json:"enabled"`
+	MaxPriority         int      `json:"max_priority"`
+	Units               []string `json:"units"`
+	Identifiers         []string `json:"identifiers"`
+	Forward             bool     `json:"forward"`
+	MaxForwardPerReport int      `json:"max_forward_per_report"`
+	SyslogFiles         []string `json:"syslog_files"`
+}
+
+// AuthMonitorSettings configures login and authentication monitoring.
+// LogFiles are the auth logs read for sshd and sudo; the journal is read
+// instead when none of them exists. A source IP or user with at least
+// BruteForceThreshold failed logins within BruteForceWindowSeconds raises
+// an alert.
+type AuthMonitorSettings struct {
+	Enabled                 bool     `json:"enabled"`
+	LogFiles                []string `json:"log_files"`
+	BruteForceThreshold     int      `json:"brute_force_threshold"`
+	BruteForceWindowSeconds int      `json:"brute_force_window_seconds"`
+}
+
+// FileIntegritySettings configures file integrity monitoring. Paths are
+// files or globs on the host; directories themselves are not followed.
+// Changes are picked up through inotify where available, and by a full
+// rescan every RescanMinutes. Files larger than MaxHashBytes are compared
+// by their metadata only.
+type FileIntegritySettings struct {
+	Enabled       bool     `json:"enabled"`
+	Paths         []string `json:"paths"`
+	RescanMinutes int      `json:"rescan_minutes"`
+	MaxHashBytes  int64    `json:"max_hash_bytes"`
+}
+
+// LogForwardSource selects log lines to ship to the backend. Files are
+// paths or globs and are tailed like LogTailSettings.Files.
+//
+// Multiline, when set, matches the first line of a record; the lines that
+// do not match are joined to the record before them (stack traces). Format
+// is a regexp applied to that first line whose named groups "time",
+// "level" and "msg" fill the record, other named groups becoming extra
+// fields; without it the time and level are guessed. TimeLayout is the Go
+// layout of the time, guessed when empty. Include drops the records it
+// does not match. RateLimit caps the records per second (0 is unlimited);
+// a source over its rate, like a full buffer, pauses the tailing of its
+// files instead of dropping lines.
+type LogForwardSource struct {
+	Name       string   `json:"name"`
+	Files      []string `json:"files"`
+	Include    string   `json:"include"`
+	Multiline  string   `json:"multiline"`
+	Format     string   `json:"format"`
+	TimeLayout string   `json:"time_layout"`
+	RateLimit  int      `json:"rate_limit"`
+}
+
+// LogForwardSettings configures log forwarding. Records are buffered up to
+// MaxBuffered and sent gzip-compressed in batches of up to BatchRecords.
+// Records longer than MaxRecordBytes are truncated.
+type LogForwardSettings struct {
+	Enabled        bool               `json:"enabled"`
+	Sources        []LogForwardSource `json:"sources"`
+	BatchRecords   int                `json:"batch_records"`
+	MaxBuffered    int                `json:"max_buffered"`
+	MaxRecordBytes int                `json:"max_record_bytes"`
+}
+
+// LogTailSettings configures the log file tailer. Files are paths or glob
+// patterns; files that do not exist are skipped. Each report carries the
+// per-rule counts and up to SamplesPerRule matching lines. Lines longer than
+// MaxLineBytes are truncated.
+type LogTailSettings struct {
+	Enabled        bool      `json:"enabled"`
+	Files          []string  `json:"files"`
+	Rules          []LogRule `json:"rules"`
+	SamplesPerRule int       `json:"samples_per_rule"`
+	MaxLineBytes   int       `json:"max_line_bytes"`
+
+	Journal JournalSettings    `json:"journal"`
+	Forward LogForwardSettings `json:"forward"`
+}
+
+// DefaultLogRules classify lines as critical, error or warning. The error
+// rule matches "error" in any case, so it counts more lines than the former
+// syslog error count, which only matched "error" and "ERROR".
+func DefaultLogRules() []LogRule {
+	return []LogRule{
+		{Name: "critical", Pattern: `(?i)\b(panic|fatal|critical|emerg(ency)?)\b`, Severity: "critical"},
+		{Name: "error", Pattern: `(?i)error`, Severity: "error"},
+		{Name: "warning", Pattern: `(?i)\bwarn(ing)?\b`, Severity: "warning"},
+	}
+}
+
+// Settings holds the optional agent tuning read from settings.json in the
+// data directory. Every field has a usable default so the file may be absent.
+type Settings struct {
+	// ProcessGroupBy selects the fallback grouping for processes not matched
+	// by a rule: "exe" (executable name) or "unit" (systemd unit / cgroup).
+	ProcessGroupBy    string             `json:"process_group_by"`
+	ProcessGroupRules []ProcessGroupRule `json:"process_group_rules"`
+
+	// Process list delta uploads: a full list is resent every
+	// ProcessResyncMinutes, and a process only counts as changed when CPU or
+	// memory moved by at least the given percentage points, or its thread or
+	// handle count by at least ProcessCountDelta percent.
+	ProcessResyncMinutes int     `json:"process_resync_minutes"`
+	ProcessCPUDelta      float64 `json:"process_cpu_delta"`
+	ProcessMemoryDelta   float64 `json:"process_memory_delta"`
+	ProcessCountDelta    float64 `json:"process_count_delta"`
+
+	// Availability tracking: a heartbeat gap longer than HeartbeatGapSeconds,
+	// or than three health_report intervals when that is longer, counts as
+	// agent downtime. SLATargets maps a window (24h, 7d, 30d) to its
+	// target percentage.
+	HeartbeatGapSeconds int                `json:"heartbeat_gap_seconds"`
+	SLATargets          map[string]float64 `json:"sla_targets"`
+
+	// AlertRules replaces the built-in threshold rules when set.
+	AlertRules []AlertRule `json:"alert_rules"`
+
+	// Notifiers receive alert and health state changes directly from the
+	// agent, independent of the backend.
+	Notifiers []NotifierConfig `json:"notifiers"`
+
+	// KeySource selects how the config encryption key is obtained: "file"
+	// (random per-install key in agent.key) or "derived" (machine ID plus
+	// the server-issued secret in server.secret). Derived mode uses the key
+	// file until the backend issued the secret.
+	KeySource string `json:"key_source"`
+
+	TLS   TLSSettings   `json:"tls"`
+	Proxy ProxySettings `json:"proxy"`
+
+	// Batch combines collector payloads into one compressed request to the
+	// ingest endpoint instead of one request per payload.
+	Batch BatchSettings `json:"batch"`
+
+	Remote RemoteSettings `json:"remote"`
+
+	Update UpdateSettings `json:"update"`
+
+	Logging LoggingSettings `json:"logging"`
+
+	Telemetry TelemetrySettings `json:"telemetry"`
+
+	LocalAPI LocalAPISettings `json:"local_api"`
+
+	LogTail LogTailSettings `json:"log_tail"`
+
+	AuthMonitor AuthMonitorSettings `json:"auth_monitor"`
+
+	FileIntegrity FileIntegritySettings `json:"file_integrity"`
+
+	// HostRoot is where the host's /sys and /proc are read from, "/" unless
+	// the agent runs in a container with the host mounted elsewhere.
+	HostRoot string `json:"host_root"`
+}
+
+var (
+	settingsOnce sync.Once
+	settings     *Settings
+)
+
+// DataDir returns the directory holding config.json and the agent's state files.
+func DataDir() string {
+	return filepath.Dir(getConfigPath())
+}
+
+// DefaultAlertRules are the thresholds the agent has always used for the
+// metrics status and partition health. The health report now uses the same
+// critical rules: before, a resource only counted as unhealthy from 95%
+// CPU, memory or disk, now CPU and memory above 90% already do.
+func DefaultAlertRules() []AlertRule {
+	cond := func(metric, op string, threshold float64) AlertCondition {
+		return AlertCondition{Metric: metric, Operator: op, Threshold: threshold}
+	}
+	return []AlertRule{
+		{Name: "cpu_critical", AlertCondition: cond("cpu_percent", ">", 90), Severity: "critical", For: "1m", Hysteresis: 5},
+		{Name: "memory_critical", AlertCondition: cond("memory_percent", ">", 90), Severity: "critical", For: "1m", Hysteresis: 5},
+		{Name: "disk_critical", AlertCondition: cond("disk_used_percent", ">", 95), Severity: "critical", Hysteresis: 2},
+		{Name: "cpu_trouble", AlertCondition: cond("cpu_percent", ">", 80), Severity: "warning", For: "5m", Hysteresis: 5},
+		{Name: "memory_trouble", AlertCondition: cond("memory_percent", ">", 80), Severity: "warning", For: "5m", Hysteresis: 5},
+		{Name: "disk_trouble", AlertCondition: cond("disk_used_percent", ">", 85), Severity: "warning", Hysteresis: 2},
+		{Name: "partition_critical", AlertCondition: cond("disk_partitions.used_percent", ">", 90), Severity: "critical", Hysteresis: 2},
+		{Name: "partition_warning", AlertCondition: cond("disk_partitions.used_percent", ">", 80), Severity: "warning", Hysteresis: 2},
+		{
+			Name:           "host_idle",
+			AlertCondition: cond("cpu_percent", "<", 5),
+			And: []AlertCondition{
+				cond("memory_percent", "<", 10),
+				cond("disk_used_percent", "<", 10),
+			},
+			Severity: "down",
+			For:      "1m",
+		},
+	}
+}
+
+func defaultSettings() *Settings {
+	return &Settings{
+		ProcessGroupBy:       "exe",
+		ProcessResyncMinutes: 30,
+		ProcessCPUDelta:      5,
+		ProcessMemoryDelta:   1,
+		ProcessCountDelta:    10,
+		HeartbeatGapSeconds:  60,
+		SLATargets: map[string]float64{
+			"24h": 99.9,
+			"7d":  99.9,
+			"30d": 99.9,
+		},
+		AlertRules: DefaultAlertRules(),
+		KeySource:  "file",
+		HostRoot:   "/",
+		LogTail: LogTailSettings{
+			Enabled:        true,
+			Files:          []string{"/var/log/syslog", "/var/log/messages"},
+			Rules:          DefaultLogRules(),
+			SamplesPerRule: 5,
+			MaxLineBytes:   4096,
+			Journal: JournalSettings{
+				Enabled:             true,
+				MaxPriority:         4,
+				Forward:             true,
+				MaxForwardPerReport: 200,
+				SyslogFiles:         []string{"/var/log/syslog", "/var/log/messages"},
+			},
+			Forward: LogForwardSettings{
+				BatchRecords:   500,
+				MaxBuffered:    5000,
+				MaxRecordBytes: 16 << 10,
+			},
+		},
+		AuthMonitor: AuthMonitorSettings{
+			Enabled:                 true,
+			LogFiles:                []string{"/var/log/auth.log", "/var/log/secure"},
+			BruteForceThreshold:     10,
+			BruteForceWindowSeconds: 300,
+		},
+		FileIntegrity: FileIntegritySettings{
+			Enabled: true,
+			Paths: []string{
+				"/etc/passwd",
+				"/etc/shadow",
+				"/etc/group",
+				"/etc/sudoers",
+				"/etc/sudoers.d/*",
+				"/etc/ssh/sshd_config",
+				"/etc/ssh/sshd_config.d/*",
+				// config.json is left out: the agent rewrites it on every
+				// token refresh. settings.json is only rewritten to seal a
+				// password an operator added, or on a key rotation.
+				settingsPath(),
+			},
+			RescanMinutes: 60,
+			MaxHashBytes:  64 << 20,
+		},
+		Batch: BatchSettings{
+			Enabled:      true,
+			MaxRecords:   200,
+			MaxBytes:     512 * 1024,
+			FlushSeconds: 10,
+			MaxQueue:     5000,
+			MaxAttempts:  5,
+			Compression:  "gzip",
+		},
+		Remote: RemoteSettings{
+			Enabled:     true,
+			PollSeconds: 60,
+		},
+		Update: UpdateSettings{
+			Enabled:            true,
+			Channel:            "stable",
+			CheckMinutes:       360,
+			HealthCheckMinutes: 5,
+		},
+		Logging: LoggingSettings{
+			Format:     "text",
+			Level:      "info",
+			MaxSizeMB:  10,
+			MaxBackups: 5,
+			Stdout:     true,
+		},
+		Telemetry: TelemetrySettings{
+			HeartbeatSeconds: 60,
+			StaleSeconds:     300,
+		},
+	}
+}
+
+// LoadSettings reads settings.json once and returns the cached result.
+// A missing or invalid file falls back to the defaults.
+func LoadSettings() *Settings {
+	settingsOnce.Do(func() {
+		settings = defaultSettings()
+
+		path := settingsPath()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(data, settings); err != nil {
+			log.Warn("could not parse settings, using defaults", "path", path, "err", err)
+			settings = defaultSettings()
+		}
+		settings.Batch.normalize()
+	})
+	return settings
+}
@@ -0,0 +1,52 @@
+package configs
+
+import (
+	"iDevopzAgent/security"
+)
+
+// SaveAgentToken stores the backend-issued agent credentials, encrypted,
+// in config.json.
+func SaveAgentToken(token, refreshToken string, expiry int64) error {
+	cfg, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+
+	encToken, err := security.Encrypt(KeyProvider(), token)
+	if err != nil {
+		return err
+	}
+	encRefresh := ""
+	if refreshToken != "" {
+		if encRefresh, err = security.Encrypt(KeyProvider(), refreshToken); err != nil {
+			return err
+		}
+	}
+
+	cfg.AgentToken = encToken
+	cfg.RefreshToken = encRefresh
+	cfg.TokenExpiry = expiry
+	return writeConfigFile(cfg)
+}
+
+// LoadAgentToken returns the stored agent credentials, or empty values when
+// the agent has not registered yet.
+func LoadAgentToken() (token, refreshToken string, expiry int64, err error) {
+	cfg, err := readConfigFile()
+	if err != nil {
+		return "", "", 0, err
+	}
+	if cfg.AgentToken == "" {
+		return "", "", 0, nil
+	}
+
+	if token, err = security.Decrypt(KeyProvider(), cfg.AgentToken); err != nil {
+		return "", "", 0, err
+	}
+	if cfg.RefreshToken != "" {
+		if refreshToken, err = security.Decrypt(KeyProvider(), cfg.RefreshToken); err != nil {
+			return "", "", 0, err
+		}
+	}
+	return token, refreshToken, cfg.TokenExpiry, nil
+}
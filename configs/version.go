@@ -0,0 +1,5 @@
+package configs
+
+// Version is the agent release. Release builds set it with
+// -ldflags "-X iDevopzAgent/configs.Version=1.2.3".
+var Version = "1.0.0"
@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Authenticator signs requests to the backend and renews the agent's
+// credentials when the backend rejects them.
+type Authenticator interface {
+	// Sign adds credentials to req. It returns false for requests it does
+	// not handle (other hosts, the registration call itself), and true for
+	// backend requests even when it holds no credentials yet.
+	Sign(req *http.Request, body []byte) (bool, error)
+	// Renew obtains fresh credentials, or the first ones, after the
+	// backend answered a handled request with 401.
+	Renew(failed *http.Request) error
+}
+
+var (
+	authMu        sync.RWMutex
+	authenticator Authenticator
+)
+
+// SetAuthenticator installs the authenticator used by every request.
+func SetAuthenticator(a Authenticator) {
+	authMu.Lock()
+	authenticator = a
+	authMu.Unlock()
+}
+
+func currentAuthenticator() Authenticator {
+	authMu.RLock()
+	defer authMu.RUnlock()
+	return authenticator
+}
+
+// do sends req, signing it when an authenticator is installed. A backend
+// request answered with 401 triggers one credential renewal and retry.
+func do(req *http.Request, body []byte) (*http.Response, error) {
+	client := clientFor(req)
+
+	auth := currentAuthenticator()
+	if auth == nil {
+		resp, err := client.Do(req)
+		return resp, classify(req, resp, err)
+	}
+
+	retry := req.Clone(req.Context())
+	handled, err := auth.Sign(req, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	err = classify(req, resp, err)
+	if err != nil || !handled || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	if err := auth.Renew(req); err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := auth.Sign(retry, body); err != nil {
+		return nil, err
+	}
+	resp, err = client.Do(retry)
+	return resp, classify(retry, resp, err)
+}
@@ -2,6 +2,7 @@ package httpclient
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"io"
@@ -23,11 +24,11 @@ func SendGET(apiURL string, queryParams map[string]string) (*http.Response, erro
 	}
 	reqURL.RawQuery = q.Encode()
 
-	resp, err := http.Get(reqURL.String())
+	req, err := http.NewRequest("GET", reqURL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
-	return resp, nil
+	return do(req, nil)
 }
 
 // SendPOST sends a POST request with JSON payload to the given endpoint
@@ -48,8 +49,7 @@ func SendDELETE(apiURL string, payload interface{}) (*http.Response, error) {
 			return nil, err
 		}
 		req.Header.Set("Content-Type", "application/json")
-		client := &http.Client{}
-		return client.Do(req)
+		return do(req, nil)
 	}
 	return sendWithBody("DELETE", apiURL, payload)
 }
@@ -61,14 +61,54 @@ func sendWithBody(method, apiURL string, payload interface{}) (*http.Response, e
 		return nil, err
 	}
 
-	req, err := http.NewRequest(method, apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(method, apiURL, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	return client.Do(req)
+	return do(req, jsonData)
+}
+
+// SendPOSTBody sends a POST request with a pre-encoded body and extra headers
+func SendPOSTBody(apiURL string, contentType string, body []byte, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	return do(req, body)
+}
+
+// SendPOSTGzip sends a POST request with a gzip-compressed JSON payload
+func SendPOSTGzip(apiURL string, payload interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(jsonData); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	body := buf.Bytes()
+	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	return do(req, body)
 }
 
 // ParseJSON parses the response body into the target struct/interface
@@ -0,0 +1,236 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyOptions configures the outbound proxy. With an empty URL the standard
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables apply.
+type ProxyOptions struct {
+	URL      string // http://proxy:3128 or https://proxy:3129
+	Username string // basic auth, overrides credentials in URL
+	Password string
+	NoProxy  string // comma-separated hosts, .domains, IPs or CIDRs; "*" disables the proxy
+}
+
+// ProxyError is returned when a request failed at the proxy (unreachable,
+// CONNECT refused, authentication required) rather than at the backend.
+type ProxyError struct {
+	Proxy string
+	Err   error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("proxy %s: %v", e.Proxy, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error { return e.Err }
+
+// IsProxyError reports whether err was caused by the proxy.
+func IsProxyError(err error) bool {
+	var pe *ProxyError
+	return errors.As(err, &pe)
+}
+
+// ConnStatus is the last outcome of outbound requests, split by where they
+// failed so a broken proxy is not mistaken for a backend outage. The
+// backend fields only count requests to the backend; LastSuccessAt is its
+// last 2xx answer.
+type ConnStatus struct {
+	Proxy            string    `json:"proxy,omitempty"`
+	LastProxyError   string    `json:"last_proxy_error,omitempty"`
+	LastProxyErrorAt time.Time `json:"last_proxy_error_at,omitempty"`
+	LastError        string    `json:"last_backend_error,omitempty"`
+	LastErrorAt      time.Time `json:"last_backend_error_at,omitempty"`
+	LastSuccessAt    time.Time `json:"last_success_at,omitempty"`
+}
+
+var (
+	statusMu sync.Mutex
+	status   ConnStatus
+)
+
+// Status returns a snapshot of the connection status.
+func Status() ConnStatus {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return status
+}
+
+// SetProxy installs the proxy settings for every outbound request.
+func SetProxy(opts ProxyOptions) error {
+	fn := http.ProxyFromEnvironment
+	display := "environment"
+
+	if opts.URL != "" {
+		proxyURL, err := url.Parse(opts.URL)
+		if err != nil || proxyURL.Host == "" {
+			return fmt.Errorf("proxy url %q is invalid", opts.URL)
+		}
+		if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+			return fmt.Errorf("proxy url %q: scheme must be http or https", opts.URL)
+		}
+		if opts.Username != "" {
+			proxyURL.User = url.UserPassword(opts.Username, opts.Password)
+		}
+
+		rules := parseNoProxy(opts.NoProxy)
+		fn = func(req *http.Request) (*url.URL, error) {
+			if rules.matches(req.URL) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+		display = proxyURL.Redacted()
+	}
+
+	clientMu.Lock()
+	proxyFunc = fn
+	rebuildClientsLocked()
+	clientMu.Unlock()
+
+	statusMu.Lock()
+	status.Proxy = display
+	statusMu.Unlock()
+	return nil
+}
+
+type noProxyRules struct {
+	all     bool
+	hosts   []string // exact host or domain suffix
+	nets    []*net.IPNet
+	ports   map[string]string // host -> required port
+	ipHosts []net.IP
+}
+
+func parseNoProxy(value string) noProxyRules {
+	rules := noProxyRules{ports: map[string]string{}}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			rules.all = true
+			continue
+		}
+		if _, ipnet, err := net.ParseCIDR(entry); err == nil {
+			rules.nets = append(rules.nets, ipnet)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			rules.ipHosts = append(rules.ipHosts, ip)
+			continue
+		}
+		host := entry
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			host = h
+			rules.ports[strings.TrimPrefix(host, ".")] = p
+		}
+		rules.hosts = append(rules.hosts, strings.TrimPrefix(host, "."))
+	}
+	return rules
+}
+
+// matches reports whether u must bypass the proxy.
+func (r noProxyRules) matches(u *url.URL) bool {
+	if r.all {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() {
+			return true
+		}
+		for _, n := range r.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		for _, h := range r.ipHosts {
+			if h.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+	for _, h := range r.hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			if want, ok := r.ports[h]; ok && want != port {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// classify wraps proxy-level failures in ProxyError and records the outcome
+// of a request in the connection status and the endpoint counters.
+func classify(req *http.Request, resp *http.Response, err error) error {
+	recordEndpoint(req, resp, err)
+
+	var proxyURL *url.URL
+	clientMu.RLock()
+	fn := proxyFunc
+	clientMu.RUnlock()
+	if fn != nil {
+		proxyURL, _ = fn(req)
+	}
+
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	now := time.Now()
+
+	if err == nil && resp.StatusCode == http.StatusProxyAuthRequired {
+		status.LastProxyError = "proxy authentication required"
+		status.LastProxyErrorAt = now
+		return nil
+	}
+	if err == nil {
+		// only the backend acknowledging a request counts as success;
+		// other hosts and rejected requests say nothing about it
+		switch {
+		case !IsBackend(req.URL):
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			status.LastSuccessAt = now
+		case resp.StatusCode >= 400:
+			status.LastError = resp.Status
+			status.LastErrorAt = now
+		}
+		return nil
+	}
+
+	var pe *ProxyError
+	if errors.As(err, &pe) {
+		// CONNECT refused, see rebuildClientsLocked
+		status.LastProxyError = pe.Error()
+		status.LastProxyErrorAt = now
+		return pe
+	}
+	var opErr *net.OpError
+	if proxyURL != nil && errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		pe = &ProxyError{Proxy: proxyURL.Redacted(), Err: err}
+		status.LastProxyError = pe.Error()
+		status.LastProxyErrorAt = now
+		return pe
+	}
+	if IsBackend(req.URL) {
+		status.LastError = err.Error()
+		status.LastErrorAt = now
+	}
+	return err
+}
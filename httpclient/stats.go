@@ -0,0 +1,69 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EndpointStat counts the outcomes of requests to one endpoint. A request
+// succeeds when it gets a 2xx response.
+type EndpointStat struct {
+	Success       int64     `json:"success"`
+	Failure       int64     `json:"failure"`
+	LastStatus    string    `json:"last_status,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastSuccessAt time.Time `json:"last_success_at,omitempty"`
+}
+
+var (
+	endpointMu sync.Mutex
+	endpoints  = map[string]*EndpointStat{}
+)
+
+// Endpoints returns a snapshot of the per-endpoint counters.
+func Endpoints() map[string]EndpointStat {
+	endpointMu.Lock()
+	defer endpointMu.Unlock()
+
+	out := make(map[string]EndpointStat, len(endpoints))
+	for k, v := range endpoints {
+		out[k] = *v
+	}
+	return out
+}
+
+// endpointKey is the URL path for backend requests. Other hosts are only
+// keyed by host: webhook URLs carry their credentials in the path.
+func endpointKey(req *http.Request) string {
+	if IsBackend(req.URL) {
+		return req.URL.Path
+	}
+	return req.URL.Host
+}
+
+func recordEndpoint(req *http.Request, resp *http.Response, err error) {
+	key := endpointKey(req)
+
+	endpointMu.Lock()
+	defer endpointMu.Unlock()
+
+	stat, ok := endpoints[key]
+	if !ok {
+		stat = &EndpointStat{}
+		endpoints[key] = stat
+	}
+	switch {
+	case err != nil:
+		stat.Failure++
+		stat.LastStatus = ""
+		stat.LastError = err.Error()
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		stat.Failure++
+		stat.LastStatus = resp.Status
+	default:
+		stat.Success++
+		stat.LastStatus = resp.Status
+		stat.LastSuccessAt = time.Now()
+	}
+}
@@ -0,0 +1,99 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions configures the TLS connection to the backend.
+type TLSOptions struct {
+	CAFile     string   // PEM bundle trusted in addition to the system roots
+	CertFile   string   // client certificate for mutual TLS
+	KeyFile    string   // client private key for mutual TLS
+	MinVersion string   // "1.2" (default) or "1.3"
+	ServerName string   // overrides the name verified in the server certificate
+	Pins       []string // SHA-256 of a chain certificate's SubjectPublicKeyInfo, hex or base64
+}
+
+// NewTLSConfig builds a tls.Config from opts. Every misconfiguration is
+// reported with the setting at fault so it can be fixed from the error.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: opts.ServerName,
+	}
+
+	switch opts.MinVersion {
+	case "", "1.2":
+	case "1.3":
+		cfg.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("tls min_version %q is not supported, use 1.2 or 1.3", opts.MinVersion)
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls ca_file %s: no PEM certificates found", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("tls cert_file and key_file must be set together")
+	}
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls client certificate %s / %s: %w", opts.CertFile, opts.KeyFile, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(opts.Pins) > 0 {
+		pins := make(map[string]bool, len(opts.Pins))
+		for _, pin := range opts.Pins {
+			sum, err := decodePin(pin)
+			if err != nil {
+				return nil, err
+			}
+			pins[string(sum)] = true
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, cert := range cs.PeerCertificates {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if pins[string(sum[:])] {
+					return nil
+				}
+			}
+			return errors.New("tls: server certificate does not match any pinned key")
+		}
+	}
+
+	return cfg, nil
+}
+
+func decodePin(pin string) ([]byte, error) {
+	pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+	if sum, err := hex.DecodeString(pin); err == nil && len(sum) == sha256.Size {
+		return sum, nil
+	}
+	if sum, err := base64.StdEncoding.DecodeString(pin); err == nil && len(sum) == sha256.Size {
+		return sum, nil
+	}
+	return nil, fmt.Errorf("tls pin %q is not a hex or base64 SHA-256 digest", pin)
+}
@@ -0,0 +1,259 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway certificate authority for the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a leaf certificate signed by the CA for the given names;
+// names that parse as IPs become IP SANs.
+func (ca *testCA) issue(t *testing.T, usage x509.ExtKeyUsage, names ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writePair stores cert and its key as PEM files and returns their paths.
+func writePair(t *testing.T, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (ca *testCA) file(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, path, ca.pem)
+	return path
+}
+
+// newTLSServer starts an HTTPS server presenting cert; configure may adjust
+// its TLS settings before it starts.
+func newTLSServer(t *testing.T, cert tls.Certificate, configure func(*tls.Config)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if configure != nil {
+		configure(srv.TLS)
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get requests url with a client built from opts.
+func get(t *testing.T, opts TLSOptions, url string) error {
+	t.Helper()
+	cfg, err := NewTLSConfig(opts)
+	if err != nil {
+		t.Fatalf("NewTLSConfig: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func TestNewTLSConfigCustomCA(t *testing.T) {
+	ca := newTestCA(t)
+	srv := newTLSServer(t, ca.issue(t, x509.ExtKeyUsageServerAuth, "127.0.0.1"), nil)
+
+	if err := get(t, TLSOptions{}, srv.URL); err == nil {
+		t.Fatal("server signed by an unknown CA was trusted")
+	}
+	if err := get(t, TLSOptions{CAFile: ca.file(t)}, srv.URL); err != nil {
+		t.Fatalf("request with ca_file: %v", err)
+	}
+}
+
+func TestNewTLSConfigServerName(t *testing.T) {
+	ca := newTestCA(t)
+	srv := newTLSServer(t, ca.issue(t, x509.ExtKeyUsageServerAuth, "backend.test"), nil)
+	caFile := ca.file(t)
+
+	if err := get(t, TLSOptions{CAFile: caFile}, srv.URL); err == nil {
+		t.Fatal("certificate for another name was accepted")
+	}
+	if err := get(t, TLSOptions{CAFile: caFile, ServerName: "backend.test"}, srv.URL); err != nil {
+		t.Fatalf("request with server_name: %v", err)
+	}
+	if err := get(t, TLSOptions{CAFile: caFile, ServerName: "other.test"}, srv.URL); err == nil {
+		t.Fatal("certificate was accepted for a different server_name")
+	}
+}
+
+func TestNewTLSConfigClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	clients := x509.NewCertPool()
+	clients.AddCert(ca.cert)
+	srv := newTLSServer(t, ca.issue(t, x509.ExtKeyUsageServerAuth, "127.0.0.1"), func(cfg *tls.Config) {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = clients
+	})
+	caFile := ca.file(t)
+
+	if err := get(t, TLSOptions{CAFile: caFile}, srv.URL); err == nil {
+		t.Fatal("server requiring a client certificate accepted none")
+	}
+	certFile, keyFile := writePair(t, ca.issue(t, x509.ExtKeyUsageClientAuth, "agent"))
+	if err := get(t, TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, srv.URL); err != nil {
+		t.Fatalf("request with client certificate: %v", err)
+	}
+
+	if _, err := NewTLSConfig(TLSOptions{CertFile: certFile}); err == nil {
+		t.Fatal("cert_file without key_file was accepted")
+	}
+	if _, err := NewTLSConfig(TLSOptions{CertFile: certFile, KeyFile: caFile}); err == nil {
+		t.Fatal("mismatched key_file was accepted")
+	}
+}
+
+func TestNewTLSConfigMinVersion(t *testing.T) {
+	ca := newTestCA(t)
+	srv := newTLSServer(t, ca.issue(t, x509.ExtKeyUsageServerAuth, "127.0.0.1"), func(cfg *tls.Config) {
+		cfg.MaxVersion = tls.VersionTLS12
+	})
+	caFile := ca.file(t)
+
+	if err := get(t, TLSOptions{CAFile: caFile, MinVersion: "1.2"}, srv.URL); err != nil {
+		t.Fatalf("TLS 1.2 request: %v", err)
+	}
+	if err := get(t, TLSOptions{CAFile: caFile, MinVersion: "1.3"}, srv.URL); err == nil {
+		t.Fatal("min_version 1.3 connected to a TLS 1.2 server")
+	}
+	if _, err := NewTLSConfig(TLSOptions{MinVersion: "1.1"}); err == nil {
+		t.Fatal("min_version 1.1 was accepted")
+	}
+}
+
+func TestNewTLSConfigPins(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := ca.issue(t, x509.ExtKeyUsageServerAuth, "127.0.0.1")
+	srv := newTLSServer(t, leaf, nil)
+	caFile := ca.file(t)
+
+	leafSum := sha256.Sum256(leaf.Leaf.RawSubjectPublicKeyInfo)
+	caSum := sha256.Sum256(ca.cert.RawSubjectPublicKeyInfo)
+	other := sha256.Sum256([]byte("some other key"))
+
+	for _, tc := range []struct {
+		name string
+		pin  string
+		ok   bool
+	}{
+		{"leaf hex", hex.EncodeToString(leafSum[:]), true},
+		{"leaf base64", "sha256/" + base64.StdEncoding.EncodeToString(leafSum[:]), true},
+		{"ca hex", hex.EncodeToString(caSum[:]), false}, // the CA is not part of the served chain
+		{"mismatch", hex.EncodeToString(other[:]), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := get(t, TLSOptions{CAFile: caFile, Pins: []string{tc.pin}}, srv.URL)
+			if tc.ok && err != nil {
+				t.Fatalf("pinned request: %v", err)
+			}
+			if !tc.ok && (err == nil || !strings.Contains(err.Error(), "pinned key")) {
+				t.Fatalf("want pin mismatch, got %v", err)
+			}
+		})
+	}
+
+	if _, err := NewTLSConfig(TLSOptions{Pins: []string{"not-a-digest"}}); err == nil {
+		t.Fatal("malformed pin was accepted")
+	}
+}
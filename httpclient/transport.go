@@ -0,0 +1,92 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	clientMu      sync.RWMutex
+	backendBase   string
+	backendTLS    *tls.Config
+	proxyFunc     = http.ProxyFromEnvironment
+	backendClient = &http.Client{}
+	defaultClient = &http.Client{}
+)
+
+// SetBackendTLS makes requests to URLs under baseURL use cfg. Other
+// requests (e.g. notification webhooks) keep the default TLS settings and
+// never present the client certificate.
+func SetBackendTLS(baseURL string, cfg *tls.Config) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	backendBase = baseURL
+	backendTLS = cfg
+	rebuildClientsLocked()
+}
+
+// rebuildClientsLocked recreates both clients from the current TLS and
+// proxy settings.
+func rebuildClientsLocked() {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = proxyFunc
+	base.OnProxyConnectResponse = func(ctx context.Context, proxyURL *url.URL, connectReq *http.Request, connectRes *http.Response) error {
+		if connectRes.StatusCode != http.StatusOK {
+			return &ProxyError{Proxy: proxyURL.Redacted(), Err: fmt.Errorf("CONNECT %s: %s", connectReq.Host, connectRes.Status)}
+		}
+		return nil
+	}
+	defaultClient = &http.Client{Transport: base}
+
+	backend := base.Clone()
+	backend.TLSClientConfig = backendTLS
+	backendClient = &http.Client{Transport: backend}
+}
+
+// clientFor returns the client to use for req.
+func clientFor(req *http.Request) *http.Client {
+	clientMu.RLock()
+	defer clientMu.RUnlock()
+
+	if sameOrigin(req.URL, backendBase) {
+		return backendClient
+	}
+	return defaultClient
+}
+
+// IsBackend reports whether u points at the backend set by SetBackendTLS.
+func IsBackend(u *url.URL) bool {
+	clientMu.RLock()
+	defer clientMu.RUnlock()
+	return sameOrigin(u, backendBase)
+}
+
+// sameOrigin reports whether u has the scheme, host and port of base.
+func sameOrigin(u *url.URL, base string) bool {
+	if base == "" {
+		return false
+	}
+	b, err := url.Parse(base)
+	if err != nil || b.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, b.Scheme) &&
+		strings.EqualFold(u.Hostname(), b.Hostname()) &&
+		effectivePort(u) == effectivePort(b)
+}
+
+func effectivePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return "443"
+	}
+	return "80"
+}
@@ -0,0 +1,379 @@
+package alerting
+
+import (
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var log = logging.For("alerting")
+
+// Severity precedence used to derive a single status from matching rules.
+var severityRank = map[string]int{
+	"critical": 3,
+	"warning":  2,
+	"down":     1,
+}
+
+type rule struct {
+	configs.AlertRule
+	forDuration time.Duration
+}
+
+// alertState tracks one rule/instance pair between cycles.
+type alertState struct {
+	pendingSince time.Time
+	firing       bool
+	firedAt      time.Time
+	value        float64
+}
+
+// Engine evaluates the configured alert rules every collection cycle and
+// keeps firing/resolved state between cycles.
+type Engine struct {
+	mu     sync.Mutex
+	rules  []rule
+	states map[string]*alertState
+	now    func() time.Time
+}
+
+var (
+	engineOnce sync.Once
+	engine     *Engine
+)
+
+// GetEngine returns the process-wide engine built from the agent settings.
+func GetEngine() *Engine {
+	engineOnce.Do(func() {
+		engine = NewEngine(configs.LoadSettings().AlertRules)
+	})
+	return engine
+}
+
+// NewEngine compiles rules; rules with an invalid "for" duration or
+// operator are skipped.
+func NewEngine(rules []configs.AlertRule) *Engine {
+	e := &Engine{
+		states: make(map[string]*alertState),
+		now:    time.Now,
+	}
+	e.SetRules(rules)
+	return e
+}
+
+// SetRules replaces the rule set. State of rules that still exist is kept.
+func (e *Engine) SetRules(rules []configs.AlertRule) {
+	var compiled []rule
+	for _, r := range rules {
+		var d time.Duration
+		if r.For != "" {
+			parsed, err := time.ParseDuration(r.For)
+			if err != nil {
+				log.Warn("skipping alert rule with bad for duration", "rule", r.Name, "err", err)
+				continue
+			}
+			d = parsed
+		}
+		if !validOperator(r.Operator) {
+			log.Warn("skipping alert rule with unknown operator", "rule", r.Name, "operator", r.Operator)
+			continue
+		}
+		compiled = append(compiled, rule{AlertRule: r, forDuration: d})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = compiled
+
+	names := make(map[string]bool, len(compiled))
+	for _, r := range compiled {
+		names[r.Name] = true
+	}
+	for key := range e.states {
+		if !names[stateRule(key)] {
+			delete(e.states, key)
+		}
+	}
+}
+
+func validOperator(op string) bool {
+	switch op {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func compare(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
+
+// holds reports whether the rule condition is currently true for v.
+// While firing, the threshold is shifted by the hysteresis so a value
+// hovering around the threshold does not flap.
+func (r rule) holds(v float64, sample Sample, firing bool) bool {
+	threshold := r.Threshold
+	if firing {
+		switch r.Operator {
+		case ">", ">=":
+			threshold -= r.Hysteresis
+		case "<", "<=":
+			threshold += r.Hysteresis
+		}
+	}
+	if !compare(v, r.Operator, threshold) {
+		return false
+	}
+	for _, c := range r.And {
+		other, ok := sample.scalar(c.Metric)
+		if !ok || !compare(other, c.Operator, c.Threshold) {
+			return false
+		}
+	}
+	return true
+}
+
+func stateKey(ruleName, instance string) string {
+	return ruleName + "\x00" + instance
+}
+
+func stateRule(key string) string {
+	name, _, _ := strings.Cut(key, "\x00")
+	return name
+}
+
+// Evaluate runs every rule against metrics and returns the alerts that
+// started firing or resolved during this cycle.
+func (e *Engine) Evaluate(metrics *models.Metrics) []*models.AlertEvent {
+	sample := Flatten(metrics)
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events []*models.AlertEvent
+	seen := make(map[string]bool)
+	for _, r := range e.rules {
+		for _, v := range sample[r.Metric] {
+			key := stateKey(r.Name, v.Instance)
+			seen[key] = true
+
+			st, ok := e.states[key]
+			if !ok {
+				st = &alertState{}
+				e.states[key] = st
+			}
+			st.value = v.Value
+
+			event := &models.AlertEvent{
+				UserID:    metrics.UserID,
+				MachineID: metrics.MachineID,
+				Hostname:  metrics.Hostname,
+				Rule:      r.Name,
+				Metric:    r.Metric,
+				Instance:  v.Instance,
+				Severity:  r.Severity,
+				Value:     v.Value,
+				Threshold: r.Threshold,
+				Timestamp: now.Unix(),
+			}
+
+			if r.holds(v.Value, sample, st.firing) {
+				if st.firing {
+					continue
+				}
+				if st.pendingSince.IsZero() {
+					st.pendingSince = now
+				}
+				if now.Sub(st.pendingSince) >= r.forDuration {
+					st.firing = true
+					st.firedAt = now
+					event.State = "firing"
+					event.StartedAt = st.pendingSince.Unix()
+					events = append(events, event)
+				}
+				continue
+			}
+
+			st.pendingSince = time.Time{}
+			if st.firing {
+				st.firing = false
+				event.State = "resolved"
+				event.StartedAt = st.firedAt.Unix()
+				events = append(events, event)
+			}
+		}
+	}
+
+	// instances that disappeared (e.g. an unmounted partition) resolve
+	for key, st := range e.states {
+		if seen[key] {
+			continue
+		}
+		if st.firing {
+			ruleName := stateRule(key)
+			event := &models.AlertEvent{
+				UserID:    metrics.UserID,
+				MachineID: metrics.MachineID,
+				Hostname:  metrics.Hostname,
+				Rule:      ruleName,
+				Instance:  strings.TrimPrefix(key, ruleName+"\x00"),
+				State:     "resolved",
+				StartedAt: st.firedAt.Unix(),
+				Timestamp: now.Unix(),
+			}
+			for _, r := range e.rules {
+				if r.Name == ruleName {
+					event.Metric, event.Severity, event.Threshold = r.Metric, r.Severity, r.Threshold
+					break
+				}
+			}
+			events = append(events, event)
+		}
+		delete(e.states, key)
+	}
+
+	return events
+}
+
+// Active returns the alerts that are pending or firing as of the last
+// Evaluate, sorted by rule and instance.
+func (e *Engine) Active() []models.ActiveAlert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	active := []models.ActiveAlert{}
+	for _, r := range e.rules {
+		for key, st := range e.states {
+			if stateRule(key) != r.Name {
+				continue
+			}
+			a := models.ActiveAlert{
+				Rule:      r.Name,
+				Metric:    r.Metric,
+				Instance:  strings.TrimPrefix(key, r.Name+"\x00"),
+				Severity:  r.Severity,
+				Value:     st.value,
+				Threshold: r.Threshold,
+			}
+			switch {
+			case st.firing:
+				a.State, a.Since = "firing", st.firedAt.Unix()
+			case !st.pendingSince.IsZero():
+				a.State, a.Since = "pending", st.pendingSince.Unix()
+			default:
+				continue
+			}
+			active = append(active, a)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		if active[i].Rule != active[j].Rule {
+			return active[i].Rule < active[j].Rule
+		}
+		return active[i].Instance < active[j].Instance
+	})
+	return active
+}
+
+// Severity returns the highest severity among rules on metric whose
+// condition currently holds for value, or "" when none match. It ignores
+// "for" durations and And conditions on other metrics.
+func (e *Engine) Severity(metric string, value float64) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	best := ""
+	for _, r := range e.rules {
+		if r.Metric != metric || len(r.And) > 0 {
+			continue
+		}
+		if compare(value, r.Operator, r.Threshold) && severityRank[r.Severity] > severityRank[best] {
+			best = r.Severity
+		}
+	}
+	return best
+}
+
+// SampleSeverity returns the highest severity among all rules whose
+// condition currently holds in sample, or "" when none match.
+func (e *Engine) SampleSeverity(sample Sample) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	best := ""
+	for _, r := range e.rules {
+		for _, v := range sample[r.Metric] {
+			if r.holds(v.Value, sample, false) && severityRank[r.Severity] > severityRank[best] {
+				best = r.Severity
+			}
+		}
+	}
+	return best
+}
+
+// Status derives the metrics status (up, down, trouble, critical) from the
+// rules on host-wide metrics that currently match. Per-instance readings
+// such as partitions have their own health field.
+func (e *Engine) Status(metrics *models.Metrics) string {
+	host := Sample{}
+	for path, values := range Flatten(metrics) {
+		for _, v := range values {
+			if v.Instance == "" {
+				host[path] = append(host[path], v)
+			}
+		}
+	}
+
+	switch e.SampleSeverity(host) {
+	case "critical":
+		return "critical"
+	case "warning":
+		return "trouble"
+	case "down":
+		return "down"
+	}
+	return "up"
+}
+
+// PartitionHealth derives a partition's health (healthy, warning, critical)
+// from the rules on disk_partitions.used_percent.
+func (e *Engine) PartitionHealth(usedPercent float64) string {
+	switch e.Severity("disk_partitions.used_percent", usedPercent) {
+	case "critical":
+		return "critical"
+	case "warning":
+		return "warning"
+	}
+	return "healthy"
+}
+
+// ResourceHealthy reports whether no critical rule matches the given host
+// resource usage. It backs the health report's SLA evaluation, which with
+// the default rules is stricter than the former fixed 95% threshold.
+func (e *Engine) ResourceHealthy(cpuPercent, memPercent, diskPercent float64) bool {
+	sample := Sample{
+		"cpu_percent":       {{Value: cpuPercent}},
+		"memory_percent":    {{Value: memPercent}},
+		"disk_used_percent": {{Value: diskPercent}},
+	}
+	return e.SampleSeverity(sample) != "critical"
+}
@@ -0,0 +1,224 @@
+package alerting
+
+import (
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func alertRule(name, metric, op string, threshold float64, forDuration string, hysteresis float64) configs.AlertRule {
+	return configs.AlertRule{
+		Name:           name,
+		AlertCondition: configs.AlertCondition{Metric: metric, Operator: op, Threshold: threshold},
+		For:            forDuration,
+		Severity:       "critical",
+		Hysteresis:     hysteresis,
+	}
+}
+
+// step is one collection cycle: the readings at a time after the start
+// and the events expected from it, as sorted "rule[instance]=state".
+type step struct {
+	at         time.Duration
+	cpu        float64
+	memory     float64
+	partitions map[string]float64 // mountpoint to used percent
+	want       string
+}
+
+func (s step) metrics() *models.Metrics {
+	m := &models.Metrics{Hostname: "web-1", CPUPercent: s.cpu, MemoryPercent: s.memory}
+	for mount, used := range s.partitions {
+		m.DiskPartitions = append(m.DiskPartitions, models.DiskPartition{Mountpoint: mount, UsedPercent: used})
+	}
+	sort.Slice(m.DiskPartitions, func(i, j int) bool {
+		return m.DiskPartitions[i].Mountpoint < m.DiskPartitions[j].Mountpoint
+	})
+	return m
+}
+
+func describe(events []*models.AlertEvent) string {
+	var out []string
+	for _, e := range events {
+		s := e.Rule
+		if e.Instance != "" {
+			s += "[" + e.Instance + "]"
+		}
+		out = append(out, s+"="+e.State)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules []configs.AlertRule
+		steps []step
+	}{
+		{
+			name:  "fires and resolves without for",
+			rules: []configs.AlertRule{alertRule("cpu", "cpu_percent", ">", 90, "", 0)},
+			steps: []step{
+				{at: 0, cpu: 50, want: ""},
+				{at: time.Minute, cpu: 95, want: "cpu=firing"},
+				{at: 2 * time.Minute, cpu: 97, want: ""}, // still firing, not repeated
+				{at: 3 * time.Minute, cpu: 50, want: "cpu=resolved"},
+			},
+		},
+		{
+			name:  "waits out the for duration",
+			rules: []configs.AlertRule{alertRule("cpu", "cpu_percent", ">", 90, "1m", 0)},
+			steps: []step{
+				{at: 0, cpu: 95, want: ""},
+				{at: 30 * time.Second, cpu: 95, want: ""},
+				{at: 60 * time.Second, cpu: 95, want: "cpu=firing"},
+				{at: 90 * time.Second, cpu: 50, want: "cpu=resolved"},
+			},
+		},
+		{
+			name:  "for restarts when the condition breaks",
+			rules: []configs.AlertRule{alertRule("cpu", "cpu_percent", ">", 90, "1m", 0)},
+			steps: []step{
+				{at: 0, cpu: 95, want: ""},
+				{at: 50 * time.Second, cpu: 50, want: ""},
+				{at: 70 * time.Second, cpu: 95, want: ""},
+				{at: 120 * time.Second, cpu: 95, want: ""},
+				{at: 130 * time.Second, cpu: 95, want: "cpu=firing"},
+			},
+		},
+		{
+			name:  "hysteresis above the threshold",
+			rules: []configs.AlertRule{alertRule("cpu", "cpu_percent", ">", 90, "", 5)},
+			steps: []step{
+				{at: 0, cpu: 95, want: "cpu=firing"},
+				{at: time.Minute, cpu: 88, want: ""}, // below 90 but above 85
+				{at: 2 * time.Minute, cpu: 86, want: ""},
+				{at: 3 * time.Minute, cpu: 84, want: "cpu=resolved"},
+				{at: 4 * time.Minute, cpu: 89, want: ""}, // not firing: plain threshold again
+				{at: 5 * time.Minute, cpu: 91, want: "cpu=firing"},
+			},
+		},
+		{
+			name:  "hysteresis below the threshold",
+			rules: []configs.AlertRule{alertRule("idle", "cpu_percent", "<", 5, "", 2)},
+			steps: []step{
+				{at: 0, cpu: 4, want: "idle=firing"},
+				{at: time.Minute, cpu: 6.5, want: ""},
+				{at: 2 * time.Minute, cpu: 7.5, want: "idle=resolved"},
+			},
+		},
+		{
+			name: "and conditions",
+			rules: []configs.AlertRule{func() configs.AlertRule {
+				r := alertRule("idle", "cpu_percent", "<", 5, "", 0)
+				r.And = []configs.AlertCondition{{Metric: "memory_percent", Operator: "<", Threshold: 10}}
+				return r
+			}()},
+			steps: []step{
+				{at: 0, cpu: 2, memory: 50, want: ""},
+				{at: time.Minute, cpu: 2, memory: 5, want: "idle=firing"},
+				{at: 2 * time.Minute, cpu: 2, memory: 50, want: "idle=resolved"},
+			},
+		},
+		{
+			name:  "per instance and resolve on disappear",
+			rules: []configs.AlertRule{alertRule("partition", "disk_partitions.used_percent", ">", 90, "", 2)},
+			steps: []step{
+				{at: 0, partitions: map[string]float64{"/": 50, "/data": 95}, want: "partition[/data]=firing"},
+				{at: time.Minute, partitions: map[string]float64{"/": 92, "/data": 95}, want: "partition[/]=firing"},
+				{at: 2 * time.Minute, partitions: map[string]float64{"/": 92}, want: "partition[/data]=resolved"},
+				{at: 3 * time.Minute, partitions: map[string]float64{"/": 92, "/data": 95}, want: "partition[/data]=firing"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Unix(1700000000, 0)
+			e := NewEngine(tc.rules)
+			for i, s := range tc.steps {
+				e.now = func() time.Time { return start.Add(s.at) }
+				if got := describe(e.Evaluate(s.metrics())); got != s.want {
+					t.Fatalf("step %d (%v): events %q, want %q", i, s.at, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEngineVanishedInstanceEvent(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	e := NewEngine([]configs.AlertRule{alertRule("partition", "disk_partitions.used_percent", ">", 90, "", 0)})
+
+	e.now = func() time.Time { return start }
+	e.Evaluate(step{partitions: map[string]float64{"/data": 95}}.metrics())
+	if active := e.Active(); len(active) != 1 || active[0].State != "firing" {
+		t.Fatalf("active = %+v", active)
+	}
+
+	e.now = func() time.Time { return start.Add(time.Minute) }
+	events := e.Evaluate(step{}.metrics())
+	if len(events) != 1 {
+		t.Fatalf("events = %q", describe(events))
+	}
+	ev := events[0]
+	if ev.Metric != "disk_partitions.used_percent" || ev.Severity != "critical" || ev.Threshold != 90 ||
+		ev.Hostname != "web-1" || ev.StartedAt != start.Unix() || ev.Timestamp != start.Add(time.Minute).Unix() {
+		t.Fatalf("resolved event = %+v", ev)
+	}
+	if active := e.Active(); len(active) != 0 {
+		t.Fatalf("state kept for a vanished instance: %+v", active)
+	}
+}
+
+func TestEngineSkipsInvalidRules(t *testing.T) {
+	bad := alertRule("bad_for", "cpu_percent", ">", 90, "soon", 0)
+	badOp := alertRule("bad_op", "cpu_percent", "=>", 90, "", 0)
+	e := NewEngine([]configs.AlertRule{bad, badOp, alertRule("cpu", "cpu_percent", ">", 90, "", 0)})
+	if got := describe(e.Evaluate(step{cpu: 95}.metrics())); got != "cpu=firing" {
+		t.Fatalf("events %q, want only the valid rule", got)
+	}
+}
+
+// The health report counts a resource as unhealthy from the default
+// critical rules: above 90% CPU or memory, above 95% disk.
+func TestResourceHealthyDefaults(t *testing.T) {
+	e := NewEngine(configs.DefaultAlertRules())
+	for _, tc := range []struct {
+		cpu, mem, disk float64
+		healthy        bool
+	}{
+		{50, 50, 50, true},
+		{90, 90, 95, true},
+		{92, 50, 50, false},
+		{50, 92, 50, false},
+		{50, 50, 94, true},
+		{50, 50, 96, false},
+	} {
+		if got := e.ResourceHealthy(tc.cpu, tc.mem, tc.disk); got != tc.healthy {
+			t.Errorf("ResourceHealthy(%v, %v, %v) = %v, want %v", tc.cpu, tc.mem, tc.disk, got, tc.healthy)
+		}
+	}
+}
+
+func TestEngineStatus(t *testing.T) {
+	e := NewEngine(configs.DefaultAlertRules())
+	for _, tc := range []struct {
+		s    step
+		want string
+	}{
+		{step{cpu: 50, memory: 50}, "up"},
+		{step{cpu: 85, memory: 50}, "trouble"},
+		{step{cpu: 50, memory: 95}, "critical"},
+		// a full partition has its own health and leaves the host status alone
+		{step{cpu: 50, memory: 50, partitions: map[string]float64{"/data": 99}}, "up"},
+	} {
+		m := tc.s.metrics()
+		m.DiskUsedPercent = 50
+		if got := e.Status(m); got != tc.want {
+			t.Errorf("Status(cpu %v, memory %v) = %q, want %q", tc.s.cpu, tc.s.memory, got, tc.want)
+		}
+	}
+}
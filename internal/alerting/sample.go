@@ -0,0 +1,89 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Value is one reading of a metric. Instance is empty for scalar fields and
+// identifies the array element (mountpoint, device, name) otherwise.
+type Value struct {
+	Instance string
+	Value    float64
+}
+
+// Sample maps a metric path to its readings in one collection cycle.
+type Sample map[string][]Value
+
+// instanceKeys are tried in order to label elements of array fields.
+var instanceKeys = []string{"mountpoint", "device", "name", "group", "pid"}
+
+// Flatten turns a collected payload (e.g. *models.Metrics) into a Sample
+// keyed by JSON field paths such as "cpu_percent" or
+// "disk_partitions.used_percent".
+func Flatten(payload any) Sample {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Sample{}
+	}
+	var tree map[string]any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return Sample{}
+	}
+
+	sample := Sample{}
+	flattenInto(sample, "", "", tree)
+	return sample
+}
+
+func flattenInto(sample Sample, prefix, instance string, node map[string]any) {
+	for key, v := range node {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch val := v.(type) {
+		case float64:
+			sample[path] = append(sample[path], Value{Instance: instance, Value: val})
+		case bool:
+			f := 0.0
+			if val {
+				f = 1
+			}
+			sample[path] = append(sample[path], Value{Instance: instance, Value: f})
+		case map[string]any:
+			flattenInto(sample, path, instance, val)
+		case []any:
+			for i, elem := range val {
+				obj, ok := elem.(map[string]any)
+				if !ok {
+					continue
+				}
+				flattenInto(sample, path, elementInstance(obj, i), obj)
+			}
+		}
+	}
+}
+
+func elementInstance(obj map[string]any, index int) string {
+	for _, key := range instanceKeys {
+		if v, ok := obj[key]; ok {
+			if s := strings.TrimSpace(fmt.Sprint(v)); s != "" {
+				return s
+			}
+		}
+	}
+	return fmt.Sprintf("%d", index)
+}
+
+// scalar returns the first reading of path, for conditions that compare a
+// host-wide value.
+func (s Sample) scalar(path string) (float64, bool) {
+	values := s[path]
+	if len(values) == 0 {
+		return 0, false
+	}
+	return values[0].Value, true
+}
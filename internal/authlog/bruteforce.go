@@ -0,0 +1,128 @@
+package authlog
+
+import (
+	"iDevopzAgent/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ruleBruteForceSource = "auth_brute_force_source"
+	ruleBruteForceUser   = "auth_brute_force_user"
+	bruteForceMetric     = "auth.failed_logins"
+)
+
+// bucket counts the failures of one minute.
+type bucket struct {
+	minute int64
+	count  int
+}
+
+type bruteState struct {
+	buckets []bucket
+	firing  bool
+	since   time.Time
+}
+
+// bruteForce counts failed logins per source IP and per user over a
+// sliding window, in one-minute buckets so memory depends on the number of
+// attackers rather than attempts. A key fires once its count within the
+// window reaches the threshold and resolves when it drops below it.
+type bruteForce struct {
+	threshold int
+	window    time.Duration
+	states    map[string]*bruteState // "ip\x00..." or "user\x00..."
+}
+
+func newBruteForce(threshold int, window time.Duration) *bruteForce {
+	return &bruteForce{threshold: threshold, window: window, states: map[string]*bruteState{}}
+}
+
+func (b *bruteForce) add(ip, user string, t time.Time, n int) {
+	if b.threshold <= 0 {
+		return
+	}
+	if ip != "" {
+		b.count("ip\x00"+ip, t, n)
+	}
+	if user != "" {
+		b.count("user\x00"+user, t, n)
+	}
+}
+
+func (b *bruteForce) count(key string, t time.Time, n int) {
+	st := b.states[key]
+	if st == nil {
+		st = &bruteState{}
+		b.states[key] = st
+	}
+	minute := t.Unix() / 60
+	if len(st.buckets) > 0 && st.buckets[len(st.buckets)-1].minute == minute {
+		st.buckets[len(st.buckets)-1].count += n
+		return
+	}
+	st.buckets = append(st.buckets, bucket{minute: minute, count: n})
+}
+
+// evaluate drops the buckets that left the window and returns the keys
+// that started or stopped firing.
+func (b *bruteForce) evaluate(now time.Time) []*models.AlertEvent {
+	oldest := now.Add(-b.window).Unix() / 60
+
+	keys := make([]string, 0, len(b.states))
+	for key := range b.states {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var events []*models.AlertEvent
+	for _, key := range keys {
+		st := b.states[key]
+		i := 0
+		for i < len(st.buckets) && st.buckets[i].minute < oldest {
+			i++
+		}
+		st.buckets = st.buckets[i:]
+		total := 0
+		for _, bk := range st.buckets {
+			total += bk.count
+		}
+
+		kind, instance := splitKey(key)
+		event := &models.AlertEvent{
+			Rule:      ruleBruteForceSource,
+			Metric:    bruteForceMetric,
+			Instance:  instance,
+			Severity:  "critical",
+			Value:     float64(total),
+			Threshold: float64(b.threshold),
+			Timestamp: now.Unix(),
+		}
+		if kind == "user" {
+			event.Rule = ruleBruteForceUser
+		}
+
+		switch {
+		case total >= b.threshold && !st.firing:
+			st.firing, st.since = true, now
+			event.State = "firing"
+			event.StartedAt = now.Unix()
+			events = append(events, event)
+		case total < b.threshold && st.firing:
+			st.firing = false
+			event.State = "resolved"
+			event.StartedAt = st.since.Unix()
+			events = append(events, event)
+		}
+		if len(st.buckets) == 0 && !st.firing {
+			delete(b.states, key)
+		}
+	}
+	return events
+}
+
+func splitKey(key string) (kind, instance string) {
+	kind, instance, _ = strings.Cut(key, "\x00")
+	return kind, instance
+}
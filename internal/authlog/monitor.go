@@ -0,0 +1,360 @@
+// Package authlog reports logins, failed login attempts and sudo usage.
+//
+// sshd and sudo messages come from the auth log files or, on hosts without
+// them, the journal; login records come from wtmp and btmp. To count each
+// login once, successful logins are taken from wtmp when it is readable
+// (it covers consoles as well as ssh), and btmp is only used for failures
+// when neither the auth logs nor the journal are available.
+package authlog
+
+import (
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/hostfs"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/internal/logtail"
+	"iDevopzAgent/internal/utils"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var log = logging.For("authlog")
+
+// maxEvents bounds the logins and sudo events held per report.
+const maxEvents = 1000
+
+// utmpState is the persisted read position in wtmp or btmp.
+type utmpState struct {
+	Known  bool   `json:"known"`
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+type authState struct {
+	Wtmp      utmpState `json:"wtmp"`
+	Btmp      utmpState `json:"btmp"`
+	SudoUsers []string  `json:"sudo_users"`
+}
+
+type failureKey struct {
+	user, ip, service string
+	invalid           bool
+}
+
+// Monitor collects the authentication activity of one report interval.
+type Monitor struct {
+	mu        sync.Mutex
+	cfg       configs.AuthMonitorSettings
+	root      string
+	statePath string
+	state     authState
+	sudoUsers map[string]bool
+
+	sources  []string
+	useWtmp  bool
+	useBtmp  bool
+	start    time.Time
+	logins   []models.LoginEvent
+	failures map[failureKey]*models.FailedLogins
+	sudo     []models.SudoEvent
+	dropped  int
+
+	brute *bruteForce
+}
+
+var (
+	monitorOnce sync.Once
+	monitor     *Monitor
+)
+
+// GetMonitor returns the process-wide monitor built from the agent
+// settings.
+func GetMonitor() *Monitor {
+	monitorOnce.Do(func() {
+		settings := configs.LoadSettings()
+		cfg := settings.AuthMonitor
+		window := time.Duration(cfg.BruteForceWindowSeconds) * time.Second
+		if window <= 0 {
+			window = 5 * time.Minute
+		}
+		monitor = &Monitor{
+			cfg:       cfg,
+			root:      settings.HostRoot,
+			statePath: filepath.Join(configs.DataDir(), "auth_state.json"),
+			sudoUsers: map[string]bool{},
+			start:     time.Now(),
+			failures:  map[failureKey]*models.FailedLogins{},
+			brute:     newBruteForce(cfg.BruteForceThreshold, window),
+		}
+	})
+	return monitor
+}
+
+// Enabled reports whether authentication monitoring is on.
+func (m *Monitor) Enabled() bool {
+	return m.cfg.Enabled
+}
+
+// Start picks the sources available on the host and starts following
+// them. wtmp and btmp are read by Report.
+func (m *Monitor) Start() {
+	if !m.cfg.Enabled {
+		return
+	}
+	m.mu.Lock()
+	m.load()
+
+	if err := probeUtmp(m.hostPath("var/log/wtmp"), &m.state.Wtmp); err == nil {
+		m.useWtmp = true
+		m.sources = append(m.sources, "wtmp")
+	}
+
+	// the tailer and journal call back into m, so they are started once m
+	// is unlocked
+	var start func()
+	switch {
+	case m.anyLogFile():
+		start = func() { logtail.GetTailer().Watch(m.cfg.LogFiles, m.observeLine) }
+		m.sources = append(m.sources, "auth_log")
+	case logtail.JournalAvailable():
+		start = func() { go logtail.WatchJournal("auth", programs, m.observeJournal).Run() }
+		m.sources = append(m.sources, "journal")
+	default:
+		if err := probeUtmp(m.hostPath("var/log/btmp"), &m.state.Btmp); err == nil {
+			m.useBtmp = true
+			m.sources = append(m.sources, "btmp")
+		}
+	}
+	m.save()
+	sources := m.sources
+	m.mu.Unlock()
+
+	if start != nil {
+		start()
+	}
+	log.Info("auth monitoring started", "sources", sources)
+}
+
+func (m *Monitor) hostPath(p string) string {
+	return filepath.Join(m.root, p)
+}
+
+func (m *Monitor) anyLogFile() bool {
+	for _, pattern := range m.cfg.LogFiles {
+		if matches, _ := filepath.Glob(m.hostPath(pattern)); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Monitor) observeLine(_ string, line []byte) {
+	program, msg, ok := parseSyslogLine(string(line))
+	if !ok {
+		return
+	}
+	if ev := parseMessage(program, msg); ev != nil {
+		m.record(ev, "auth_log", time.Now())
+	}
+}
+
+func (m *Monitor) observeJournal(e models.JournalEntry) {
+	if ev := parseMessage(e.Identifier, e.Message); ev != nil {
+		t := time.Now()
+		if e.Time > 0 {
+			t = time.UnixMicro(e.Time)
+		}
+		m.record(ev, "journal", t)
+	}
+}
+
+// record adds one event to the current interval.
+func (m *Monitor) record(ev *authEvent, origin string, t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch ev.kind {
+	case eventLogin:
+		if m.useWtmp {
+			return // wtmp has it
+		}
+		m.addLogin(models.LoginEvent{
+			User:     ev.user,
+			SourceIP: ev.ip,
+			Service:  ev.service,
+			Method:   ev.method,
+			Origin:   origin,
+			Time:     t.Unix(),
+		})
+	case eventFailure:
+		m.addFailure(ev, t)
+	case eventSudo:
+		first := !m.sudoUsers[ev.user]
+		if first {
+			m.sudoUsers[ev.user] = true
+			m.state.SudoUsers = append(m.state.SudoUsers, ev.user)
+			m.save()
+		}
+		if len(m.sudo) >= maxEvents {
+			m.dropped++
+			return
+		}
+		m.sudo = append(m.sudo, models.SudoEvent{
+			User:     ev.user,
+			RunAs:    ev.runAs,
+			Command:  ev.command,
+			TTY:      ev.tty,
+			PWD:      ev.pwd,
+			Allowed:  ev.allowed,
+			Reason:   ev.reason,
+			FirstUse: first,
+			Time:     t.Unix(),
+		})
+	}
+}
+
+func (m *Monitor) addLogin(l models.LoginEvent) {
+	if len(m.logins) >= maxEvents {
+		m.dropped++
+		return
+	}
+	m.logins = append(m.logins, l)
+}
+
+func (m *Monitor) addFailure(ev *authEvent, t time.Time) {
+	key := failureKey{user: ev.user, ip: ev.ip, service: ev.service, invalid: ev.invalidUser}
+	f, ok := m.failures[key]
+	if !ok {
+		if len(m.failures) >= maxEvents {
+			m.dropped++
+			return
+		}
+		f = &models.FailedLogins{
+			User:        ev.user,
+			SourceIP:    ev.ip,
+			Service:     ev.service,
+			InvalidUser: ev.invalidUser,
+			FirstSeen:   t.Unix(),
+		}
+		m.failures[key] = f
+	}
+	f.Count += ev.count
+	f.LastSeen = max(f.LastSeen, t.Unix())
+	m.brute.add(ev.ip, ev.user, t, ev.count)
+}
+
+// readUtmpFiles adds the wtmp logins and, when used, the btmp failures
+// recorded since the last call.
+func (m *Monitor) readUtmpFiles() {
+	if m.useWtmp {
+		records, err := readUtmp(m.hostPath("var/log/wtmp"), &m.state.Wtmp)
+		if err != nil {
+			log.Debug("read wtmp failed", "err", err)
+		}
+		for _, r := range records {
+			if r.Type != hostfs.UtmpUserProcess || r.User == "" {
+				continue
+			}
+			service := "login"
+			if r.Host != "" {
+				service = "remote"
+			}
+			m.addLogin(models.LoginEvent{
+				User:     r.User,
+				SourceIP: r.IP,
+				Service:  service,
+				TTY:      r.Line,
+				Origin:   "wtmp",
+				Time:     r.Time.Unix(),
+			})
+		}
+	}
+	if m.useBtmp {
+		records, err := readUtmp(m.hostPath("var/log/btmp"), &m.state.Btmp)
+		if err != nil {
+			log.Debug("read btmp failed", "err", err)
+		}
+		for _, r := range records {
+			if r.User == "" {
+				continue
+			}
+			m.addFailure(&authEvent{kind: eventFailure, user: r.User, ip: r.IP, service: "btmp", count: 1}, r.Time)
+		}
+	}
+}
+
+// Report returns the activity since the previous report, or nil when there
+// was none, and the brute-force alerts that started or stopped firing.
+func (m *Monitor) Report(userID, machineID, hostname string) (*models.AuthReport, []*models.AlertEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.readUtmpFiles()
+	m.save()
+
+	now := time.Now()
+	alerts := m.brute.evaluate(now)
+	for _, a := range alerts {
+		a.UserID, a.MachineID, a.Hostname = userID, machineID, hostname
+	}
+	if m.dropped > 0 {
+		log.Warn("auth events dropped, too many in one interval", "dropped", m.dropped)
+	}
+
+	var report *models.AuthReport
+	if len(m.logins) > 0 || len(m.failures) > 0 || len(m.sudo) > 0 {
+		report = &models.AuthReport{
+			UserID:        userID,
+			MachineID:     machineID,
+			Hostname:      hostname,
+			IntervalStart: m.start.Unix(),
+			IntervalEnd:   now.Unix(),
+			Logins:        m.logins,
+			Sudo:          m.sudo,
+			Sources:       m.sources,
+		}
+		for _, f := range m.failures {
+			report.Failures = append(report.Failures, *f)
+		}
+		sort.Slice(report.Failures, func(i, j int) bool {
+			return report.Failures[i].Count > report.Failures[j].Count
+		})
+		if users, err := utils.GetLoggedInUsers(); err == nil {
+			report.Sessions = len(users)
+		}
+	}
+
+	m.start = now
+	m.logins, m.sudo, m.dropped = nil, nil, 0
+	m.failures = map[failureKey]*models.FailedLogins{}
+	return report, alerts
+}
+
+func (m *Monitor) load() {
+	data, err := os.ReadFile(m.statePath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &m.state); err != nil {
+		log.Warn("ignoring corrupt auth state", "err", err)
+		m.state = authState{}
+	}
+	for _, u := range m.state.SudoUsers {
+		m.sudoUsers[u] = true
+	}
+}
+
+func (m *Monitor) save() {
+	data, err := json.Marshal(m.state)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(m.statePath), 0700)
+	if err := os.WriteFile(m.statePath, data, 0600); err != nil {
+		log.Error("failed to save auth state", "err", err)
+	}
+}
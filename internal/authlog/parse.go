@@ -0,0 +1,125 @@
+package authlog
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	eventLogin   = "login"
+	eventFailure = "failure"
+	eventSudo    = "sudo"
+)
+
+// authEvent is what one sshd or sudo message says.
+type authEvent struct {
+	kind        string
+	user        string
+	ip          string
+	service     string
+	method      string
+	invalidUser bool
+	count       int // rsyslog folds repeats into one line
+
+	// sudo only
+	runAs   string
+	command string
+	tty     string
+	pwd     string
+	allowed bool
+	reason  string
+}
+
+// programs are the syslog identifiers whose messages are parsed.
+var programs = []string{"sshd", "sshd-session", "sudo"}
+
+var (
+	// syslogProgram finds "sshd[123]: message" in a syslog line, after the
+	// timestamp and host.
+	syslogProgram = regexp.MustCompile(`\s(sshd|sshd-session|sudo)(?:\[\d+\])?: (.*)$`)
+	repeated      = regexp.MustCompile(`^message repeated (\d+) times: \[\s*(.*?)\s*\]$`)
+
+	sshAccepted = regexp.MustCompile(`^Accepted (\S+) for (\S+) from (\S+) port \d+`)
+	sshFailed   = regexp.MustCompile(`^Failed (\S+) for (invalid user )?(\S+) from (\S+) port \d+`)
+	sshInvalid  = regexp.MustCompile(`^Invalid user (\S*) from (\S+)`)
+	sudoLine    = regexp.MustCompile(`^\s*(\S+) : (.*)$`)
+)
+
+// parseSyslogLine splits an auth log line into the program and message,
+// returning ok false for lines of other programs.
+func parseSyslogLine(line string) (program, msg string, ok bool) {
+	m := syslogProgram.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// parseMessage reads an sshd or sudo message. It returns nil for messages
+// that are not logins, failed attempts or sudo invocations.
+//
+// sshd logs "Invalid user" once per connection and then "Failed ... for
+// invalid user" per attempt, so only the former is counted: a failure per
+// connection for unknown users, as key-only servers never log the latter.
+func parseMessage(program, msg string) *authEvent {
+	count := 1
+	if m := repeated.FindStringSubmatch(msg); m != nil {
+		count, _ = strconv.Atoi(m[1])
+		msg = m[2]
+	}
+
+	switch program {
+	case "sshd", "sshd-session":
+		if m := sshAccepted.FindStringSubmatch(msg); m != nil {
+			return &authEvent{kind: eventLogin, method: m[1], user: m[2], ip: m[3], service: "sshd", count: count}
+		}
+		if m := sshInvalid.FindStringSubmatch(msg); m != nil {
+			return &authEvent{kind: eventFailure, user: m[1], ip: m[2], service: "sshd", invalidUser: true, count: count}
+		}
+		if m := sshFailed.FindStringSubmatch(msg); m != nil && m[2] == "" {
+			return &authEvent{kind: eventFailure, method: m[1], user: m[3], ip: m[4], service: "sshd", count: count}
+		}
+	case "sudo":
+		return parseSudo(msg)
+	}
+	return nil
+}
+
+// parseSudo reads sudo's "alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ;
+// COMMAND=/usr/bin/id" lines. A leading part without "=" is the reason the
+// command was denied, e.g. "3 incorrect password attempts".
+func parseSudo(msg string) *authEvent {
+	m := sudoLine.FindStringSubmatch(msg)
+	if m == nil {
+		return nil
+	}
+	ev := &authEvent{kind: eventSudo, user: m[1], service: "sudo", allowed: true, count: 1}
+
+	rest := m[2]
+	var command string
+	if before, after, ok := strings.Cut(rest, "COMMAND="); ok {
+		rest, command = before, after
+	}
+	for _, part := range strings.Split(rest, " ; ") {
+		part = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(part), ";"))
+		key, value, ok := strings.Cut(part, "=")
+		switch {
+		case part == "":
+		case !ok:
+			ev.allowed = false
+			ev.reason = part
+		case key == "TTY":
+			ev.tty = value
+		case key == "PWD":
+			ev.pwd = value
+		case key == "USER":
+			ev.runAs = value
+		}
+	}
+	ev.command = strings.TrimSpace(command)
+	if ev.command == "" && ev.allowed {
+		return nil // not an invocation, e.g. a sudo error message
+	}
+	return ev
+}
@@ -0,0 +1,66 @@
+package authlog
+
+import (
+	"iDevopzAgent/internal/hostfs"
+	"io"
+	"os"
+)
+
+// maxUtmpRead bounds the records read per call.
+const maxUtmpRead = 4096 * hostfs.UtmpSize
+
+// probeUtmp reports whether path can be read. A file seen for the first
+// time is measured; a known one keeps its offset, so the next read returns
+// the records written while the agent was down.
+func probeUtmp(path string, st *utmpState) error {
+	if !st.Known {
+		_, err := readUtmp(path, st)
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readUtmp returns the complete records of path after st's offset and
+// advances it. A file that was replaced or truncated is read from the
+// start; a file seen for the first time is only measured.
+func readUtmp(path string, st *utmpState) ([]hostfs.UtmpRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	ino := hostfs.Inode(info)
+	if !st.Known {
+		st.Known, st.Inode, st.Offset = true, ino, info.Size()-info.Size()%hostfs.UtmpSize
+		return nil, nil
+	}
+	if st.Inode != ino || info.Size() < st.Offset {
+		st.Inode, st.Offset = ino, 0
+	}
+
+	n := min(info.Size()-st.Offset, maxUtmpRead)
+	n -= n % hostfs.UtmpSize
+	if n <= 0 {
+		return nil, nil
+	}
+	data := make([]byte, n)
+	if _, err := f.ReadAt(data, st.Offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	st.Offset += n
+
+	records := make([]hostfs.UtmpRecord, 0, n/hostfs.UtmpSize)
+	for off := 0; off < len(data); off += hostfs.UtmpSize {
+		records = append(records, hostfs.ParseUtmp(data[off:off+hostfs.UtmpSize]))
+	}
+	return records, nil
+}
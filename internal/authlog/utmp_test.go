@@ -0,0 +1,48 @@
+package authlog
+
+import (
+	"iDevopzAgent/internal/hostfs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendRecords(t *testing.T, path string, n int) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(make([]byte, n*hostfs.UtmpSize)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProbeUtmpKeepsDowntimeRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wtmp")
+	appendRecords(t, path, 3)
+
+	// first start: the history is skipped
+	var st utmpState
+	if err := probeUtmp(path, &st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Known || st.Offset != 3*hostfs.UtmpSize {
+		t.Fatalf("state = %+v after the first probe", st)
+	}
+
+	// records written while the agent was down are read after a restart
+	appendRecords(t, path, 2)
+	if err := probeUtmp(path, &st); err != nil {
+		t.Fatal(err)
+	}
+	records, err := readUtmp(path, &st)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("read %d records, %v; want the 2 written meanwhile", len(records), err)
+	}
+
+	if err := probeUtmp(filepath.Join(t.TempDir(), "btmp"), &utmpState{}); err == nil {
+		t.Fatal("probe of a missing file succeeded")
+	}
+}
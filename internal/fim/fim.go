@@ -0,0 +1,386 @@
+// Package fim detects changes to the configured files against a baseline
+// of their hash, mode, owner and mtime.
+package fim
+
+import (
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var log = logging.For("fim")
+
+const (
+	// maxPending bounds the events held between two checks.
+	maxPending = 1000
+
+	// settleDelay lets a burst of inotify events settle, e.g. an editor
+	// renaming the old file away and writing the new one, so one save is
+	// one change.
+	settleDelay = 2 * time.Second
+)
+
+// Monitor keeps the baseline of the monitored files, persisted to
+// fim_baseline.json, and the change events found since the last Check.
+type Monitor struct {
+	mu           sync.Mutex
+	cfg          configs.FileIntegritySettings
+	root         string
+	baselinePath string
+	baseline     map[string]models.FileMeta
+
+	watcher  *watcher
+	dirty    map[string]string // host name -> full path, awaiting settle
+	settle   *time.Timer
+	lastScan time.Time
+	rescan   bool // inotify lost events
+	pending  []*models.FileChangeEvent
+	dropped  int
+}
+
+var (
+	monitorOnce sync.Once
+	monitor     *Monitor
+)
+
+// GetMonitor returns the process-wide monitor built from the agent
+// settings.
+func GetMonitor() *Monitor {
+	monitorOnce.Do(func() {
+		settings := configs.LoadSettings()
+		monitor = &Monitor{
+			cfg:          settings.FileIntegrity,
+			root:         settings.HostRoot,
+			baselinePath: filepath.Join(configs.DataDir(), "fim_baseline.json"),
+			dirty:        map[string]string{},
+		}
+		if monitor.cfg.RescanMinutes <= 0 {
+			monitor.cfg.RescanMinutes = 60
+		}
+	})
+	return monitor
+}
+
+// Enabled reports whether file integrity monitoring is on.
+func (m *Monitor) Enabled() bool {
+	return m.cfg.Enabled && len(m.cfg.Paths) > 0
+}
+
+// Start loads the baseline, or records it when there is none yet, and
+// starts watching the files' directories. Without inotify changes are only
+// found by the rescans in Check.
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.load()
+	if m.baseline == nil {
+		m.baseline = map[string]models.FileMeta{}
+		for name, meta := range m.scanAll() {
+			m.baseline[name] = *meta
+		}
+		m.save()
+		m.lastScan = time.Now()
+		log.Info("file integrity baseline recorded", "files", len(m.baseline))
+	}
+
+	w, err := newWatcher(m.onEvent)
+	if err != nil {
+		log.Warn("file watching unavailable, relying on rescans", "err", err)
+		return
+	}
+	m.watcher = w
+	m.watchDirs()
+	go w.run()
+}
+
+// Check returns the changes found since the previous call. It rescans
+// every file when the rescan interval passed, when inotify is unavailable
+// or after inotify dropped events.
+func (m *Monitor) Check(userID, machineID, hostname string) []*models.FileChangeEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.watcher == nil || m.watcher.failed() || m.rescan || now.Sub(m.lastScan) >= time.Duration(m.cfg.RescanMinutes)*time.Minute {
+		m.rescan = false
+		m.lastScan = now
+		m.scan()
+		if m.watcher != nil {
+			m.watchDirs() // directories created since
+		}
+	}
+
+	if m.dropped > 0 {
+		log.Warn("file change events dropped", "dropped", m.dropped)
+	}
+	events := m.pending
+	m.pending, m.dropped = nil, 0
+	for _, e := range events {
+		e.UserID, e.MachineID, e.Hostname = userID, machineID, hostname
+	}
+	return events
+}
+
+// scan compares every monitored file with the baseline.
+func (m *Monitor) scan() {
+	current := m.scanAll()
+	names := make([]string, 0, len(current)+len(m.baseline))
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range m.baseline {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changed := false
+	for _, name := range names {
+		changed = m.compare(name, current[name], "rescan") || changed
+	}
+	if changed {
+		m.save()
+	}
+}
+
+// onEvent handles an inotify event for name in dir. The file is looked at
+// once the events for it settled.
+func (m *Monitor) onEvent(dir, name string, overflow bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if overflow {
+		m.rescan = true
+		return
+	}
+	full := filepath.Join(dir, name)
+	hostName := m.hostName(full)
+	if !m.monitored(hostName) {
+		return
+	}
+	m.dirty[hostName] = full
+	if m.settle == nil {
+		m.settle = time.AfterFunc(settleDelay, m.checkDirty)
+	} else {
+		m.settle.Reset(settleDelay)
+	}
+}
+
+// checkDirty compares the files inotify reported with the baseline.
+func (m *Monitor) checkDirty() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.dirty))
+	for name := range m.dirty {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := false
+	for _, name := range names {
+		meta, err := statFile(m.dirty[name], name, m.cfg.MaxHashBytes)
+		if err != nil && !os.IsNotExist(err) {
+			log.Debug("stat monitored file failed", "file", name, "err", err)
+			continue
+		}
+		changed = m.compare(name, meta, "inotify") || changed
+	}
+	m.dirty = map[string]string{}
+	m.settle = nil
+	if changed {
+		m.save()
+	}
+}
+
+// compare records an event when meta (nil for a missing file) differs
+// from the baseline of name, and updates the baseline.
+func (m *Monitor) compare(name string, meta *models.FileMeta, detectedBy string) bool {
+	old, had := m.baseline[name]
+	e := &models.FileChangeEvent{
+		Path:       name,
+		DetectedBy: detectedBy,
+		Timestamp:  time.Now().Unix(),
+	}
+	switch {
+	case !had && meta == nil:
+		return false
+	case !had:
+		e.Change, e.After = "created", meta
+		m.baseline[name] = *meta
+	case meta == nil:
+		e.Change, e.Before = "deleted", &old
+		delete(m.baseline, name)
+	default:
+		e.Fields = diff(old, *meta)
+		if len(e.Fields) == 0 {
+			return false
+		}
+		e.Change = "attributes"
+		for _, f := range e.Fields {
+			if f == "sha256" || f == "size" {
+				e.Change = "modified"
+			}
+		}
+		e.Before, e.After = &old, meta
+		m.baseline[name] = *meta
+	}
+
+	if len(m.pending) >= maxPending {
+		m.dropped++
+	} else {
+		m.pending = append(m.pending, e)
+	}
+	log.Info("monitored file changed", "file", name, "change", e.Change, "fields", e.Fields)
+	return true
+}
+
+// diff names the fields that differ between two baselines of a file.
+func diff(a, b models.FileMeta) []string {
+	var fields []string
+	if a.SHA256 != b.SHA256 {
+		fields = append(fields, "sha256")
+	}
+	if a.Size != b.Size {
+		fields = append(fields, "size")
+	}
+	if a.Mode != b.Mode {
+		fields = append(fields, "mode")
+	}
+	if a.UID != b.UID || a.Owner != b.Owner {
+		fields = append(fields, "owner")
+	}
+	if a.GID != b.GID || a.Group != b.Group {
+		fields = append(fields, "group")
+	}
+	if a.MTime != b.MTime {
+		fields = append(fields, "mtime")
+	}
+	return fields
+}
+
+// scanAll stats every existing monitored file. A file that cannot be read
+// keeps its baseline rather than being reported as deleted.
+func (m *Monitor) scanAll() map[string]*models.FileMeta {
+	files := map[string]*models.FileMeta{}
+	for _, pattern := range m.cfg.Paths {
+		matches, err := filepath.Glob(m.hostPath(pattern))
+		if err != nil {
+			log.Warn("bad monitored path pattern", "pattern", pattern, "err", err)
+			continue
+		}
+		for _, full := range matches {
+			name := m.hostName(full)
+			if _, seen := files[name]; seen {
+				continue
+			}
+			meta, err := statFile(full, name, m.cfg.MaxHashBytes)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					log.Debug("stat monitored file failed", "file", name, "err", err)
+					if old, ok := m.baseline[name]; ok {
+						files[name] = &old
+					}
+				}
+				continue
+			}
+			if meta != nil {
+				files[name] = meta
+			}
+		}
+	}
+	return files
+}
+
+// monitored reports whether a host path is one of the configured files or
+// matches one of the globs.
+func (m *Monitor) monitored(name string) bool {
+	for _, pattern := range m.cfg.Paths {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// watchDirs watches the directories holding the monitored files, so files
+// replaced by rename or created later are seen too.
+func (m *Monitor) watchDirs() {
+	dirs := map[string]bool{}
+	for _, pattern := range m.cfg.Paths {
+		dirs[filepath.Dir(m.hostPath(pattern))] = true
+	}
+	for dir := range dirs {
+		if strings.ContainsAny(dir, "*?[") {
+			matches, _ := filepath.Glob(dir)
+			for _, d := range matches {
+				m.watcher.add(d)
+			}
+			continue
+		}
+		m.watcher.add(dir)
+	}
+}
+
+func (m *Monitor) atRoot() bool {
+	return m.root == "" || m.root == "/"
+}
+
+// hostPath maps a configured path onto the host root. Paths are used as
+// they are when the root is "/", which keeps Windows paths intact.
+func (m *Monitor) hostPath(p string) string {
+	if m.atRoot() {
+		return p
+	}
+	return filepath.Join(m.root, p)
+}
+
+// hostName is the inverse of hostPath.
+func (m *Monitor) hostName(full string) string {
+	if m.atRoot() {
+		return full
+	}
+	rel, _ := filepath.Rel(m.root, full)
+	return "/" + filepath.ToSlash(rel)
+}
+
+func (m *Monitor) load() {
+	data, err := os.ReadFile(m.baselinePath)
+	if err != nil {
+		return
+	}
+	var files []models.FileMeta
+	if err := json.Unmarshal(data, &files); err != nil {
+		log.Warn("ignoring corrupt file integrity baseline", "err", err)
+		return
+	}
+	m.baseline = make(map[string]models.FileMeta, len(files))
+	for _, f := range files {
+		m.baseline[f.Path] = f
+	}
+}
+
+func (m *Monitor) save() {
+	files := make([]models.FileMeta, 0, len(m.baseline))
+	for _, f := range m.baseline {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	data, err := json.Marshal(files)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(m.baselinePath), 0700)
+	if err := os.WriteFile(m.baselinePath, data, 0600); err != nil {
+		log.Error("failed to save file integrity baseline", "err", err)
+	}
+}
@@ -0,0 +1,80 @@
+package fim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"iDevopzAgent/models"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// unixMode returns the permission bits as chmod writes them, with setuid,
+// setgid and sticky at 04000, 02000 and 01000 rather than Go's own bits.
+func unixMode(m os.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+	return mode
+}
+
+// statFile reads the baseline of the file at full, recorded under name. It
+// returns nil for directories and other non-regular files. Symlinks are
+// followed.
+func statFile(full, name string, maxHash int64) (*models.FileMeta, error) {
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	meta := &models.FileMeta{
+		Path:  name,
+		Size:  info.Size(),
+		Mode:  fmt.Sprintf("%#o", unixMode(info.Mode())),
+		MTime: info.ModTime().Unix(),
+	}
+	if uid, gid, ok := fileOwner(info); ok {
+		meta.UID, meta.GID = uid, gid
+		if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+			meta.Owner = u.Username
+		}
+		if g, err := user.LookupGroupId(strconv.Itoa(gid)); err == nil {
+			meta.Group = g.Name
+		}
+	}
+
+	if maxHash <= 0 || info.Size() <= maxHash {
+		sum, err := hashFile(full)
+		if err != nil {
+			return nil, err
+		}
+		meta.SHA256 = sum
+	}
+	return meta, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package fim
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid), true
+	}
+	return 0, 0, false
+}
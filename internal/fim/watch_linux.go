@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+package fim
+
+import (
+	"encoding/binary"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CLOSE_WRITE | unix.IN_ATTRIB | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// watcher reports changes in the watched directories through inotify.
+// fn is called with the directory and the file name, or with overflow set
+// when the kernel dropped events.
+type watcher struct {
+	fd      int
+	fn      func(dir, name string, overflow bool)
+	mu      sync.Mutex
+	dirs    map[int]string
+	stopped atomic.Bool
+}
+
+func newWatcher(fn func(dir, name string, overflow bool)) (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return &watcher{fd: fd, fn: fn, dirs: map[int]string{}}, nil
+}
+
+// add watches dir. Adding a directory twice is harmless; a missing one is
+// picked up by a later call once it exists.
+func (w *watcher) add(dir string) {
+	wd, err := unix.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Debug("watch directory failed", "dir", dir, "err", err)
+		}
+		return
+	}
+	w.mu.Lock()
+	w.dirs[wd] = dir
+	w.mu.Unlock()
+}
+
+// failed reports whether the watcher stopped reading events.
+func (w *watcher) failed() bool {
+	return w.stopped.Load()
+}
+
+// run reads events until reading fails.
+func (w *watcher) run() {
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := unix.Read(w.fd, buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			log.Error("file watcher stopped, relying on rescans", "err", err)
+			w.stopped.Store(true)
+			unix.Close(w.fd)
+			return
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[off:])))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			start := off + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[start:min(start+nameLen, n)]), "\x00")
+			off = start + nameLen
+
+			switch {
+			case mask&unix.IN_Q_OVERFLOW != 0:
+				w.fn("", "", true)
+				continue
+			case mask&unix.IN_IGNORED != 0:
+				w.mu.Lock()
+				delete(w.dirs, wd)
+				w.mu.Unlock()
+				continue
+			}
+			w.mu.Lock()
+			dir := w.dirs[wd]
+			w.mu.Unlock()
+			if dir != "" && name != "" {
+				w.fn(dir, name, false)
+			}
+		}
+	}
+}
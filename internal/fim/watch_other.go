@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package fim
+
+import "errors"
+
+// watcher is only implemented with inotify; elsewhere changes are found by
+// the rescans.
+type watcher struct{}
+
+func newWatcher(fn func(dir, name string, overflow bool)) (*watcher, error) {
+	return nil, errors.New("not supported on this platform")
+}
+
+func (w *watcher) add(dir string) {}
+
+func (w *watcher) failed() bool { return true }
+
+func (w *watcher) run() {}
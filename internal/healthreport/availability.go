@@ -0,0 +1,263 @@
+package healthreport
+
+import (
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/internal/remote"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var log = logging.For("healthreport")
+
+// Outage reasons recorded by the tracker.
+const (
+	reasonAgentDown        = "agent_down"
+	reasonReboot           = "reboot"
+	reasonResourceCritical = "resource_critical"
+)
+
+const (
+	stateRetention = 30 * 24 * time.Hour
+
+	// bootTimeTolerance absorbs the jitter of the boot time derived from
+	// uptime, so only a real reboot moves it further.
+	bootTimeTolerance = 5
+
+	// missedHeartbeats is how many health_report intervals may pass before
+	// the agent counts as down.
+	missedHeartbeats = 3
+)
+
+var reportWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// outage is a closed window of unavailability, in unix seconds.
+type outage struct {
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Reason string `json:"reason"`
+}
+
+// availabilityState is persisted to health_state.json so availability
+// survives agent restarts.
+type availabilityState struct {
+	FirstSeen     int64    `json:"first_seen"`
+	LastHeartbeat int64    `json:"last_heartbeat"`
+	BootTime      uint64   `json:"boot_time"`
+	Interval      int64    `json:"interval,omitempty"`
+	CriticalSince int64    `json:"critical_since,omitempty"`
+	Outages       []outage `json:"outages"`
+}
+
+type availabilityTracker struct {
+	mu     sync.Mutex
+	path   string
+	loaded bool
+	state  availabilityState
+}
+
+var tracker = &availabilityTracker{
+	path: filepath.Join(configs.DataDir(), "health_state.json"),
+}
+
+func (t *availabilityTracker) load() {
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &t.state); err != nil {
+		log.Warn("ignoring corrupt health state", "err", err)
+		t.state = availabilityState{}
+	}
+}
+
+func (t *availabilityTracker) save() {
+	data, err := json.Marshal(t.state)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(t.path), 0700)
+	if err := os.WriteFile(t.path, data, 0600); err != nil {
+		log.Error("failed to save health state", "err", err)
+	}
+}
+
+// Record registers one health check at now. Heartbeat gaps and boot time
+// changes close an outage window; resource-critical periods are tracked
+// from the first unhealthy check until the next healthy one.
+func (t *availabilityTracker) Record(now time.Time, bootTime uint64, resourceHealthy bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+
+	settings := configs.LoadSettings()
+	ts := now.Unix()
+	st := &t.state
+
+	if st.FirstSeen == 0 {
+		st.FirstSeen = ts
+	}
+
+	interval := int64(remote.JobInterval("health_report") / time.Second)
+	if st.LastHeartbeat > 0 {
+		rebooted := st.BootTime != 0 && bootTime != 0 && absDiff(bootTime, st.BootTime) > bootTimeTolerance
+		gap := ts - st.LastHeartbeat
+		// the interval may have been changed remotely since the last
+		// heartbeat, so the longer of the two applies
+		missed := gap > max(int64(settings.HeartbeatGapSeconds), missedHeartbeats*max(interval, st.Interval))
+		switch {
+		case rebooted:
+			st.Outages = append(st.Outages, outage{Start: st.LastHeartbeat, End: ts, Reason: reasonReboot})
+		case missed:
+			st.Outages = append(st.Outages, outage{Start: st.LastHeartbeat, End: ts, Reason: reasonAgentDown})
+		}
+
+		// an open critical window cannot span a period we did not observe
+		if st.CriticalSince != 0 && (rebooted || missed) {
+			if st.LastHeartbeat > st.CriticalSince {
+				st.Outages = append(st.Outages, outage{Start: st.CriticalSince, End: st.LastHeartbeat, Reason: reasonResourceCritical})
+			}
+			st.CriticalSince = 0
+		}
+	}
+
+	if !resourceHealthy && st.CriticalSince == 0 {
+		st.CriticalSince = ts
+	} else if resourceHealthy && st.CriticalSince != 0 {
+		st.Outages = append(st.Outages, outage{Start: st.CriticalSince, End: ts, Reason: reasonResourceCritical})
+		st.CriticalSince = 0
+	}
+
+	st.LastHeartbeat = ts
+	st.BootTime = bootTime
+	st.Interval = interval
+
+	cutoff := now.Add(-stateRetention).Unix()
+	kept := st.Outages[:0]
+	for _, o := range st.Outages {
+		if o.End > cutoff {
+			kept = append(kept, o)
+		}
+	}
+	st.Outages = kept
+
+	t.save()
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// Windows computes availability and SLA over the rolling report windows.
+// Availability only counts host/agent outages; SLA also counts
+// resource-critical periods.
+func (t *availabilityTracker) Windows(now time.Time) []models.AvailabilityWindow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+
+	settings := configs.LoadSettings()
+	ts := now.Unix()
+	st := t.state
+
+	all := append([]outage(nil), st.Outages...)
+	if st.CriticalSince != 0 {
+		all = append(all, outage{Start: st.CriticalSince, End: ts, Reason: reasonResourceCritical})
+	}
+
+	var result []models.AvailabilityWindow
+	for _, w := range reportWindows {
+		start := now.Add(-w.duration).Unix()
+		if st.FirstSeen > start {
+			start = st.FirstSeen
+		}
+		observed := ts - start
+
+		var hostOutages []outage
+		count := 0
+		for _, o := range all {
+			if o.End <= start || o.Start >= ts {
+				continue
+			}
+			count++
+			if o.Reason != reasonResourceCritical {
+				hostOutages = append(hostOutages, o)
+			}
+		}
+		downtime := overlapSeconds(hostOutages, start, ts)
+		degraded := overlapSeconds(all, start, ts)
+
+		availability, sla := 100.0, 100.0
+		if observed > 0 {
+			availability = 100 * float64(observed-downtime) / float64(observed)
+			sla = 100 * float64(observed-degraded) / float64(observed)
+		}
+
+		target, ok := settings.SLATargets[w.name]
+		if !ok {
+			target = 99.9
+		}
+
+		result = append(result, models.AvailabilityWindow{
+			Window:          w.name,
+			Availability:    availability,
+			SLA:             sla,
+			SLATarget:       target,
+			SLAMet:          sla >= target,
+			DowntimeSeconds: downtime,
+			Outages:         count,
+		})
+	}
+	return result
+}
+
+// overlapSeconds returns how many seconds of [start, end) are covered by
+// outages, counting overlapping windows once.
+func overlapSeconds(outages []outage, start, end int64) int64 {
+	clipped := make([]outage, 0, len(outages))
+	for _, o := range outages {
+		s, e := o.Start, o.End
+		if s < start {
+			s = start
+		}
+		if e > end {
+			e = end
+		}
+		if e > s {
+			clipped = append(clipped, outage{Start: s, End: e})
+		}
+	}
+	sort.Slice(clipped, func(i, j int) bool { return clipped[i].Start < clipped[j].Start })
+
+	var total, curStart, curEnd int64
+	for i, o := range clipped {
+		if i == 0 || o.Start > curEnd {
+			total += curEnd - curStart
+			curStart, curEnd = o.Start, o.End
+		} else if o.End > curEnd {
+			curEnd = o.End
+		}
+	}
+	total += curEnd - curStart
+	return total
+}
@@ -5,6 +5,7 @@ package healthreport
 
 import (
 	"fmt"
+	"iDevopzAgent/internal/alerting"
 	"iDevopzAgent/internal/utils"
 	"iDevopzAgent/models"
 	"math"
@@ -13,26 +14,17 @@ import (
 
 type WindowsCollector struct{}
 
-var (
-	totalChecks     int
-	resourceHealthy int
-	totalUptimeOk   int
-)
-
 func (l WindowsCollector) GenerateHealthReport(userId string, machineId string) (*models.HealthReport, error) {
-	totalChecks++
-
 	// --- Get system values ---
 	hostname, err := utils.GetHostName()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get hostname: %v", err)
 	}
 
-	uptimeSeconds, err := utils.GetUptime()
+	bootTime, err := utils.GetBootTime()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get uptime: %v", err)
+		return nil, fmt.Errorf("failed to get boot time: %v", err)
 	}
-	uptime := time.Duration(uptimeSeconds) * time.Second
 
 	// --- Get metrics ---
 	cpuPercent, err := utils.GetCPUPercentage()
@@ -53,33 +45,27 @@ func (l WindowsCollector) GenerateHealthReport(userId string, machineId string)
 	}
 
 	// --- Evaluation ---
-	const threshold = 95.0
-	isResourceHealthy := cpuPercent < threshold && memPercent < threshold && diskPercent < threshold
-	isUptimeHealthy := uptime > 24*time.Hour
-
-	if isResourceHealthy {
-		resourceHealthy++
-	}
-	if isUptimeHealthy {
-		totalUptimeOk++
-	}
+	isResourceHealthy := alerting.GetEngine().ResourceHealthy(cpuPercent, memPercent, diskPercent)
 
-	availability := (float64(totalUptimeOk) / float64(totalChecks)) * 100
-	sla := (float64(resourceHealthy) / float64(totalChecks)) * 100
-	utcNow := time.Now().UTC().Format(time.RFC3339)
+	now := time.Now()
+	tracker.Record(now, bootTime, isResourceHealthy)
+	windows := tracker.Windows(now)
+	day := windows[0]
+	utcNow := now.UTC().Format(time.RFC3339)
 
 	// --- Build Report ---
 	report := &models.HealthReport{
 		UserID:        userId,
 		MachineID:     machineId,
 		Hostname:      hostname,
-		Availability:  fmt.Sprintf("%.1f %%", availability),
+		Availability:  fmt.Sprintf("%.1f %%", day.Availability),
 		CPUPercent:    math.Round(cpuPercent*100) / 100,
 		MemoryPercent: math.Round(memPercent*100) / 100,
 		DiskPercent:   math.Round(diskPercent*100) / 100,
-		Downtimes:     totalChecks - resourceHealthy,
+		Downtimes:     day.Outages,
 		MetricGetTime: utcNow,
-		SLA:           fmt.Sprintf("%.2f %%", sla),
+		SLA:           fmt.Sprintf("%.2f %%", day.SLA),
+		Windows:       windows,
 	}
 
 	return report, nil
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package hostfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// Inode returns the inode number of the file, which stays the same across
+// renames, so a rotated log can be told apart from a new one.
+func Inode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
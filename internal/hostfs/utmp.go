@@ -0,0 +1,72 @@
+// Package hostfs holds the low-level readers of host files shared by the
+// log tailer, the auth monitor and the system inventory.
+package hostfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+)
+
+// utmp record layout shared by glibc's 32 and 64-bit ABIs.
+const (
+	UtmpSize = 384
+
+	// record types
+	UtmpRunLevel    = 1
+	UtmpBootTime    = 2
+	UtmpUserProcess = 7
+
+	utmpLineOff = 8
+	utmpLineLen = 32
+	utmpUserOff = 44
+	utmpUserLen = 32
+	utmpHostOff = 76
+	utmpHostLen = 256
+	utmpTimeOff = 340
+	utmpAddrOff = 348
+)
+
+// UtmpRecord is the part of a utmp, wtmp or btmp record the agent uses.
+type UtmpRecord struct {
+	Type uint16
+	Line string
+	User string
+	Host string
+	IP   string
+	Time time.Time
+}
+
+// ParseUtmp decodes one record of UtmpSize bytes.
+func ParseUtmp(rec []byte) UtmpRecord {
+	r := UtmpRecord{
+		Type: binary.LittleEndian.Uint16(rec[0:2]),
+		Line: cString(rec[utmpLineOff : utmpLineOff+utmpLineLen]),
+		User: cString(rec[utmpUserOff : utmpUserOff+utmpUserLen]),
+		Host: cString(rec[utmpHostOff : utmpHostOff+utmpHostLen]),
+		Time: time.Unix(int64(binary.LittleEndian.Uint32(rec[utmpTimeOff:])), 0),
+	}
+
+	addr := rec[utmpAddrOff : utmpAddrOff+16]
+	switch {
+	case bytes.Equal(addr[4:], make([]byte, 12)) && !bytes.Equal(addr[:4], make([]byte, 4)):
+		r.IP = net.IP(addr[:4]).String()
+	case !bytes.Equal(addr, make([]byte, 16)):
+		r.IP = net.IP(addr).String()
+	default:
+		// no address recorded; the host field often holds the IP
+		if ip := net.ParseIP(r.Host); ip != nil {
+			r.IP = ip.String()
+		}
+	}
+	return r
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
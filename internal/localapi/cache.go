@@ -0,0 +1,32 @@
+package localapi
+
+import (
+	"sync"
+	"time"
+)
+
+// entry is the result of the latest collection cycle for one kind of data.
+type entry struct {
+	value       any
+	collectedAt time.Time
+}
+
+var (
+	cacheMu sync.RWMutex
+	cache   = map[string]entry{}
+)
+
+// Record stores the latest result for kind. The collector loops call it
+// with the same value they send, so the API never collects on its own.
+func Record(kind string, value any) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cache[kind] = entry{value: value, collectedAt: time.Now()}
+}
+
+func latest(kind string) (entry, bool) {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	e, ok := cache[kind]
+	return e, ok
+}
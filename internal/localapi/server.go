@@ -0,0 +1,160 @@
+package localapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"iDevopzAgent/internal/alerting"
+	"iDevopzAgent/internal/logging"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var log = logging.For("localapi")
+
+// routes maps each API path to the cached kind it serves.
+var routes = map[string]string{
+	"/v1/metrics":              "metrics",
+	"/v1/health":               "health_report",
+	"/v1/system":               "system_info",
+	"/v1/inventory":            "inventory",
+	"/v1/packages":             "packages",
+	"/v1/sockets":              "sockets",
+	"/v1/logs":                 "logs",
+	"/v1/auth":                 "auth",
+	"/v1/files/changes":        "file_changes",
+	"/v1/processes":            "processes",
+	"/v1/processes/groups":     "process_groups",
+	"/v1/processes/top-cpu":    "top_cpu",
+	"/v1/processes/top-memory": "top_memory",
+}
+
+// Serve starts the read-only API on listen, a host:port or "unix:<path>".
+// Addresses reachable from other hosts are refused without a token.
+func Serve(listen, token string) error {
+	ln, err := Listen(listen, token)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	for path, kind := range routes {
+		mux.HandleFunc(path, handleCached(kind))
+	}
+	mux.HandleFunc("/v1/alerts", handleAlerts)
+	mux.HandleFunc("/v1", handleIndex)
+
+	srv := &http.Server{
+		Handler:           ReadOnly(token, mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			log.Error("local api stopped", "err", err)
+		}
+	}()
+	log.Info("local api started", "addr", listen, "auth", token != "")
+	return nil
+}
+
+// Listen binds a local listener on a host:port or "unix:<path>". A TCP
+// address other than loopback is refused without a token, so nothing is
+// exposed to the network unauthenticated.
+func Listen(listen, token string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(listen, "unix:"); ok {
+		// a socket left behind by a previous run blocks the bind
+		os.Remove(path)
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			ln.Close()
+			return nil, err
+		}
+		return ln, nil
+	}
+
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, err
+	}
+	if !isLoopback(host) && token == "" {
+		return nil, errors.New("listening on a non-loopback address requires a token")
+	}
+	return net.Listen("tcp", listen)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ReadOnly rejects anything but GET and HEAD and checks the bearer token
+// when one is configured.
+func ReadOnly(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, http.StatusMethodNotAllowed, "read-only api")
+			return
+		}
+		if token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, "missing or invalid token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func handleCached(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e, ok := latest(kind)
+		if !ok {
+			writeError(w, http.StatusServiceUnavailable, "no "+kind+" collected yet")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"collected_at": e.collectedAt.Unix(),
+			"data":         e.value,
+		})
+	}
+}
+
+func handleAlerts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data": alerting.GetEngine().Active(),
+	})
+}
+
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	paths := []string{"/v1/alerts"}
+	for path := range routes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	writeJSON(w, http.StatusOK, map[string]any{"endpoints": paths})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,164 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Options configures the process-wide logger.
+type Options struct {
+	Dir        string            // directory for logs.txt; empty disables the file
+	Format     string            // text (default) or json
+	Level      string            // debug, info (default), warn, error
+	Levels     map[string]string // per-component overrides of Level
+	MaxSizeMB  int               // rotate logs.txt at this size
+	MaxBackups int               // rotated files kept
+	Stdout     bool              // also write to stdout (journald, console)
+}
+
+var (
+	mu           sync.RWMutex
+	base         slog.Handler = newHandler(os.Stdout, "text")
+	defaultLevel              = slog.LevelInfo
+	levels                    = map[string]slog.Level{}
+	file         *RotatingFile
+)
+
+// Setup installs the logger described by opts. Loggers returned by For
+// before Setup switch over to it.
+func Setup(opts Options) error {
+	level, err := parseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	perComponent := make(map[string]slog.Level, len(opts.Levels))
+	for component, l := range opts.Levels {
+		if perComponent[component], err = parseLevel(l); err != nil {
+			return fmt.Errorf("level for %s: %w", component, err)
+		}
+	}
+	if opts.Format != "" && opts.Format != "text" && opts.Format != "json" {
+		return fmt.Errorf("log format %q is not supported, use text or json", opts.Format)
+	}
+
+	var writers []io.Writer
+	var rf *RotatingFile
+	if opts.Dir != "" {
+		rf, err = OpenRotatingFile(filepath.Join(opts.Dir, "logs.txt"), int64(opts.MaxSizeMB)<<20, opts.MaxBackups)
+		if err != nil {
+			return err
+		}
+		writers = append(writers, rf)
+	}
+	if opts.Stdout || len(writers) == 0 {
+		writers = append(writers, os.Stdout)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		file.Close()
+	}
+	file = rf
+	base = newHandler(io.MultiWriter(writers...), opts.Format)
+	defaultLevel = level
+	levels = perComponent
+	return nil
+}
+
+// Rotate starts a new log file now, as if the size limit had been reached.
+func Rotate() error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if file == nil {
+		return fmt.Errorf("logging to a file is not enabled")
+	}
+	return file.Rotate()
+}
+
+// For returns the logger of a component. Every record carries the
+// component name and is filtered by its level.
+func For(component string) *slog.Logger {
+	return slog.New(&componentHandler{component: component})
+}
+
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: redact}
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return l, fmt.Errorf("log level %q is not one of debug, info, warn, error", s)
+	}
+	return l, nil
+}
+
+// secretKeys are attribute key fragments whose values are never written.
+// Log a secret under one of these keys, or not at all.
+var secretKeys = []string{"token", "password", "passwd", "secret", "authorization", "api_key", "apikey", "device_key", "private_key", "credential", "cookie"}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ReplaceAll(strings.ToLower(a.Key), "-", "_")
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return slog.String(a.Key, "[REDACTED]")
+		}
+	}
+	return a
+}
+
+// componentHandler resolves the installed handler for every record, so
+// package-level loggers created before Setup still follow it.
+type componentHandler struct {
+	component string
+	ops       []func(slog.Handler) slog.Handler
+}
+
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	min, ok := levels[h.component]
+	if !ok {
+		min = defaultLevel
+	}
+	return level >= min
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	mu.RLock()
+	inner := base
+	mu.RUnlock()
+
+	inner = inner.WithAttrs([]slog.Attr{slog.String("component", h.component)})
+	for _, op := range h.ops {
+		inner = op(inner)
+	}
+	return inner.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(inner slog.Handler) slog.Handler { return inner.WithAttrs(attrs) })
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(inner slog.Handler) slog.Handler { return inner.WithGroup(name) })
+}
+
+func (h *componentHandler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := append(h.ops[:len(h.ops):len(h.ops)], op)
+	return &componentHandler{component: h.component, ops: ops}
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.Writer that renames the file to path.1 (shifting
+// older backups up to path.N) once it grows past the size limit.
+type RotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+// OpenRotatingFile appends to path, rotating at maxSize bytes and keeping
+// backups old files. A maxSize of 0 disables size-based rotation.
+func OpenRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.openLocked(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) openLocked() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file aside and starts a new one.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotateLocked()
+}
+
+func (r *RotatingFile) rotateLocked() error {
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+
+	if r.backups <= 0 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.backups))
+		for i := r.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+			// keep logging to the old file rather than losing output
+			if oerr := r.openLocked(); oerr != nil {
+				return oerr
+			}
+			return err
+		}
+	}
+	return r.openLocked()
+}
+
+// Close closes the current file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
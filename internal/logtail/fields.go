@@ -0,0 +1,133 @@
+package logtail
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// isoTime matches a leading ISO 8601 timestamp, with a comma or dot
+	// before the fraction as Python and Java loggers write it.
+	isoTime = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?)\]?\s*`)
+	// syslogTime matches the leading "Jan _2 15:04:05" of RFC 3164 syslog.
+	syslogTime = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})\s+`)
+
+	levelWord = regexp.MustCompile(`(?i)\b(trace|debug|info|notice|warn|warning|err|error|fatal|crit|critical|alert|emerg|panic)\b`)
+)
+
+var isoLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// normalLevels maps level spellings onto the names used in reports.
+var normalLevels = map[string]string{
+	"warn":  "warning",
+	"err":   "error",
+	"crit":  "critical",
+	"emerg": "critical",
+	"alert": "critical",
+	"panic": "critical",
+	"fatal": "critical",
+}
+
+// extracted holds the fields taken from the first line of a record.
+type extracted struct {
+	time   time.Time
+	level  string
+	msg    string
+	fields map[string]string
+}
+
+// extractFields fills the time, level and message of a record from its
+// first line, through format's named groups when set and by guessing
+// otherwise. now anchors the year of syslog timestamps, which have none.
+func extractFields(line string, format *regexp.Regexp, layout string, now time.Time) extracted {
+	if format != nil {
+		m := format.FindStringSubmatch(line)
+		if m != nil {
+			e := extracted{msg: line}
+			for i, name := range format.SubexpNames() {
+				switch name {
+				case "":
+				case "time":
+					e.time = parseTime(m[i], layout, now)
+				case "level":
+					e.level = normalLevel(m[i])
+				case "msg":
+					e.msg = m[i]
+				default:
+					if e.fields == nil {
+						e.fields = map[string]string{}
+					}
+					e.fields[name] = m[i]
+				}
+			}
+			return e
+		}
+	}
+
+	e := extracted{msg: line}
+	if m := isoTime.FindStringSubmatch(line); m != nil {
+		e.time = parseTime(m[1], layout, now)
+		e.msg = line[len(m[0]):]
+	} else if m := syslogTime.FindStringSubmatch(line); m != nil {
+		e.time = parseTime(m[1], layout, now)
+		e.msg = line[len(m[0]):]
+	}
+	if m := levelWord.FindString(e.msg); m != "" {
+		e.level = normalLevel(m)
+	}
+	return e
+}
+
+func normalLevel(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if n, ok := normalLevels[s]; ok {
+		return n
+	}
+	return s
+}
+
+// parseTime parses s with layout, or with the known ISO and syslog layouts
+// when layout is empty. Times without a zone are local. It returns the zero
+// time when s does not parse.
+func parseTime(s, layout string, now time.Time) time.Time {
+	if layout != "" {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			return time.Time{}
+		}
+		return withYear(t, now)
+	}
+
+	iso := strings.Replace(s, ",", ".", 1)
+	for _, l := range isoLayouts {
+		if t, err := time.ParseInLocation(l, iso, time.Local); err == nil {
+			return t
+		}
+	}
+	if t, err := time.ParseInLocation(time.Stamp, s, time.Local); err == nil {
+		return withYear(t, now)
+	}
+	return time.Time{}
+}
+
+// withYear gives a time parsed without a year the year of now, or the
+// previous one when that would put it more than a day in the future
+// (lines from late December read in January).
+func withYear(t, now time.Time) time.Time {
+	if t.Year() != 0 {
+		return t
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
@@ -0,0 +1,285 @@
+package logtail
+
+import (
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// multilineIdle is how long a multiline record waits for more lines once
+// its file went quiet.
+const multilineIdle = 2 * pollInterval
+
+// pendingRecord is a record still collecting continuation lines.
+type pendingRecord struct {
+	file  string
+	text  strings.Builder
+	first string
+	at    time.Time // when the first line was read
+	last  time.Time // when the last line was read
+}
+
+type forwardSource struct {
+	cfg       configs.LogForwardSource
+	include   *regexp.Regexp
+	multiline *regexp.Regexp
+	format    *regexp.Regexp
+
+	// token bucket holding up to one second of RateLimit
+	tokens float64
+	filled time.Time
+
+	pending map[string]*pendingRecord // by file
+}
+
+// Forwarder turns the lines of the forwarded files into records and queues
+// them for upload. The queue is bounded: when it is full, or a source is
+// over its rate limit, offer refuses the line and the tailer stops
+// advancing that file until the next poll, so nothing is dropped as long
+// as the rotated file is not removed or truncated meanwhile. Bytes lost
+// that way are counted in DroppedBytes.
+type Forwarder struct {
+	mu        sync.Mutex
+	enabled   bool
+	batchSize int
+	maxQueue  int
+	maxRecord int
+	sources   []*forwardSource
+	byFile    map[string]*forwardSource
+
+	queue     []models.LogRecord
+	throttled int
+	dropped   int64 // bytes never forwarded, since start
+}
+
+func newForwarder(cfg configs.LogForwardSettings) *Forwarder {
+	f := &Forwarder{
+		enabled:   cfg.Enabled,
+		batchSize: cfg.BatchRecords,
+		maxQueue:  cfg.MaxBuffered,
+		maxRecord: cfg.MaxRecordBytes,
+		byFile:    map[string]*forwardSource{},
+	}
+	if f.batchSize <= 0 {
+		f.batchSize = 500
+	}
+	if f.maxQueue < f.batchSize {
+		f.maxQueue = f.batchSize
+	}
+	if f.maxRecord <= 0 {
+		f.maxRecord = 16 << 10
+	}
+	if !cfg.Enabled {
+		return f
+	}
+
+	for _, sc := range cfg.Sources {
+		src := &forwardSource{cfg: sc, pending: map[string]*pendingRecord{}}
+		var err error
+		for _, p := range []struct {
+			expr string
+			re   **regexp.Regexp
+		}{{sc.Include, &src.include}, {sc.Multiline, &src.multiline}, {sc.Format, &src.format}} {
+			if p.expr == "" {
+				continue
+			}
+			if *p.re, err = regexp.Compile(p.expr); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Warn("skipping log forward source with bad pattern", "source", sc.Name, "err", err)
+			continue
+		}
+		f.sources = append(f.sources, src)
+	}
+	return f
+}
+
+// patterns returns the file patterns of all sources, for the tailer.
+func (f *Forwarder) patterns() []string {
+	var patterns []string
+	for _, src := range f.sources {
+		patterns = append(patterns, src.cfg.Files...)
+	}
+	return patterns
+}
+
+// source returns the source forwarding the file name, if any. The first
+// source whose pattern matches wins.
+func (f *Forwarder) source(name string) *forwardSource {
+	if src, ok := f.byFile[name]; ok {
+		return src
+	}
+	var found *forwardSource
+	for _, src := range f.sources {
+		for _, p := range src.cfg.Files {
+			if ok, _ := filepath.Match(p, name); ok {
+				found = src
+				break
+			}
+		}
+		if found != nil {
+			break
+		}
+	}
+	f.byFile[name] = found
+	return found
+}
+
+// offer hands a line of file name to its source. It returns false when the
+// line cannot be taken now; the caller must offer it again later.
+func (f *Forwarder) offer(name string, line []byte) bool {
+	if len(f.sources) == 0 {
+		return true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	src := f.source(name)
+	if src == nil {
+		return true
+	}
+	now := time.Now()
+
+	p := src.pending[name]
+	if src.multiline != nil && p != nil && !src.multiline.Match(line) {
+		if room := f.maxRecord - p.text.Len() - 1; room > 0 {
+			p.text.WriteByte('\n')
+			p.text.Write(line[:min(len(line), room)])
+		}
+		p.last = now
+		return true
+	}
+
+	if p != nil && !f.emitLocked(src, p, now) {
+		return false
+	}
+	p = &pendingRecord{file: name, first: string(line[:min(len(line), f.maxRecord)]), at: now, last: now}
+	p.text.WriteString(p.first)
+	src.pending[name] = p
+	if src.multiline == nil {
+		// a refused single line stays pending and holds the next one back
+		f.emitLocked(src, p, now)
+	}
+	return true
+}
+
+// emitLocked moves the pending record p of src to the queue, unless the
+// queue is full or the source is over its rate.
+func (f *Forwarder) emitLocked(src *forwardSource, p *pendingRecord, now time.Time) bool {
+	text := p.text.String()
+	if src.include != nil && !src.include.MatchString(text) {
+		delete(src.pending, p.file)
+		return true
+	}
+	if len(f.queue) >= f.maxQueue {
+		return false
+	}
+	if !src.allow(now) {
+		f.throttled++
+		return false
+	}
+
+	e := extractFields(p.first, src.format, src.cfg.TimeLayout, now)
+	rec := models.LogRecord{
+		Source:  src.cfg.Name,
+		File:    p.file,
+		Level:   e.level,
+		Message: e.msg + text[len(p.first):],
+		Fields:  e.fields,
+		ReadAt:  p.at.Unix(),
+	}
+	if !e.time.IsZero() {
+		rec.Time = e.time.UnixMilli()
+	}
+	f.queue = append(f.queue, rec)
+	delete(src.pending, p.file)
+	return true
+}
+
+// allow takes a token from the source's bucket, refilled at RateLimit per
+// second up to one second's worth.
+func (src *forwardSource) allow(now time.Time) bool {
+	limit := float64(src.cfg.RateLimit)
+	if limit <= 0 {
+		return true
+	}
+	if src.filled.IsZero() {
+		src.tokens = limit
+	} else {
+		src.tokens = min(limit, src.tokens+now.Sub(src.filled).Seconds()*limit)
+	}
+	src.filled = now
+	if src.tokens < 1 {
+		return false
+	}
+	src.tokens--
+	return true
+}
+
+// drop records bytes of a file that went away before they were forwarded.
+func (f *Forwarder) drop(bytes int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dropped += bytes
+}
+
+// DroppedBytes returns how many bytes of the forwarded files were lost
+// since start because the file was removed or truncated before the
+// forwarder caught up.
+func (f *Forwarder) DroppedBytes() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dropped
+}
+
+// Enabled reports whether any source forwards logs.
+func (f *Forwarder) Enabled() bool {
+	return f.enabled && len(f.sources) > 0
+}
+
+// Pending returns the oldest queued records, at most one batch, or nil
+// when the queue is empty. Multiline records whose file went quiet are
+// queued first. The records stay queued until Ack.
+func (f *Forwarder) Pending(userID, machineID, hostname string) *models.LogForwardBatch {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for _, src := range f.sources {
+		for _, p := range src.pending {
+			if now.Sub(p.last) >= multilineIdle {
+				f.emitLocked(src, p, now)
+			}
+		}
+	}
+	if f.throttled > 0 {
+		log.Debug("log forwarding throttled", "lines", f.throttled)
+		f.throttled = 0
+	}
+	if len(f.queue) == 0 {
+		return nil
+	}
+	n := min(len(f.queue), f.batchSize)
+	records := make([]models.LogRecord, n)
+	copy(records, f.queue[:n])
+	return &models.LogForwardBatch{
+		UserID:    userID,
+		MachineID: machineID,
+		Hostname:  hostname,
+		Records:   records,
+	}
+}
+
+// Ack removes the n oldest records after the backend accepted them.
+func (f *Forwarder) Ack(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n = min(n, len(f.queue))
+	f.queue = append(f.queue[:0], f.queue[n:]...)
+}
@@ -0,0 +1,313 @@
+package logtail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxEntriesPerPoll bounds the work per poll; a busy journal is caught
+	// up over the following polls.
+	maxEntriesPerPoll = 5000
+	journalTimeout    = 30 * time.Second
+
+	// journalSource is the source name journal entries are counted under
+	// in the log report.
+	journalSource = "journal"
+
+	priorityErr = 3
+)
+
+// journalFields are the export fields the reader keeps.
+var journalFields = map[string]bool{
+	"__CURSOR":             true,
+	"__REALTIME_TIMESTAMP": true,
+	"PRIORITY":             true,
+	"MESSAGE":              true,
+	"SYSLOG_IDENTIFIER":    true,
+	"_SYSTEMD_UNIT":        true,
+	"_PID":                 true,
+}
+
+// journalState is the persisted position in the journal.
+type journalState struct {
+	Cursor string `json:"cursor"`
+}
+
+// Journal reads the systemd journal through journalctl's export format,
+// resuming after the persisted cursor. The agent's journal feeds the same
+// rules as the tailed files and, when forwarding is on, a bounded buffer
+// drained by Forwarded; readers made by WatchJournal call their own
+// handler instead.
+type Journal struct {
+	mu        sync.Mutex
+	cfg       configs.JournalSettings
+	root      string
+	maxLine   int
+	statePath string
+	loaded    bool
+	state     journalState
+	since     time.Time
+
+	handle  func(entry map[string]string)
+	forward []models.JournalEntry
+	dropped int
+
+	rules *ruleSet
+}
+
+var (
+	journalOnce sync.Once
+	journal     *Journal
+)
+
+// GetJournal returns the process-wide journal reader. It shares the rules
+// and error count of GetTailer.
+func GetJournal() *Journal {
+	journalOnce.Do(func() {
+		settings := configs.LoadSettings()
+		t := GetTailer()
+		journal = &Journal{
+			cfg:       settings.LogTail.Journal,
+			root:      settings.HostRoot,
+			maxLine:   t.maxLine,
+			statePath: filepath.Join(configs.DataDir(), "journal_state.json"),
+			rules:     t.rules,
+		}
+		journal.cfg.Enabled = journal.cfg.Enabled && settings.LogTail.Enabled
+		journal.handle = journal.observe
+	})
+	return journal
+}
+
+// WatchJournal returns a journal reader of its own for the entries of the
+// given syslog identifiers, at every priority, which calls fn for each of
+// them once Run. name keeps its cursor apart from the other readers.
+func WatchJournal(name string, identifiers []string, fn func(models.JournalEntry)) *Journal {
+	settings := configs.LoadSettings()
+	j := &Journal{
+		cfg: configs.JournalSettings{
+			Enabled:     true,
+			MaxPriority: 7,
+			Identifiers: identifiers,
+		},
+		root:      settings.HostRoot,
+		maxLine:   GetTailer().maxLine,
+		statePath: filepath.Join(configs.DataDir(), "journal_"+name+"_state.json"),
+	}
+	j.handle = func(entry map[string]string) { fn(journalEntry(entry)) }
+	return j
+}
+
+// JournalAvailable reports whether journalctl is installed.
+func JournalAvailable() bool {
+	_, err := exec.LookPath("journalctl")
+	return err == nil
+}
+
+// Run polls the journal until the process exits. It returns at once when
+// the journal source is disabled or journalctl is not installed.
+func (j *Journal) Run() {
+	if !j.cfg.Enabled {
+		return
+	}
+	if !JournalAvailable() {
+		log.Debug("journalctl not found, journal source disabled")
+		return
+	}
+	for {
+		if err := j.Poll(); err != nil {
+			log.Warn("journal poll failed", "err", err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Poll reads the entries written since the last poll. Without a saved
+// cursor reading starts at the first poll, so existing history is not
+// reported as new.
+func (j *Journal) Poll() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.load()
+	if j.since.IsZero() {
+		j.since = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), journalTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "journalctl", j.args()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	r := bufio.NewReaderSize(stdout, readBufferSize)
+	read := 0
+	var readErr error
+	for read < maxEntriesPerPoll {
+		entry, err := readExportEntry(r, journalFields, j.maxLine)
+		if err != nil {
+			readErr = err
+			break
+		}
+		j.handle(entry)
+		if c := entry["__CURSOR"]; c != "" {
+			j.state.Cursor = c
+		}
+		read++
+	}
+	if read == maxEntriesPerPoll {
+		_ = cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+
+	if read > 0 {
+		j.save()
+	}
+	switch {
+	case read == maxEntriesPerPoll:
+		return nil
+	case waitErr != nil && read == 0 && j.state.Cursor != "" && strings.Contains(stderr.String(), "cursor"):
+		// the cursor pointed into a journal file that was vacuumed
+		log.Warn("journal cursor rejected, restarting from now", "stderr", strings.TrimSpace(stderr.String()))
+		j.state.Cursor = ""
+		j.since = time.Now()
+		j.save()
+		return nil
+	case waitErr != nil:
+		return fmt.Errorf("%w: %s", waitErr, strings.TrimSpace(stderr.String()))
+	case readErr != nil && !errors.Is(readErr, io.EOF):
+		return fmt.Errorf("read journal: %w", readErr)
+	}
+	return nil
+}
+
+// args builds the journalctl command line from the settings and the
+// current position.
+func (j *Journal) args() []string {
+	args := []string{"--output=export", "--no-pager", "--quiet",
+		"--priority=0.." + strconv.Itoa(j.cfg.MaxPriority)}
+	if j.root != "" && j.root != "/" {
+		args = append(args, "--directory="+filepath.Join(j.root, "var/log/journal"))
+	}
+	if j.state.Cursor != "" {
+		args = append(args, "--after-cursor="+j.state.Cursor)
+	} else {
+		args = append(args, "--since=@"+strconv.FormatInt(j.since.Unix(), 10))
+	}
+	for _, u := range j.cfg.Units {
+		args = append(args, "--unit="+u)
+	}
+	for _, id := range j.cfg.Identifiers {
+		args = append(args, "--identifier="+id)
+	}
+	return args
+}
+
+// journalEntry converts the exported fields of an entry.
+func journalEntry(entry map[string]string) models.JournalEntry {
+	prio, err := strconv.Atoi(entry["PRIORITY"])
+	if err != nil {
+		prio = 6 // info, the journal's default
+	}
+	ts, _ := strconv.ParseInt(entry["__REALTIME_TIMESTAMP"], 10, 64)
+	return models.JournalEntry{
+		Time:       ts,
+		Priority:   prio,
+		Unit:       entry["_SYSTEMD_UNIT"],
+		Identifier: entry["SYSLOG_IDENTIFIER"],
+		PID:        entry["_PID"],
+		Message:    entry["MESSAGE"],
+	}
+}
+
+// observe counts one entry. Its priority decides whether it is an error;
+// the rules only contribute counts and samples.
+func (j *Journal) observe(fields map[string]string) {
+	entry := journalEntry(fields)
+
+	source := journalSource
+	if entry.Unit != "" {
+		source += ":" + entry.Unit
+	}
+	j.rules.observe(source, []byte(entry.Message))
+	if entry.Priority <= priorityErr {
+		j.rules.errored.Add(1)
+	}
+
+	if !j.cfg.Forward {
+		return
+	}
+	if len(j.forward) >= j.cfg.MaxForwardPerReport {
+		j.dropped++
+		return
+	}
+	j.forward = append(j.forward, entry)
+}
+
+// Forwarded returns the entries buffered for forwarding since the previous
+// call, or nil when there are none.
+func (j *Journal) Forwarded(userID, machineID, hostname string) *models.JournalBatch {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.forward) == 0 && j.dropped == 0 {
+		return nil
+	}
+	b := &models.JournalBatch{
+		UserID:    userID,
+		MachineID: machineID,
+		Hostname:  hostname,
+		Entries:   j.forward,
+		Dropped:   j.dropped,
+	}
+	j.forward, j.dropped = nil, 0
+	return b
+}
+
+func (j *Journal) load() {
+	if j.loaded {
+		return
+	}
+	j.loaded = true
+
+	data, err := os.ReadFile(j.statePath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &j.state); err != nil {
+		log.Warn("ignoring corrupt journal state", "err", err)
+		j.state = journalState{}
+	}
+}
+
+func (j *Journal) save() {
+	data, err := json.Marshal(j.state)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(j.statePath), 0700)
+	if err := os.WriteFile(j.statePath, data, 0600); err != nil {
+		log.Error("failed to save journal state", "err", err)
+	}
+}
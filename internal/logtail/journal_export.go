@@ -0,0 +1,121 @@
+package logtail
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const maxFieldName = 256
+
+var errBadExport = errors.New("malformed journal export stream")
+
+// readExportEntry reads the next entry of a journal export stream
+// (journalctl -o export). Text fields are "KEY=value\n"; binary fields are
+// "KEY\n", a little-endian 64-bit length, the data and "\n". An empty line
+// ends the entry. Only the fields in keep are returned, each cut to
+// maxValue bytes, so a huge field costs no memory.
+//
+// It returns io.EOF at the end of the stream and io.ErrUnexpectedEOF when
+// the stream ends inside an entry.
+func readExportEntry(r *bufio.Reader, keep map[string]bool, maxValue int) (map[string]string, error) {
+	entry := map[string]string{}
+	fields := 0
+	for {
+		key, sep, err := readFieldName(r)
+		if err != nil {
+			if err == io.EOF && fields == 0 && len(key) == 0 {
+				return nil, io.EOF
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		if sep == '\n' && len(key) == 0 {
+			if fields == 0 {
+				continue // stray blank line between entries
+			}
+			return entry, nil
+		}
+		fields++
+
+		var value []byte
+		if sep == '=' {
+			value, err = readTextValue(r, maxValue)
+		} else {
+			value, err = readBinaryValue(r, maxValue)
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if keep == nil || keep[string(key)] {
+			entry[string(key)] = string(value)
+		}
+	}
+}
+
+// readFieldName reads up to the first '=' or newline and returns the name
+// and the separator.
+func readFieldName(r *bufio.Reader) ([]byte, byte, error) {
+	var key []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return key, 0, err
+		}
+		if b == '=' || b == '\n' {
+			return key, b, nil
+		}
+		if len(key) >= maxFieldName {
+			return nil, 0, errBadExport
+		}
+		key = append(key, b)
+	}
+}
+
+func readTextValue(r *bufio.Reader, maxValue int) ([]byte, error) {
+	var value []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if room := maxValue - len(value); room > 0 {
+			value = append(value, chunk[:min(len(chunk), room)]...)
+		}
+		switch err {
+		case nil:
+			return trimLine(value), nil
+		case bufio.ErrBufferFull:
+			continue
+		default:
+			return nil, err
+		}
+	}
+}
+
+func readBinaryValue(r *bufio.Reader, maxValue int) ([]byte, error) {
+	var size [8]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint64(size[:])
+
+	kept := min(n, uint64(max(maxValue, 0)))
+	value := make([]byte, kept)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, err
+	}
+	if _, err := r.Discard(int(n - kept)); err != nil {
+		return nil, err
+	}
+	if b, err := r.ReadByte(); err != nil {
+		return nil, err
+	} else if b != '\n' {
+		return nil, errBadExport
+	}
+	return value, nil
+}
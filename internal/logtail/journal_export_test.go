@@ -0,0 +1,116 @@
+package logtail
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// binaryField encodes a field in the export format's binary form.
+func binaryField(key, value string) string {
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	return key + "\n" + string(size[:]) + value + "\n"
+}
+
+func exportReader(stream string) *bufio.Reader {
+	// a small buffer makes long text values span several reads
+	return bufio.NewReaderSize(strings.NewReader(stream), 16)
+}
+
+func TestReadExportEntry(t *testing.T) {
+	stream := "__CURSOR=s=1;i=1\nPRIORITY=3\nMESSAGE=disk failure\n_HOSTNAME=web-1\n\n" +
+		"\n" + // stray blank line between entries
+		"__CURSOR=s=1;i=2\n" + binaryField("MESSAGE", "line one\nline two\x00") + "PRIORITY=6\n\n"
+	r := exportReader(stream)
+	keep := map[string]bool{"__CURSOR": true, "PRIORITY": true, "MESSAGE": true}
+
+	for _, want := range []map[string]string{
+		{"__CURSOR": "s=1;i=1", "PRIORITY": "3", "MESSAGE": "disk failure"},
+		{"__CURSOR": "s=1;i=2", "PRIORITY": "6", "MESSAGE": "line one\nline two\x00"},
+	} {
+		got, err := readExportEntry(r, keep, 1024)
+		if err != nil {
+			t.Fatalf("readExportEntry: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("entry = %q, want %q", got, want)
+		}
+	}
+	if _, err := readExportEntry(r, keep, 1024); err != io.EOF {
+		t.Fatalf("after the last entry: %v, want io.EOF", err)
+	}
+}
+
+func TestReadExportEntryKeepsAllFieldsWithoutFilter(t *testing.T) {
+	got, err := readExportEntry(exportReader("A=1\nB=2\n\n"), nil, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"A": "1", "B": "2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("entry = %q, want %q", got, want)
+	}
+}
+
+func TestReadExportEntryTruncates(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	stream := "MESSAGE=" + long + "\n" + binaryField("DATA", long) + "PRIORITY=4\n\n" + "MESSAGE=next\n\n"
+	r := exportReader(stream)
+
+	got, err := readExportEntry(r, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"MESSAGE": long[:10], "DATA": long[:10], "PRIORITY": "4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("entry = %q, want %q", got, want)
+	}
+
+	// the cut-off rest of both values was skipped, not left in the stream
+	got, err = readExportEntry(r, nil, 10)
+	if err != nil || got["MESSAGE"] != "next" {
+		t.Fatalf("next entry = %q, %v", got, err)
+	}
+}
+
+func TestReadExportEntryUnexpectedEOF(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		stream string
+	}{
+		{"no terminating blank line", "MESSAGE=hello\n"},
+		{"inside a text value", "MESSAGE=hel"},
+		{"inside a field name", "MESSAGE=hello\nPRIO"},
+		{"inside a binary length", "MESSAGE\n\x05\x00\x00"},
+		{"inside binary data", "MESSAGE\n\x05\x00\x00\x00\x00\x00\x00\x00ab"},
+		{"missing newline after binary data", "MESSAGE\n\x02\x00\x00\x00\x00\x00\x00\x00ab"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readExportEntry(exportReader(tc.stream), nil, 1024)
+			if err != io.ErrUnexpectedEOF {
+				t.Fatalf("err = %v, want io.ErrUnexpectedEOF", err)
+			}
+		})
+	}
+}
+
+func TestReadExportEntryMalformed(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		stream string
+	}{
+		{"field name too long", strings.Repeat("K", maxFieldName+1) + "=v\n\n"},
+		{"binary value without newline", "MESSAGE\n\x02\x00\x00\x00\x00\x00\x00\x00abX\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readExportEntry(exportReader(tc.stream), nil, 1024)
+			if !errors.Is(err, errBadExport) {
+				t.Fatalf("err = %v, want errBadExport", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package logtail
+
+import (
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type rule struct {
+	configs.LogRule
+	re *regexp.Regexp
+}
+
+// ruleSet classifies lines and accumulates the counts and samples of the
+// current interval. Samples are capped per rule, so memory does not grow
+// with the log volume.
+type ruleSet struct {
+	mu         sync.Mutex
+	rules      []rule
+	maxSamples int
+
+	start   time.Time
+	lines   map[string]int
+	stats   []models.LogRuleStats
+	errored atomic.Int64
+}
+
+func newRuleSet(cfg []configs.LogRule, maxSamples int) *ruleSet {
+	rs := &ruleSet{maxSamples: maxSamples}
+	for _, r := range cfg {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			log.Warn("skipping log rule with bad pattern", "rule", r.Name, "err", err)
+			continue
+		}
+		rs.rules = append(rs.rules, rule{LogRule: r, re: re})
+	}
+	rs.reset(time.Now())
+	return rs
+}
+
+func (rs *ruleSet) reset(now time.Time) {
+	rs.start = now
+	rs.lines = map[string]int{}
+	rs.stats = make([]models.LogRuleStats, len(rs.rules))
+	for i, r := range rs.rules {
+		rs.stats[i] = models.LogRuleStats{Rule: r.Name, Severity: r.Severity}
+	}
+}
+
+// observe counts line from source against the first rule it matches and
+// returns that rule's severity, or "" when none matched.
+func (rs *ruleSet) observe(source string, line []byte) string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.lines[source]++
+	for i, r := range rs.rules {
+		if !r.re.Match(line) {
+			continue
+		}
+		st := &rs.stats[i]
+		st.Count++
+		if len(st.Samples) < rs.maxSamples {
+			st.Samples = append(st.Samples, models.LogSample{
+				File: source,
+				Line: string(line),
+				Time: time.Now().Unix(),
+			})
+		}
+		return r.Severity
+	}
+	return ""
+}
+
+// Report returns the counts and samples since the previous report and
+// starts a new interval.
+func (t *Tailer) Report(userID, machineID, hostname string) *models.LogReport {
+	rs := t.rules
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	report := &models.LogReport{
+		UserID:        userID,
+		MachineID:     machineID,
+		Hostname:      hostname,
+		IntervalStart: rs.start.Unix(),
+		IntervalEnd:   now.Unix(),
+		Lines:         rs.lines,
+		Rules:         rs.stats,
+	}
+	rs.reset(now)
+	return report
+}
+
+// ErrorCount returns how many file lines matched an error or critical rule,
+// plus the journal entries of priority err or worse, since the agent
+// started.
+func (t *Tailer) ErrorCount() int {
+	return int(t.rules.errored.Load())
+}
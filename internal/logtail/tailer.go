@@ -0,0 +1,407 @@
+package logtail
+
+import (
+	"bufio"
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/hostfs"
+	"iDevopzAgent/internal/logging"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var log = logging.For("logtail")
+
+const (
+	pollInterval = 5 * time.Second
+
+	// maxBytesPerPoll bounds the work per file and poll; a file that grows
+	// faster is caught up over the following polls.
+	maxBytesPerPoll = 8 << 20
+	readBufferSize  = 64 << 10
+)
+
+// fileState is the persisted read position of one log file. Offset is where
+// the rules and watchers continue; Backlog is how many bytes before Offset
+// the forwarder still has to take. Both always point at the start of a
+// line. Rotated holds the positions in the previous files, oldest first,
+// while they are not yet fully counted or forwarded.
+type fileState struct {
+	Inode   uint64       `json:"inode"`
+	Offset  int64        `json:"offset"`
+	Backlog int64        `json:"backlog,omitempty"`
+	Rotated []*fileState `json:"rotated,omitempty"`
+}
+
+// LineFunc receives the new lines of a watched file. name is the file's
+// path on the host.
+type LineFunc func(name string, line []byte)
+
+type watcher struct {
+	patterns []string
+	fn       LineFunc
+}
+
+// Tailer follows the configured log files across rotation and feeds every
+// new line to the rules, and the lines of watched files to their watchers.
+type Tailer struct {
+	mu        sync.Mutex
+	root      string
+	patterns  []string
+	fed       []string // files the journal source already counts
+	watchers  []watcher
+	maxLine   int
+	statePath string
+	loaded    bool
+	files     map[string]*fileState
+
+	rules *ruleSet
+	fwd   *Forwarder
+}
+
+// target says what the lines of one file feed.
+type target struct {
+	counted   bool // by the rules
+	forwarded bool // offered to the forwarder
+	held      bool // counted, but left in the backlog behind an older file
+	watchers  []LineFunc
+}
+
+var (
+	tailerOnce sync.Once
+	tailer     *Tailer
+)
+
+// GetTailer returns the process-wide tailer built from the agent settings.
+func GetTailer() *Tailer {
+	tailerOnce.Do(func() {
+		settings := configs.LoadSettings()
+		cfg := settings.LogTail
+		fwd := newForwarder(cfg.Forward)
+		var patterns []string
+		var fed []string
+		if cfg.Enabled {
+			patterns = append(append(patterns, cfg.Files...), fwd.patterns()...)
+			if cfg.Journal.Enabled && JournalAvailable() {
+				fed = cfg.Journal.SyslogFiles
+			}
+		}
+		tailer = &Tailer{
+			root:      settings.HostRoot,
+			patterns:  patterns,
+			fed:       fed,
+			maxLine:   cfg.MaxLineBytes,
+			statePath: filepath.Join(configs.DataDir(), "logtail_state.json"),
+			files:     map[string]*fileState{},
+			rules:     newRuleSet(cfg.Rules, cfg.SamplesPerRule),
+			fwd:       fwd,
+		}
+		if tailer.maxLine <= 0 {
+			tailer.maxLine = 4096
+		}
+	})
+	return tailer
+}
+
+// GetForwarder returns the log forwarder fed by the tailer.
+func GetForwarder() *Forwarder {
+	return GetTailer().fwd
+}
+
+// Watch feeds the new lines of the files matching patterns to fn, from
+// the next poll on. Files matched only by watchers are not counted by the
+// rules.
+func (t *Tailer) Watch(patterns []string, fn LineFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.watchers = append(t.watchers, watcher{patterns: patterns, fn: fn})
+}
+
+// Run polls the files until the process exits. With log tailing disabled
+// only the watched files are followed.
+func (t *Tailer) Run() {
+	for {
+		t.Poll()
+		time.Sleep(pollInterval)
+	}
+}
+
+// Poll reads what was appended to every configured file since the last
+// poll. A file seen for the first time is read from its end, so existing
+// history is not reported as new. Files the journal source feeds are only
+// forwarded, so their entries are not counted twice.
+func (t *Tailer) Poll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.load()
+
+	fed := map[string]bool{}
+	for _, name := range t.expand(t.fed) {
+		fed[name] = true
+	}
+	targets := map[string]*target{}
+	for _, name := range t.expand(t.patterns) {
+		targets[name] = &target{counted: !fed[name], forwarded: true}
+	}
+	for _, w := range t.watchers {
+		for _, name := range t.expand(w.patterns) {
+			if targets[name] == nil {
+				targets[name] = &target{}
+			}
+			targets[name].watchers = append(targets[name].watchers, w.fn)
+		}
+	}
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.follow(name, targets[name])
+	}
+	if len(names) > 0 {
+		t.save()
+	}
+}
+
+// expand resolves paths and globs to existing files, as paths relative to
+// the host root.
+func (t *Tailer) expand(patterns []string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(t.hostPath(pattern))
+		if err != nil {
+			log.Warn("bad log file pattern", "pattern", pattern, "err", err)
+			continue
+		}
+		for _, m := range matches {
+			name := m
+			if !t.atRoot() {
+				rel, _ := filepath.Rel(t.root, m)
+				name = "/" + filepath.ToSlash(rel)
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *Tailer) atRoot() bool {
+	return t.root == "" || t.root == "/"
+}
+
+// hostPath maps a configured path onto the host root. Paths are used as
+// they are when the root is "/", which keeps Windows paths intact.
+func (t *Tailer) hostPath(p string) string {
+	if t.atRoot() {
+		return p
+	}
+	return filepath.Join(t.root, p)
+}
+
+func (t *Tailer) follow(name string, tgt *target) {
+	full := t.hostPath(name)
+	info, err := os.Stat(full)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	ino := hostfs.Inode(info)
+
+	st, ok := t.files[name]
+	if !ok {
+		t.files[name] = &fileState{Inode: ino, Offset: info.Size()}
+		log.Debug("tailing log file", "file", name)
+		return
+	}
+
+	if st.Inode != ino {
+		log.Debug("log file rotated", "file", name)
+		st.Rotated = append(st.Rotated, &fileState{Inode: st.Inode, Offset: st.Offset, Backlog: st.Backlog})
+		st.Inode, st.Offset, st.Backlog = ino, 0, 0
+	}
+
+	// finish the previous files while they are still around; the newer
+	// ones are counted meanwhile, but forwarded only after them
+	held := *tgt
+	kept := st.Rotated[:0]
+	for _, prev := range st.Rotated {
+		if old := findRotated(full, prev.Inode); old != "" && !t.read(name, old, prev, &held) {
+			kept = append(kept, prev)
+			held.held = true
+			continue
+		}
+		if prev.Backlog > 0 {
+			log.Warn("rotated log file gone before it was forwarded", "file", name, "bytes", prev.Backlog)
+			t.fwd.drop(prev.Backlog)
+		}
+	}
+	st.Rotated = kept
+
+	if info.Size() < st.Offset {
+		log.Debug("log file truncated", "file", name)
+		if st.Backlog > 0 {
+			t.fwd.drop(st.Backlog)
+		}
+		st.Offset, st.Backlog = 0, 0
+	}
+	t.read(name, full, st, &held)
+}
+
+// findRotated looks for a renamed previous file (name.1, name.2, or
+// name-DATE with dateext) by its inode.
+func findRotated(full string, ino uint64) string {
+	candidates, _ := filepath.Glob(full + ".*")
+	dated, _ := filepath.Glob(full + "-*")
+	candidates = append(candidates, dated...)
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && hostfs.Inode(info) == ino {
+			return c
+		}
+	}
+	return ""
+}
+
+// read feeds the complete lines of path to tgt and advances st: the lines
+// from st.Offset on go to the rules and watchers, each exactly once, and the
+// lines from the forward position on to the forwarder until it refuses one.
+// A refusal only holds the forward position back; counting goes on and the
+// refused lines are offered again next poll. With tgt.held nothing is
+// offered and the counted lines stay in the backlog. Memory stays at one
+// read buffer plus one line, however large the file. read reports whether
+// the file is done with: read to its end with nothing left to forward, or
+// unreadable.
+func (t *Tailer) read(name, path string, st *fileState, tgt *target) bool {
+	counted, fwd := st.Offset, st.Offset
+	if tgt.forwarded {
+		fwd -= st.Backlog
+	}
+	defer func() {
+		st.Offset, st.Backlog = counted, 0
+		if tgt.forwarded {
+			st.Backlog = counted - fwd
+		}
+	}()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+
+	var r *bufio.Reader
+	var lineStart, pos int64
+	seek := func(offset int64) bool {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return false
+		}
+		if r == nil {
+			r = bufio.NewReaderSize(f, readBufferSize)
+		} else {
+			r.Reset(f)
+		}
+		lineStart, pos = offset, offset
+		return true
+	}
+	if !seek(fwd) {
+		return true
+	}
+
+	line := make([]byte, 0, t.maxLine)
+	stalled := !tgt.forwarded || tgt.held
+	for {
+		chunk, err := r.ReadSlice('\n')
+		pos += int64(len(chunk))
+		if room := t.maxLine - len(line); room > 0 {
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			// a line without a newline for this long is taken as it is
+			if pos-lineStart < maxBytesPerPoll {
+				continue
+			}
+		case err != nil:
+			// EOF: an incomplete last line is read again next time
+			return !tgt.forwarded || fwd == counted
+		}
+
+		l := trimLine(line)
+		if !stalled {
+			if t.fwd.offer(name, l) {
+				fwd = pos
+			} else {
+				// forwarding is backed up: continue from this line next poll
+				stalled = true
+			}
+		}
+		if lineStart >= counted {
+			if tgt.counted {
+				if sev := t.rules.observe(name, l); sev == "error" || sev == "critical" {
+					t.rules.errored.Add(1)
+				}
+			}
+			for _, fn := range tgt.watchers {
+				fn(name, l)
+			}
+			counted = pos
+		}
+		line = line[:0]
+		lineStart = pos
+
+		switch {
+		case counted-st.Offset >= maxBytesPerPoll:
+			return false
+		case lineStart < counted && (stalled || lineStart-(st.Offset-st.Backlog) >= maxBytesPerPoll):
+			// only forwarding is behind: skip to the first uncounted line
+			stalled = true
+			if !seek(counted) {
+				return true
+			}
+		}
+	}
+}
+
+func trimLine(b []byte) []byte {
+	for len(b) > 0 && (b[len(b)-1] == '\n' || b[len(b)-1] == '\r') {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+func (t *Tailer) load() {
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+
+	data, err := os.ReadFile(t.statePath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &t.files); err != nil {
+		log.Warn("ignoring corrupt log tail state", "err", err)
+		t.files = map[string]*fileState{}
+	}
+}
+
+func (t *Tailer) save() {
+	data, err := json.Marshal(t.files)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(t.statePath), 0700)
+	if err := os.WriteFile(t.statePath, data, 0600); err != nil {
+		log.Error("failed to save log tail state", "err", err)
+	}
+}
@@ -0,0 +1,231 @@
+package logtail
+
+import (
+	"iDevopzAgent/configs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestTailer follows /app.log below a temp root and forwards it through
+// a queue of a single record, so the forwarder backs up after two lines.
+func newTestTailer(t *testing.T) (*Tailer, string) {
+	t.Helper()
+	root := t.TempDir()
+	fwd := newForwarder(configs.LogForwardSettings{
+		Enabled:      true,
+		BatchRecords: 1,
+		MaxBuffered:  1,
+		Sources:      []configs.LogForwardSource{{Name: "app", Files: []string{"/app.log"}}},
+	})
+	tl := &Tailer{
+		root:      root,
+		patterns:  []string{"/app.log"},
+		maxLine:   4096,
+		statePath: filepath.Join(root, "logtail_state.json"),
+		files:     map[string]*fileState{},
+		rules:     newRuleSet(configs.DefaultLogRules(), 5),
+		fwd:       fwd,
+	}
+	return tl, filepath.Join(root, "app.log")
+}
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, l := range lines {
+		if _, err := f.WriteString(l + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// drain returns the messages of the queued records and acknowledges them.
+// Records held back as pending are aged so Pending queues them.
+func drain(tl *Tailer) []string {
+	var msgs []string
+	for {
+		tl.fwd.mu.Lock()
+		for _, src := range tl.fwd.sources {
+			for _, p := range src.pending {
+				p.last = p.last.Add(-multilineIdle)
+			}
+		}
+		tl.fwd.mu.Unlock()
+
+		b := tl.fwd.Pending("u", "m", "h")
+		if b == nil {
+			return msgs
+		}
+		for _, r := range b.Records {
+			msgs = append(msgs, r.Message)
+		}
+		tl.fwd.Ack(len(b.Records))
+	}
+}
+
+func TestTailerCountsWhileForwardingIsBackedUp(t *testing.T) {
+	tl, path := newTestTailer(t)
+	appendLines(t, path, "history")
+	tl.Poll() // starts at the end
+
+	appendLines(t, path, "error one", "error two", "error three")
+	tl.Poll()
+	if got := tl.ErrorCount(); got != 3 {
+		t.Fatalf("error count = %d with forwarding backed up, want 3", got)
+	}
+	if st := tl.files["/app.log"]; st.Backlog == 0 {
+		t.Fatalf("state = %+v, want a forward backlog", st)
+	}
+
+	// the refused lines are offered again, but not counted again
+	appendLines(t, path, "error four")
+	tl.Poll()
+	tl.Poll()
+	if got := tl.ErrorCount(); got != 4 {
+		t.Fatalf("error count = %d, want 4", got)
+	}
+
+	var forwarded []string
+	for i := 0; i < 5; i++ {
+		forwarded = append(forwarded, drain(tl)...)
+		tl.Poll()
+	}
+	forwarded = append(forwarded, drain(tl)...)
+	if got := strings.Join(forwarded, ","); got != "error one,error two,error three,error four" {
+		t.Fatalf("forwarded %q", got)
+	}
+	if st := tl.files["/app.log"]; st.Backlog != 0 {
+		t.Fatalf("state = %+v after the forwarder caught up", st)
+	}
+	if got := tl.ErrorCount(); got != 4 {
+		t.Fatalf("error count = %d after catching up, want 4", got)
+	}
+}
+
+func TestTailerFinishesRotatedFile(t *testing.T) {
+	tl, path := newTestTailer(t)
+	appendLines(t, path, "history")
+	tl.Poll()
+
+	appendLines(t, path, "error one", "error two", "error three")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "error four")
+
+	// both files are counted in full, though the forwarder still has to
+	// take the end of the rotated one
+	tl.Poll()
+	if got := tl.ErrorCount(); got != 4 {
+		t.Fatalf("error count = %d, want the lines of both files", got)
+	}
+
+	var forwarded []string
+	for i := 0; i < 5; i++ {
+		forwarded = append(forwarded, drain(tl)...)
+		tl.Poll()
+	}
+	forwarded = append(forwarded, drain(tl)...)
+	if got := strings.Join(forwarded, ","); got != "error one,error two,error three,error four" {
+		t.Fatalf("forwarded %q", got)
+	}
+	if got := tl.ErrorCount(); got != 4 {
+		t.Fatalf("error count = %d, want 4", got)
+	}
+}
+
+func TestTailerFollowsRepeatedRotation(t *testing.T) {
+	tl, path := newTestTailer(t)
+	appendLines(t, path, "history")
+	tl.Poll()
+
+	appendLines(t, path, "error one", "error two", "error three")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "error four")
+	tl.Poll()
+
+	// rotated again before the forwarder caught up with the first file
+	if err := os.Rename(path+".1", path+".2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "error five")
+	tl.Poll()
+	if got := tl.ErrorCount(); got != 5 {
+		t.Fatalf("error count = %d, want 5", got)
+	}
+
+	var forwarded []string
+	for i := 0; i < 8; i++ {
+		forwarded = append(forwarded, drain(tl)...)
+		tl.Poll()
+	}
+	forwarded = append(forwarded, drain(tl)...)
+	if got := strings.Join(forwarded, ","); got != "error one,error two,error three,error four,error five" {
+		t.Fatalf("forwarded %q", got)
+	}
+	if st := tl.files["/app.log"]; len(st.Rotated) != 0 || st.Backlog != 0 {
+		t.Fatalf("state = %+v after the forwarder caught up", st)
+	}
+}
+
+func TestTailerCountsDroppedBacklog(t *testing.T) {
+	tl, path := newTestTailer(t)
+	appendLines(t, path, "history")
+	tl.Poll()
+
+	appendLines(t, path, "error one", "error two", "error three")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLines(t, path, "error four")
+	tl.Poll()
+
+	// removed before its last line was forwarded
+	if err := os.Remove(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	tl.Poll()
+	if got, want := tl.fwd.DroppedBytes(), int64(len("error three\n")); got != want {
+		t.Fatalf("dropped %d bytes, want %d", got, want)
+	}
+
+	var forwarded []string
+	for i := 0; i < 5; i++ {
+		forwarded = append(forwarded, drain(tl)...)
+		tl.Poll()
+	}
+	forwarded = append(forwarded, drain(tl)...)
+	if got := strings.Join(forwarded, ","); got != "error one,error two,error four" {
+		t.Fatalf("forwarded %q", got)
+	}
+	if got := tl.ErrorCount(); got != 4 {
+		t.Fatalf("error count = %d, want 4", got)
+	}
+}
+
+func TestTailerSkipsJournalFedFiles(t *testing.T) {
+	tl, path := newTestTailer(t)
+	tl.fed = []string{"/app.log"}
+	appendLines(t, path, "history")
+	tl.Poll()
+
+	appendLines(t, path, "error one")
+	tl.Poll()
+	if got := tl.ErrorCount(); got != 0 {
+		t.Fatalf("error count = %d for a file the journal feeds", got)
+	}
+	if got := drain(tl); len(got) != 1 {
+		t.Fatalf("forwarded %q, want the line forwarded anyway", got)
+	}
+}
@@ -5,6 +5,7 @@ package metrics
 
 import (
 	"bufio"
+	"iDevopzAgent/internal/alerting"
 	"iDevopzAgent/internal/utils"
 	"iDevopzAgent/models"
 	"os"
@@ -93,17 +94,7 @@ func (l LinuxCollector) MetricsCollect(userID string, machineId string) (*models
 	// Metrics timestamp
 	utcNow := time.Now().UTC().Format(time.RFC3339)
 
-	// Determine system status
-	status := "up"
-	if cpuPercent > 90 || memUsagePercent > 90 || diskUsagePercent > 95 {
-		status = "critical"
-	} else if cpuPercent > 80 || memUsagePercent > 80 || diskUsagePercent > 85 {
-		status = "trouble"
-	} else if cpuPercent < 5 && memUsagePercent < 10 && diskUsagePercent < 10 {
-		status = "down"
-	}
-
-	return &models.Metrics{
+	metrics := &models.Metrics{
 		UserID:               userID,
 		Hostname:             hostname,
 		MachineID:            machineId,
@@ -123,9 +114,9 @@ func (l LinuxCollector) MetricsCollect(userID string, machineId string) (*models
 		SwapMemUsagePercent:  swapMemUsagePercent,
 		DiskUsed:             diskUsed,
 		DiskTotal:            diskTotal,
+		DiskUsedPercent:      diskUsagePercent,
 		Uptime:               uptime,
 		MetricGetTime:        utcNow,
-		Status:               status,
 		Os:                   osName,
 		Interrupts:           interrupts,
 		ContextSwitches:      contextSwitches,
@@ -137,7 +128,12 @@ func (l LinuxCollector) MetricsCollect(userID string, machineId string) (*models
 		OverallDiskIOPS:      totalIOPS,
 		OverallDiskIdle:      diskIdle,
 		OverallDiskBusy:      diskBusy,
-	}, nil
+	}
+
+	// Status is derived from the alert rules
+	metrics.Status = alerting.GetEngine().Status(metrics)
+
+	return metrics, nil
 }
 
 func GetCollector() Collector {
@@ -265,12 +261,7 @@ func GetLinuxDiskPartitionsWithIO() ([]models.DiskPartition, error) {
 		}
 
 		// Health
-		health := "healthy"
-		if UsedPercent > 90 {
-			health = "critical"
-		} else if UsedPercent > 80 {
-			health = "warning"
-		}
+		health := alerting.GetEngine().PartitionHealth(UsedPercent)
 
 		// Find matching device (strip /dev/)
 		devName := strings.TrimPrefix(p.Device, "/dev/")
@@ -4,6 +4,7 @@
 package metrics
 
 import (
+	"iDevopzAgent/internal/alerting"
 	"iDevopzAgent/internal/utils"
 	"iDevopzAgent/models"
 	"time"
@@ -136,17 +137,7 @@ func (w WindowsCollector) MetricsCollect(userID string, machineId string) (*mode
 	// Metrics timestamp
 	utcNow := time.Now().UTC().Format(time.RFC3339)
 
-	// Determine system status
-	status := "up"
-	if cpuPercent > 90 || memUsagePercent > 90 || diskUsagePercent > 95 {
-		status = "critical"
-	} else if cpuPercent > 80 || memUsagePercent > 80 || diskUsagePercent > 85 {
-		status = "trouble"
-	} else if cpuPercent < 5 && memUsagePercent < 10 && diskUsagePercent < 10 {
-		status = "down"
-	}
-
-	return &models.Metrics{
+	metrics := &models.Metrics{
 		UserID:               userID,
 		Hostname:             hostname,
 		MachineID:            machineId,
@@ -166,9 +157,9 @@ func (w WindowsCollector) MetricsCollect(userID string, machineId string) (*mode
 		SwapMemUsagePercent:  swapMemUsagePercent,
 		DiskUsed:             diskUsed,
 		DiskTotal:            diskTotal,
+		DiskUsedPercent:      diskUsagePercent,
 		Uptime:               uptime,
 		MetricGetTime:        utcNow,
-		Status:               status,
 		Os:                   osName,
 		Interrupts:           interrupts,
 		ContextSwitches:      contextSwitches,
@@ -180,7 +171,12 @@ func (w WindowsCollector) MetricsCollect(userID string, machineId string) (*mode
 		OverallDiskIOPS:      overallIOPS,
 		OverallDiskIdle:      overallIdlePercent,
 		OverallDiskBusy:      overallBusyPercent,
-	}, nil
+	}
+
+	// Status is derived from the alert rules
+	metrics.Status = alerting.GetEngine().Status(metrics)
+
+	return metrics, nil
 }
 func bytesToGB(bytes uint64) float64 {
 	return float64(bytes) / (1024 * 1024 * 1024)
@@ -202,12 +198,7 @@ func GetDiskPartitionsWithIO() ([]models.DiskPartition, error) {
 		}
 
 		// Determine health status
-		health := "healthy"
-		if UsedPercent > 90 {
-			health = "critical"
-		} else if UsedPercent > 80 {
-			health = "warning"
-		}
+		health := alerting.GetEngine().PartitionHealth(UsedPercent)
 
 		// Query read/write bytes/sec via WMI
 		type Win32_PerfFormattedData_PerfDisk_LogicalDisk struct {
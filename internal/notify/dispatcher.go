@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/models"
+	"sync"
+	"time"
+)
+
+var log = logging.For("notify")
+
+const queueSize = 100
+
+// channel wraps one notifier with its own queue, rate limit, dedup window
+// and retry policy, so a slow SMTP server cannot hold up the webhooks.
+type channel struct {
+	notifier    Notifier
+	minSeverity string
+	rateLimit   int
+	dedup       time.Duration
+	retries     int
+	backoff     time.Duration
+
+	queue chan *models.AlertEvent
+
+	mu       sync.Mutex
+	sent     []time.Time
+	lastSent map[string]sentState // by rule and instance
+}
+
+// sentState is the last event state sent for a rule and instance.
+type sentState struct {
+	state string
+	at    time.Time
+}
+
+// Dispatcher fans alert events out to every configured notifier.
+type Dispatcher struct {
+	channels []*channel
+
+	mu        sync.Mutex
+	slaFiring map[string]bool
+}
+
+var (
+	dispatcherOnce sync.Once
+	dispatcher     *Dispatcher
+)
+
+// GetDispatcher returns the dispatcher built from the agent settings.
+// Notifiers that fail to build are reported and skipped.
+func GetDispatcher() *Dispatcher {
+	dispatcherOnce.Do(func() {
+		dispatcher = NewDispatcher(configs.LoadSettings().Notifiers)
+	})
+	return dispatcher
+}
+
+// NewDispatcher starts one delivery worker per notifier config.
+func NewDispatcher(cfgs []configs.NotifierConfig) *Dispatcher {
+	d := &Dispatcher{slaFiring: make(map[string]bool)}
+	for _, cfg := range cfgs {
+		n, err := NewNotifier(cfg)
+		if err != nil {
+			log.Warn("skipping notifier", "err", err)
+			continue
+		}
+		d.Add(n, cfg)
+	}
+	return d
+}
+
+// Add registers n with the delivery policy from cfg and starts its worker.
+func (d *Dispatcher) Add(n Notifier, cfg configs.NotifierConfig) {
+	c := &channel{
+		notifier:    n,
+		minSeverity: cfg.MinSeverity,
+		rateLimit:   cfg.RateLimitPerMinute,
+		dedup:       time.Duration(cfg.DedupMinutes) * time.Minute,
+		retries:     cfg.Retries,
+		backoff:     time.Second,
+		queue:       make(chan *models.AlertEvent, queueSize),
+		lastSent:    make(map[string]sentState),
+	}
+	if c.rateLimit <= 0 {
+		c.rateLimit = 10
+	}
+	if c.dedup <= 0 {
+		c.dedup = 15 * time.Minute
+	}
+	if c.retries <= 0 {
+		c.retries = 3
+	}
+
+	d.channels = append(d.channels, c)
+	go c.run()
+}
+
+// Notify queues events for every notifier. It never blocks: events are
+// dropped when a notifier's queue is full.
+func (d *Dispatcher) Notify(events []*models.AlertEvent) {
+	for _, event := range events {
+		for _, c := range d.channels {
+			if severityRank[event.Severity] < severityRank[c.minSeverity] {
+				continue
+			}
+			select {
+			case c.queue <- event:
+			default:
+				log.Warn("notifier queue full, dropping event", "notifier", c.notifier.Name(), "rule", event.Rule)
+			}
+		}
+	}
+}
+
+// NotifyHealth turns SLA target breaches in the health report into
+// firing/resolved events.
+func (d *Dispatcher) NotifyHealth(report *models.HealthReport) {
+	var events []*models.AlertEvent
+
+	d.mu.Lock()
+	for _, w := range report.Windows {
+		rule := "sla_" + w.Window
+		breached := !w.SLAMet
+		if breached == d.slaFiring[rule] {
+			continue
+		}
+		d.slaFiring[rule] = breached
+
+		state := "resolved"
+		if breached {
+			state = "firing"
+		}
+		events = append(events, &models.AlertEvent{
+			UserID:    report.UserID,
+			MachineID: report.MachineID,
+			Hostname:  report.Hostname,
+			Rule:      rule,
+			Metric:    "sla",
+			Instance:  w.Window,
+			Severity:  "warning",
+			State:     state,
+			Value:     w.SLA,
+			Threshold: w.SLATarget,
+			Timestamp: time.Now().Unix(),
+		})
+	}
+	d.mu.Unlock()
+
+	d.Notify(events)
+}
+
+func (c *channel) run() {
+	for event := range c.queue {
+		if !c.allow(event) {
+			continue
+		}
+
+		var err error
+		backoff := c.backoff
+		for attempt := 0; attempt <= c.retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+			if err = c.notifier.Notify(event); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Error("notifier failed", "notifier", c.notifier.Name(), "attempts", c.retries+1, "err", err)
+		}
+	}
+}
+
+// allow applies dedup and the per-minute rate limit, recording the send
+// when the event is let through. Only a repeat of the state last sent for
+// the rule and instance is deduplicated; every transition, such as a rule
+// firing again right after it resolved, goes out.
+func (c *channel) allow(event *models.AlertEvent) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	key := event.Rule + "|" + event.Instance
+	if last, ok := c.lastSent[key]; ok && last.state == event.State && now.Sub(last.at) < c.dedup {
+		return false
+	}
+
+	recent := c.sent[:0]
+	for _, t := range c.sent {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	c.sent = recent
+	if len(c.sent) >= c.rateLimit {
+		log.Warn("notifier rate limited, dropping event", "notifier", c.notifier.Name(), "rule", event.Rule)
+		return false
+	}
+
+	c.sent = append(c.sent, now)
+	c.lastSent[key] = sentState{state: event.State, at: now}
+	for k, last := range c.lastSent {
+		if now.Sub(last.at) >= c.dedup {
+			delete(c.lastSent, k)
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"iDevopzAgent/models"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// EmailNotifier sends the event as a plain-text mail over SMTP. STARTTLS is
+// used when the server offers it; auth is only attempted with a username.
+type EmailNotifier struct {
+	name     string
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Template *template.Template
+	Timeout  time.Duration // whole delivery, smtpTimeout when zero
+}
+
+// smtpTimeout bounds one delivery, from dialing to QUIT.
+const smtpTimeout = 30 * time.Second
+
+func (e *EmailNotifier) Name() string { return e.name }
+
+func (e *EmailNotifier) Notify(event *models.AlertEvent) error {
+	text, err := render(e.Template, event)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("[%s] %s %s on %s", strings.ToUpper(event.Severity), event.Rule, event.State, event.Hostname)
+	msg := strings.Join([]string{
+		"From: " + e.From,
+		"To: " + strings.Join(e.To, ", "),
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		text,
+	}, "\r\n")
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	return e.send(auth, []byte(msg))
+}
+
+// send delivers msg like smtp.SendMail, but with the whole conversation
+// bounded by the timeout so a stalled server cannot block the channel.
+func (e *EmailNotifier) send(auth smtp.Auth, msg []byte) error {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = smtpTimeout
+	}
+	host, _, err := net.SplitHostPort(e.Addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", e.Addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.From); err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
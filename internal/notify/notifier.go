@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/httpclient"
+	"iDevopzAgent/models"
+	"io"
+	"text/template"
+	"time"
+)
+
+// Notifier delivers one alert event to a local channel.
+type Notifier interface {
+	Name() string
+	Notify(event *models.AlertEvent) error
+}
+
+var severityRank = map[string]int{
+	"down":     1,
+	"warning":  2,
+	"critical": 3,
+}
+
+// NewNotifier builds the notifier for cfg.
+func NewNotifier(cfg configs.NotifierConfig) (Notifier, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Type
+	}
+
+	tmpl, err := parseTemplate(name, cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Type {
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("notifier %q: url is required", name)
+		}
+		return &WebhookNotifier{name: name, URL: cfg.URL, Headers: cfg.Headers, Template: tmpl}, nil
+	case "slack", "teams":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("notifier %q: url is required", name)
+		}
+		return &ChatNotifier{name: name, URL: cfg.URL, Format: cfg.Type, Template: tmpl}, nil
+	case "email":
+		if cfg.SMTPAddr == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("notifier %q: smtp_addr, from and to are required", name)
+		}
+		password, err := cfg.SMTPPassword()
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: cannot decrypt password: %w", name, err)
+		}
+		return &EmailNotifier{
+			name:     name,
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.Username,
+			Password: password,
+			From:     cfg.From,
+			To:       cfg.To,
+			Template: tmpl,
+		}, nil
+	case "syslog":
+		return newSyslogNotifier(name, cfg.Network, cfg.Address, cfg.Tag, tmpl)
+	}
+	return nil, fmt.Errorf("notifier %q: unknown type %q", name, cfg.Type)
+}
+
+// parseTemplate compiles a text/template over models.AlertEvent. An empty
+// source yields nil and the notifier's default message is used.
+func parseTemplate(name, source string) (*template.Template, error) {
+	if source == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"time": func(unix int64) string { return time.Unix(unix, 0).UTC().Format(time.RFC3339) },
+	}).Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("notifier %q: bad template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func render(tmpl *template.Template, event *models.AlertEvent) (string, error) {
+	if tmpl == nil {
+		return summary(event), nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// summary is the default one-line message for an event.
+func summary(event *models.AlertEvent) string {
+	target := event.Metric
+	if event.Instance != "" {
+		target += " on " + event.Instance
+	}
+	return fmt.Sprintf("[%s] %s %s on %s: %s = %.2f (threshold %.2f)",
+		event.Severity, event.Rule, event.State, event.Hostname, target, event.Value, event.Threshold)
+}
+
+// WebhookNotifier posts the event as JSON, or the rendered template when
+// one is configured, to a generic HTTP endpoint.
+type WebhookNotifier struct {
+	name     string
+	URL      string
+	Headers  map[string]string
+	Template *template.Template
+}
+
+func (w *WebhookNotifier) Name() string { return w.name }
+
+func (w *WebhookNotifier) Notify(event *models.AlertEvent) error {
+	var body []byte
+	if w.Template != nil {
+		text, err := render(w.Template, event)
+		if err != nil {
+			return err
+		}
+		body = []byte(text)
+	} else {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		body = data
+	}
+	return postBody(w.URL, body, w.Headers)
+}
+
+// ChatNotifier posts to Slack or Teams style incoming webhooks.
+type ChatNotifier struct {
+	name     string
+	URL      string
+	Format   string // slack, teams
+	Template *template.Template
+}
+
+func (c *ChatNotifier) Name() string { return c.name }
+
+func (c *ChatNotifier) Notify(event *models.AlertEvent) error {
+	text, err := render(c.Template, event)
+	if err != nil {
+		return err
+	}
+
+	var payload any
+	if c.Format == "teams" {
+		color := "2EB886"
+		if event.State == "firing" {
+			color = "D93F0B"
+		}
+		payload = map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    event.Rule,
+			"themeColor": color,
+			"text":       text,
+		}
+	} else {
+		payload = map[string]string{"text": text}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return postBody(c.URL, body, nil)
+}
+
+func postBody(url string, body []byte, headers map[string]string) error {
+	resp, err := httpclient.SendPOSTBody(url, "application/json", body, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %s: %s", resp.Status, string(msg))
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testEvent(state string) *models.AlertEvent {
+	return &models.AlertEvent{
+		Hostname:  "web-1",
+		Rule:      "cpu_critical",
+		Metric:    "cpu_percent",
+		Severity:  "critical",
+		State:     state,
+		Value:     97.5,
+		Threshold: 90,
+		Timestamp: 1700000000,
+	}
+}
+
+// capture is an HTTP stand-in recording the request bodies it receives.
+type capture struct {
+	mu     sync.Mutex
+	bodies []string
+	header http.Header
+	status int
+}
+
+func newCapture(t *testing.T, status int) (*capture, string) {
+	t.Helper()
+	c := &capture{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		c.bodies = append(c.bodies, string(body))
+		c.header = r.Header.Clone()
+		c.mu.Unlock()
+		w.WriteHeader(c.status)
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv.URL
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	c, url := newCapture(t, http.StatusOK)
+	n, err := NewNotifier(configs.NotifierConfig{Type: "webhook", URL: url, Headers: map[string]string{"X-Token": "abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testEvent("firing")); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var got models.AlertEvent
+	if err := json.Unmarshal([]byte(c.bodies[0]), &got); err != nil {
+		t.Fatalf("webhook body is not the event: %v", err)
+	}
+	if got.Rule != "cpu_critical" || got.State != "firing" {
+		t.Fatalf("event = %+v", got)
+	}
+	if c.header.Get("X-Token") != "abc" {
+		t.Fatalf("custom header not sent: %v", c.header)
+	}
+}
+
+func TestWebhookNotifierTemplateAndStatus(t *testing.T) {
+	c, url := newCapture(t, http.StatusOK)
+	n, err := NewNotifier(configs.NotifierConfig{Type: "webhook", URL: url, Template: "{{.Rule}} {{.State}} at {{time .Timestamp}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testEvent("resolved")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "cpu_critical resolved at 2023-11-14T22:13:20Z"; c.bodies[0] != want {
+		t.Fatalf("body = %q, want %q", c.bodies[0], want)
+	}
+
+	c.status = http.StatusBadGateway
+	if err := n.Notify(testEvent("firing")); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("want status error, got %v", err)
+	}
+}
+
+func TestChatNotifier(t *testing.T) {
+	for _, format := range []string{"slack", "teams"} {
+		t.Run(format, func(t *testing.T) {
+			c, url := newCapture(t, http.StatusOK)
+			n, err := NewNotifier(configs.NotifierConfig{Type: format, URL: url})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := n.Notify(testEvent("firing")); err != nil {
+				t.Fatal(err)
+			}
+
+			var payload map[string]any
+			if err := json.Unmarshal([]byte(c.bodies[0]), &payload); err != nil {
+				t.Fatal(err)
+			}
+			text, _ := payload["text"].(string)
+			if !strings.Contains(text, "cpu_critical firing on web-1") {
+				t.Fatalf("text = %q", text)
+			}
+			if _, card := payload["@type"]; card != (format == "teams") {
+				t.Fatalf("payload = %v", payload)
+			}
+		})
+	}
+}
+
+func TestNewNotifierValidation(t *testing.T) {
+	for _, cfg := range []configs.NotifierConfig{
+		{Type: "webhook"},
+		{Type: "slack"},
+		{Type: "email", SMTPAddr: "127.0.0.1:25", From: "agent@example.com"},
+		{Type: "pager"},
+		{Type: "webhook", URL: "http://x", Template: "{{.Rule"},
+	} {
+		if _, err := NewNotifier(cfg); err == nil {
+			t.Errorf("NewNotifier(%+v) accepted an invalid config", cfg)
+		}
+	}
+}
+
+// smtpServer is a minimal SMTP stand-in that accepts one message per
+// connection and records it.
+type smtpServer struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	auth     string
+	from     string
+	rcpts    []string
+	messages []string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 stand-in ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-stand-in")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			_, cred, _ := strings.Cut(arg, " ")
+			dec, _ := base64.StdEncoding.DecodeString(cred)
+			s.mu.Lock()
+			s.auth = string(dec)
+			s.mu.Unlock()
+			reply("235 ok")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = arg
+			s.mu.Unlock()
+			reply("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, arg)
+			s.mu.Unlock()
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	srv := newSMTPServer(t)
+	n, err := NewNotifier(configs.NotifierConfig{
+		Type:     "email",
+		SMTPAddr: srv.ln.Addr().String(),
+		Username: "agent",
+		Password: "s3cret",
+		From:     "agent@example.com",
+		To:       []string{"ops@example.com", "oncall@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testEvent("firing")); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.auth != "\x00agent\x00s3cret" {
+		t.Fatalf("auth = %q", srv.auth)
+	}
+	if srv.from != "FROM:<agent@example.com>" || len(srv.rcpts) != 2 {
+		t.Fatalf("envelope = %q %q", srv.from, srv.rcpts)
+	}
+	if len(srv.messages) != 1 || !strings.Contains(srv.messages[0], "Subject: [CRITICAL] cpu_critical firing on web-1") {
+		t.Fatalf("messages = %q", srv.messages)
+	}
+}
+
+func TestEmailNotifierTimeout(t *testing.T) {
+	// accepts connections but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	n := &EmailNotifier{name: "email", Addr: ln.Addr().String(), From: "a@example.com", To: []string{"b@example.com"}, Timeout: 200 * time.Millisecond}
+	start := time.Now()
+	err = n.Notify(testEvent("firing"))
+	if err == nil {
+		t.Fatal("stalled server did not fail the delivery")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("delivery took %v despite the timeout", elapsed)
+	}
+}
+
+func TestChannelDedupsRepeatsOnly(t *testing.T) {
+	c := &channel{
+		notifier:  &WebhookNotifier{name: "test"},
+		rateLimit: 100,
+		dedup:     15 * time.Minute,
+		lastSent:  make(map[string]sentState),
+	}
+
+	var got []string
+	for _, state := range []string{"firing", "firing", "resolved", "firing", "resolved", "resolved"} {
+		if c.allow(testEvent(state)) {
+			got = append(got, state)
+		}
+	}
+	if want := "firing,resolved,firing,resolved"; strings.Join(got, ",") != want {
+		t.Fatalf("sent %v, want %s", got, want)
+	}
+
+	// another instance of the same rule is tracked on its own
+	other := testEvent("resolved")
+	other.Instance = "/data"
+	if !c.allow(other) {
+		t.Fatal("event for another instance was deduplicated")
+	}
+}
+
+func TestChannelRateLimit(t *testing.T) {
+	c := &channel{
+		notifier:  &WebhookNotifier{name: "test"},
+		rateLimit: 2,
+		dedup:     15 * time.Minute,
+		lastSent:  make(map[string]sentState),
+	}
+	sent := 0
+	for i := 0; i < 5; i++ {
+		e := testEvent("firing")
+		e.Instance = string(rune('a' + i))
+		if c.allow(e) {
+			sent++
+		}
+	}
+	if sent != 2 {
+		t.Fatalf("sent %d events, want the rate limit of 2", sent)
+	}
+}
+
+// fakeNotifier fails a configured number of times before succeeding.
+type fakeNotifier struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	done     chan *models.AlertEvent
+}
+
+func (f *fakeNotifier) Name() string { return "fake" }
+
+func (f *fakeNotifier) Notify(event *models.AlertEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return io.ErrUnexpectedEOF
+	}
+	f.done <- event
+	return nil
+}
+
+func TestDispatcherRetriesAndFilters(t *testing.T) {
+	f := &fakeNotifier{failures: 2, done: make(chan *models.AlertEvent, 4)}
+	d := &Dispatcher{slaFiring: make(map[string]bool)}
+	d.Add(f, configs.NotifierConfig{MinSeverity: "critical", Retries: 3})
+	d.channels[0].backoff = time.Millisecond
+
+	warning := testEvent("firing")
+	warning.Severity = "warning"
+	d.Notify([]*models.AlertEvent{warning, testEvent("firing")})
+
+	select {
+	case e := <-f.done:
+		if e.Severity != "critical" {
+			t.Fatalf("delivered %s event below min_severity", e.Severity)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered after retries")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls != 3 {
+		t.Fatalf("calls = %d, want 2 failures and 1 success", f.calls)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package notify
+
+import (
+	"iDevopzAgent/models"
+	"log/syslog"
+	"text/template"
+)
+
+// SyslogNotifier writes the event to the local syslog daemon, or to a remote
+// one when a network and address are configured.
+type SyslogNotifier struct {
+	name     string
+	writer   *syslog.Writer
+	Template *template.Template
+}
+
+func newSyslogNotifier(name, network, address, tag string, tmpl *template.Template) (Notifier, error) {
+	if tag == "" {
+		tag = "idevopzagent"
+	}
+	w, err := syslog.Dial(network, address, syslog.LOG_WARNING|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogNotifier{name: name, writer: w, Template: tmpl}, nil
+}
+
+func (s *SyslogNotifier) Name() string { return s.name }
+
+func (s *SyslogNotifier) Notify(event *models.AlertEvent) error {
+	text, err := render(s.Template, event)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case event.State == "resolved":
+		return s.writer.Notice(text)
+	case event.Severity == "critical":
+		return s.writer.Crit(text)
+	default:
+		return s.writer.Warning(text)
+	}
+}
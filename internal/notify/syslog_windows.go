@@ -0,0 +1,13 @@
+//go:build windows
+// +build windows
+
+package notify
+
+import (
+	"fmt"
+	"text/template"
+)
+
+func newSyslogNotifier(name, network, address, tag string, tmpl *template.Template) (Notifier, error) {
+	return nil, fmt.Errorf("notifier %q: syslog is not supported on windows", name)
+}
@@ -7,4 +7,5 @@ type Collector interface {
 	ListAllProcesses(userID string, machineId string) ([]*models.ProcessInfo, error)
 	ListTop5MemoryProcess(userID string, machineId string) ([]*models.Process, error)
 	ListTop5CpuProcess(userID string, machineId string) ([]*models.Process, error)
+	ListProcessGroups(userID string, machineId string) ([]*models.ProcessGroup, error)
 }
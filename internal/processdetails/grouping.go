@@ -0,0 +1,239 @@
+package processdetails
+
+import (
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/models"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var log = logging.For("processdetails")
+
+// processSample is the per-process data the grouping needs, filled in by the
+// platform collectors.
+type processSample struct {
+	PID         int32
+	PPID        int32
+	Name        string
+	Exe         string
+	Cmdline     string
+	Username    string
+	Unit        string // systemd unit or cgroup path, linux only
+	CPUPercent  float64
+	MemPercent  float32
+	RSS         uint64
+	ReadBytes   uint64
+	WriteBytes  uint64
+	FDCount     uint32
+	ThreadCount int32
+}
+
+// processNode is one process in the parent/child tree.
+type processNode struct {
+	sample   *processSample
+	parent   *processNode
+	children []*processNode
+
+	group   string
+	groupBy string
+}
+
+type groupRule struct {
+	name  string
+	field string
+	re    *regexp.Regexp
+}
+
+// buildProcessTree links every sample to its parent and returns the roots,
+// i.e. processes whose parent is not part of the snapshot or that are part
+// of a parent cycle.
+func buildProcessTree(samples []*processSample) []*processNode {
+	nodes := make(map[int32]*processNode, len(samples))
+	for _, s := range samples {
+		nodes[s.PID] = &processNode{sample: s}
+	}
+
+	var roots []*processNode
+	for _, s := range samples {
+		node := nodes[s.PID]
+		parent, ok := nodes[s.PPID]
+		if !ok || s.PPID == s.PID {
+			roots = append(roots, node)
+			continue
+		}
+		node.parent = parent
+		parent.children = append(parent.children, node)
+	}
+
+	// processes in a parent cycle, e.g. after PID reuse between reads, are
+	// not reachable from any root; cut each cycle and make it a root
+	visited := make(map[int32]bool, len(nodes))
+	var mark func(node *processNode)
+	mark = func(node *processNode) {
+		visited[node.sample.PID] = true
+		for _, child := range node.children {
+			mark(child)
+		}
+	}
+	for _, root := range roots {
+		mark(root)
+	}
+	for _, s := range samples {
+		if visited[s.PID] {
+			continue
+		}
+		node := nodes[s.PID]
+		siblings := node.parent.children
+		for i, c := range siblings {
+			if c == node {
+				node.parent.children = append(siblings[:i:i], siblings[i+1:]...)
+				break
+			}
+		}
+		node.parent = nil
+		roots = append(roots, node)
+		mark(node)
+	}
+
+	sort.Slice(roots, func(i, j int) bool { return roots[i].sample.PID < roots[j].sample.PID })
+	return roots
+}
+
+func compileGroupRules(rules []configs.ProcessGroupRule) []groupRule {
+	var compiled []groupRule
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			log.Warn("skipping process group rule", "rule", r.Name, "err", err)
+			continue
+		}
+		field := r.Field
+		if field == "" {
+			field = "name"
+		}
+		compiled = append(compiled, groupRule{name: r.Name, field: field, re: re})
+	}
+	return compiled
+}
+
+func (r groupRule) matches(s *processSample) bool {
+	switch r.field {
+	case "cmdline":
+		return r.re.MatchString(s.Cmdline)
+	case "exe":
+		return r.re.MatchString(s.Exe)
+	case "user":
+		return r.re.MatchString(s.Username)
+	default:
+		return r.re.MatchString(s.Name)
+	}
+}
+
+// exeGroupName returns the executable name used for "exe" grouping.
+func exeGroupName(s *processSample) string {
+	if s.Exe != "" {
+		name := filepath.Base(s.Exe)
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if s.Name != "" {
+		return strings.TrimSuffix(s.Name, ".exe")
+	}
+	return fmt.Sprintf("pid-%d", s.PID)
+}
+
+// assignGroups walks the tree top-down. A rule match wins; children of a
+// rule-matched process inherit its group so forked workers stay together.
+func assignGroups(node *processNode, rules []groupRule, groupBy string) {
+	s := node.sample
+	matched := false
+	for _, r := range rules {
+		if r.matches(s) {
+			node.group, node.groupBy = r.name, "rule"
+			matched = true
+			break
+		}
+	}
+
+	if !matched {
+		switch {
+		case node.parent != nil && node.parent.groupBy == "rule":
+			node.group, node.groupBy = node.parent.group, "rule"
+		case groupBy == "unit" && s.Unit != "":
+			node.group, node.groupBy = s.Unit, "unit"
+		default:
+			node.group, node.groupBy = exeGroupName(s), "exe"
+		}
+	}
+
+	for _, child := range node.children {
+		assignGroups(child, rules, groupBy)
+	}
+}
+
+// groupProcesses builds the process tree from samples and rolls up resource
+// usage per application group, ordered by CPU usage.
+func groupProcesses(samples []*processSample, userID, machineId, hostname string) []*models.ProcessGroup {
+	settings := configs.LoadSettings()
+	rules := compileGroupRules(settings.ProcessGroupRules)
+
+	roots := buildProcessTree(samples)
+	for _, root := range roots {
+		assignGroups(root, rules, settings.ProcessGroupBy)
+	}
+
+	now := time.Now().Unix()
+	groups := make(map[string]*models.ProcessGroup)
+	var visit func(node *processNode)
+	visit = func(node *processNode) {
+		s := node.sample
+		key := node.groupBy + "/" + node.group
+		g, ok := groups[key]
+		if !ok {
+			g = &models.ProcessGroup{
+				UserID:    userID,
+				MachineID: machineId,
+				Hostname:  hostname,
+				Group:     node.group,
+				GroupBy:   node.groupBy,
+				Timestamp: now,
+			}
+			groups[key] = g
+		}
+
+		g.ProcessCount++
+		g.CPUPercent += s.CPUPercent
+		g.MemoryPercent += s.MemPercent
+		g.MemoryRSS += s.RSS
+		g.ReadBytes += s.ReadBytes
+		g.WriteBytes += s.WriteBytes
+		g.FDCount += s.FDCount
+		g.ThreadCount += s.ThreadCount
+		if node.parent == nil || node.parent.group != node.group || node.parent.groupBy != node.groupBy {
+			g.RootPIDs = append(g.RootPIDs, s.PID)
+		}
+
+		for _, child := range node.children {
+			visit(child)
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+
+	result := make([]*models.ProcessGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CPUPercent != result[j].CPUPercent {
+			return result[i].CPUPercent > result[j].CPUPercent
+		}
+		return result[i].Group < result[j].Group
+	})
+	return result
+}
@@ -37,6 +37,7 @@ func (l LinuxCollector) ListAllProcesses(userID string, machineId string) ([]*mo
 		memPct, _ := p.MemoryPercent()
 		threads, _ := p.NumThreads()
 		priority, _ := p.Nice()
+		ppid, _ := p.Ppid()
 
 		var handles uint32
 
@@ -50,6 +51,7 @@ func (l LinuxCollector) ListAllProcesses(userID string, machineId string) ([]*mo
 			MachineID:     machineId,
 			Hostname:      hostname,
 			PID:           p.Pid,
+			ParentPID:     ppid,
 			Name:          name,
 			Username:      username,
 			CPUPercent:    cpuPct,
@@ -65,6 +67,93 @@ func (l LinuxCollector) ListAllProcesses(userID string, machineId string) ([]*mo
 	return results, nil
 }
 
+// List processes rolled up by application group
+func (l LinuxCollector) ListProcessGroups(userID string, machineId string) ([]*models.ProcessGroup, error) {
+	hostname, err := utils.GetHostName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host info: %w", err)
+	}
+
+	procs, err := utils.GetAllProcesses()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processes: %w", err)
+	}
+
+	var samples []*processSample
+	for _, p := range procs {
+		name, err := p.Name()
+		if err != nil {
+			continue // process exited while listing
+		}
+		ppid, _ := p.Ppid()
+		exe, _ := p.Exe()
+		cmdline, _ := p.Cmdline()
+		username, _ := p.Username()
+		cpuPct, _ := p.CPUPercent()
+		memPct, _ := p.MemoryPercent()
+		threads, _ := p.NumThreads()
+
+		sample := &processSample{
+			PID:         p.Pid,
+			PPID:        ppid,
+			Name:        name,
+			Exe:         exe,
+			Cmdline:     cmdline,
+			Username:    username,
+			Unit:        getSystemdUnit(p.Pid),
+			CPUPercent:  cpuPct,
+			MemPercent:  memPct,
+			ThreadCount: threads,
+		}
+		if mem, err := p.MemoryInfo(); err == nil {
+			sample.RSS = mem.RSS
+		}
+		if io, err := p.IOCounters(); err == nil {
+			sample.ReadBytes = io.ReadBytes
+			sample.WriteBytes = io.WriteBytes
+		}
+		if fds, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", p.Pid)); err == nil {
+			sample.FDCount = uint32(len(fds))
+		}
+		samples = append(samples, sample)
+	}
+
+	return groupProcesses(samples, userID, machineId, hostname), nil
+}
+
+// getSystemdUnit returns the systemd unit owning pid, or its cgroup path when
+// the process is not in a unit (e.g. containers).
+func getSystemdUnit(pid int32) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+
+	var cgroupPath string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" || strings.Contains(parts[1], "name=systemd") {
+			cgroupPath = parts[2]
+			break
+		}
+	}
+	if cgroupPath == "" || cgroupPath == "/" {
+		return ""
+	}
+
+	elems := strings.Split(cgroupPath, "/")
+	for i := len(elems) - 1; i >= 0; i-- {
+		if strings.HasSuffix(elems[i], ".service") || strings.HasSuffix(elems[i], ".scope") {
+			return elems[i]
+		}
+	}
+	return cgroupPath
+}
+
 //top 5 cpu process
 
 func (l LinuxCollector) ListTop5CpuProcess(userID string, machineId string) ([]*models.Process, error) {
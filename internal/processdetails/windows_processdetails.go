@@ -66,6 +66,7 @@ func (w WindowsCollector) ListAllProcesses(userID string, machineId string) ([]*
 		memPct, _ := p.MemoryPercent()
 		threads, _ := p.NumThreads()
 		priority, _ := p.Nice()
+		ppid, _ := p.Ppid()
 		path, err := p.Exe()
 		if err != nil {
 			// Skip system processes that don't have accessible paths (like PID 0)
@@ -93,6 +94,7 @@ func (w WindowsCollector) ListAllProcesses(userID string, machineId string) ([]*
 			Path:          path,
 			Hostname:      hostname,
 			PID:           p.Pid,
+			ParentPID:     ppid,
 			Name:          name,
 			Username:      username,
 			CPUPercent:    cpuPct,
@@ -108,6 +110,59 @@ func (w WindowsCollector) ListAllProcesses(userID string, machineId string) ([]*
 	return results, nil
 }
 
+// List processes rolled up by application group
+func (w WindowsCollector) ListProcessGroups(userID string, machineId string) ([]*models.ProcessGroup, error) {
+	hostname, err := utils.GetHostName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host info: %w", err)
+	}
+
+	procs, err := utils.GetAllProcesses()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processes: %w", err)
+	}
+
+	var samples []*processSample
+	for _, p := range procs {
+		name, err := p.Name()
+		if err != nil {
+			continue // process exited while listing
+		}
+		ppid, _ := p.Ppid()
+		exe, _ := p.Exe()
+		cmdline, _ := p.Cmdline()
+		username, _ := p.Username()
+		cpuPct, _ := p.CPUPercent()
+		memPct, _ := p.MemoryPercent()
+		threads, _ := p.NumThreads()
+
+		sample := &processSample{
+			PID:         p.Pid,
+			PPID:        ppid,
+			Name:        name,
+			Exe:         exe,
+			Cmdline:     cmdline,
+			Username:    username,
+			CPUPercent:  cpuPct,
+			MemPercent:  memPct,
+			ThreadCount: threads,
+		}
+		if mem, err := p.MemoryInfo(); err == nil {
+			sample.RSS = mem.RSS
+		}
+		if io, err := p.IOCounters(); err == nil {
+			sample.ReadBytes = io.ReadBytes
+			sample.WriteBytes = io.WriteBytes
+		}
+		if handles, err := GetWindowsHandleCount(p.Pid); err == nil {
+			sample.FDCount = handles
+		}
+		samples = append(samples, sample)
+	}
+
+	return groupProcesses(samples, userID, machineId, hostname), nil
+}
+
 //top 5 cpu process
 
 func (w WindowsCollector) ListTop5CpuProcess(userID string, machineId string) ([]*models.Process, error) {
@@ -0,0 +1,45 @@
+package remote
+
+import (
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// auditEntry is one line of audit.log: every remote config decision and
+// every command the agent was asked to run.
+type auditEntry struct {
+	Time    string            `json:"time"`
+	Kind    string            `json:"kind"` // config, command
+	Version int64             `json:"version,omitempty"`
+	ID      string            `json:"id,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Args    map[string]string `json:"args,omitempty"`
+	Status  string            `json:"status"`
+	Error   string            `json:"error,omitempty"`
+}
+
+var auditMu sync.Mutex
+
+// audit appends e to audit.log in the data directory.
+func audit(e auditEntry) {
+	e.Time = time.Now().UTC().Format(time.RFC3339)
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(configs.DataDir(), "audit.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Error("failed to write audit log", "err", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
@@ -0,0 +1,134 @@
+package remote
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// minInterval guards against a remote config that would busy-loop a
+// collector.
+const minInterval = time.Second
+
+// Job is a periodic collector whose interval and enabled state can be
+// changed by the remote config, and which can be run on demand.
+type Job struct {
+	name            string
+	defaultInterval time.Duration
+
+	mu       sync.Mutex
+	interval time.Duration
+	enabled  bool
+
+	now     chan struct{}
+	changed chan struct{}
+}
+
+var (
+	jobsMu sync.Mutex
+	jobs   = map[string]*Job{}
+)
+
+// RegisterJob returns the job for a collector loop, running every interval
+// until the remote config says otherwise.
+func RegisterJob(name string, interval time.Duration) *Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	if j, ok := jobs[name]; ok {
+		return j
+	}
+	j := &Job{
+		name:            name,
+		defaultInterval: interval,
+		interval:        interval,
+		enabled:         true,
+		now:             make(chan struct{}, 1),
+		changed:         make(chan struct{}, 1),
+	}
+	jobs[name] = j
+	return j
+}
+
+func lookupJob(name string) *Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	return jobs[name]
+}
+
+func jobNames() []string {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	names := make([]string, 0, len(jobs))
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// JobInterval returns the current interval of the named job, or zero when
+// no such job is registered.
+func JobInterval(name string) time.Duration {
+	j := lookupJob(name)
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.interval
+}
+
+// Wait blocks until the job should run: when its interval elapsed while
+// enabled, or when a run was requested. A disabled job only runs on
+// request.
+func (j *Job) Wait() {
+	for {
+		j.mu.Lock()
+		interval, enabled := j.interval, j.enabled
+		j.mu.Unlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+			if enabled {
+				return
+			}
+		case <-j.now:
+			timer.Stop()
+			return
+		case <-j.changed:
+			timer.Stop()
+		}
+	}
+}
+
+// set changes the schedule; a zero interval restores the default.
+func (j *Job) set(interval time.Duration, enabled bool) {
+	if interval == 0 {
+		interval = j.defaultInterval
+	}
+
+	j.mu.Lock()
+	changed := interval != j.interval || enabled != j.enabled
+	j.interval = interval
+	j.enabled = enabled
+	j.mu.Unlock()
+
+	if changed {
+		signal(j.changed)
+	}
+}
+
+// Trigger runs the job as soon as its loop is free.
+func (j *Job) Trigger() {
+	signal(j.now)
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
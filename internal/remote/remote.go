@@ -0,0 +1,394 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/alerting"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/models"
+	"iDevopzAgent/security"
+	"iDevopzAgent/sender"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var log = logging.For("remote")
+
+// maxExecuted bounds the remembered command IDs used to ignore replays.
+const maxExecuted = 500
+
+// DesiredConfig is the part of the agent behaviour the backend controls.
+// It is declarative: a job missing from Intervals runs at its built-in
+// interval, one missing from Collectors is enabled, and an empty AlertRules
+// falls back to the local settings.
+type DesiredConfig struct {
+	Intervals  map[string]int      `json:"intervals"`  // job -> seconds
+	Collectors map[string]bool     `json:"collectors"` // job -> enabled
+	AlertRules []configs.AlertRule `json:"alert_rules,omitempty"`
+}
+
+// Command is a one-off action requested by the backend.
+type Command struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Args      map[string]string `json:"args,omitempty"`
+	ExpiresAt int64             `json:"expires_at,omitempty"`
+}
+
+// Document is the signed desired-config document. MachineID is empty for
+// documents addressed to every agent.
+type Document struct {
+	Version   int64          `json:"version"`
+	MachineID string         `json:"machineId,omitempty"`
+	IssuedAt  int64          `json:"issued_at"`
+	Config    *DesiredConfig `json:"config,omitempty"`
+	Commands  []Command      `json:"commands,omitempty"`
+}
+
+// signedDocument is the response body: the document exactly as signed,
+// and the base64 ed25519 signature over those bytes.
+type signedDocument struct {
+	Document  json.RawMessage `json:"document"`
+	Signature string          `json:"signature"`
+}
+
+// pollerState is persisted to remote_state.json so the applied config and
+// the executed commands survive restarts. Applied is the response body as
+// received; as []byte it is stored base64 and the signed bytes stay intact.
+// IssuedAt is the issue time of the newest document accepted, so an older
+// signed document cannot be replayed once its command IDs have been
+// forgotten.
+type pollerState struct {
+	Applied  []byte   `json:"applied,omitempty"`
+	Version  int64    `json:"version"`
+	IssuedAt int64    `json:"issued_at,omitempty"`
+	Executed []string `json:"executed"`
+}
+
+// Handler runs a command and returns why it failed, if it did.
+type Handler func(args map[string]string) error
+
+// Poller fetches the desired config from the backend, applies it and runs
+// the commands it carries.
+type Poller struct {
+	mu        sync.Mutex
+	machineID string
+	statePath string
+	state     pollerState
+	handlers  map[string]Handler
+}
+
+var (
+	pollerOnce sync.Once
+	poller     *Poller
+)
+
+// GetPoller returns the process-wide poller with the built-in commands.
+func GetPoller() *Poller {
+	pollerOnce.Do(func() {
+		poller = &Poller{
+			statePath: filepath.Join(configs.DataDir(), "remote_state.json"),
+			handlers:  map[string]Handler{},
+		}
+		poller.Handle("collect_now", collectNow)
+		poller.Handle("send_full_process_list", sendFullProcessList)
+	})
+	return poller
+}
+
+// Handle registers the handler for a command name.
+func (p *Poller) Handle(name string, h Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[name] = h
+}
+
+// Run re-applies the last accepted config and then polls forever.
+func (p *Poller) Run(machineID string) {
+	settings := configs.LoadSettings().Remote
+	if !settings.Enabled {
+		return
+	}
+	if publicKey() == "" {
+		log.Warn("remote config disabled: no backend public key configured")
+		return
+	}
+
+	p.mu.Lock()
+	p.machineID = machineID
+	p.load()
+	p.mu.Unlock()
+
+	poll := time.Duration(settings.PollSeconds) * time.Second
+	if poll < minInterval {
+		poll = minInterval
+	}
+
+	for {
+		p.mu.Lock()
+		version := p.state.Version
+		p.mu.Unlock()
+
+		started := time.Now()
+		body, err := sender.FetchRemoteConfig(machineID, version, settings.LongPollSeconds)
+		if err != nil {
+			log.Error("fetch remote config failed", "err", err)
+		} else if body != nil {
+			p.Process(body)
+		}
+
+		// a long poll that returned early still waits out the poll
+		// interval, so a misbehaving backend cannot spin the agent
+		if settings.LongPollSeconds == 0 || err != nil || time.Since(started) < minInterval {
+			time.Sleep(poll)
+		}
+	}
+}
+
+// Process verifies a fetched document, applies its config if it is newer
+// than the applied one and runs its new commands.
+func (p *Poller) Process(body []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	doc, err := p.verify(body)
+	if err != nil {
+		log.Warn("rejected remote config", "version", doc.Version, "err", err)
+		audit(auditEntry{Kind: "config", Version: doc.Version, Status: "rejected", Error: err.Error()})
+		p.report(doc.Version, "rejected", err)
+		return
+	}
+
+	if doc.Version < p.state.Version {
+		err := fmt.Errorf("version %d is older than applied version %d", doc.Version, p.state.Version)
+		log.Warn("rejected remote config", "version", doc.Version, "err", err)
+		audit(auditEntry{Kind: "config", Version: doc.Version, Status: "rejected", Error: err.Error()})
+		p.report(doc.Version, "rejected", err)
+		return
+	}
+
+	if doc.IssuedAt < p.state.IssuedAt {
+		err := fmt.Errorf("document issued at %d is older than the last accepted one issued at %d", doc.IssuedAt, p.state.IssuedAt)
+		log.Warn("rejected remote config", "version", doc.Version, "err", err)
+		audit(auditEntry{Kind: "config", Version: doc.Version, Status: "rejected", Error: err.Error()})
+		p.report(doc.Version, "rejected", err)
+		return
+	}
+	if doc.IssuedAt > p.state.IssuedAt {
+		p.state.IssuedAt = doc.IssuedAt
+		p.save()
+	}
+
+	if doc.Version > p.state.Version && doc.Config != nil {
+		if err := apply(doc.Config); err != nil {
+			log.Warn("rejected remote config", "version", doc.Version, "err", err)
+			audit(auditEntry{Kind: "config", Version: doc.Version, Status: "rejected", Error: err.Error()})
+			p.report(doc.Version, "rejected", err)
+		} else {
+			p.state.Applied = body
+			p.state.Version = doc.Version
+			p.save()
+			log.Info("applied remote config", "version", doc.Version)
+			audit(auditEntry{Kind: "config", Version: doc.Version, Status: "applied"})
+			p.report(doc.Version, "applied", nil)
+		}
+	}
+
+	for _, cmd := range doc.Commands {
+		p.runLocked(cmd)
+	}
+}
+
+// verify checks the signature and the addressee and decodes the document.
+func (p *Poller) verify(body []byte) (Document, error) {
+	var doc Document
+	var signed signedDocument
+	if err := json.Unmarshal(body, &signed); err != nil || len(signed.Document) == 0 {
+		return doc, errors.New("malformed config document")
+	}
+	// decode first so a rejection can still name the version
+	if err := json.Unmarshal(signed.Document, &doc); err != nil {
+		return doc, fmt.Errorf("malformed config document: %w", err)
+	}
+	if err := security.VerifyEd25519(publicKey(), signed.Document, signed.Signature); err != nil {
+		return doc, fmt.Errorf("config document signature: %w", err)
+	}
+	if doc.MachineID != "" && doc.MachineID != p.machineID {
+		return doc, fmt.Errorf("config document is addressed to machine %s", doc.MachineID)
+	}
+	return doc, nil
+}
+
+func (p *Poller) runLocked(cmd Command) {
+	for _, id := range p.state.Executed {
+		if id == cmd.ID {
+			return
+		}
+	}
+
+	result := &models.CommandResult{
+		MachineID: p.machineID,
+		CommandID: cmd.ID,
+		Name:      cmd.Name,
+		StartedAt: time.Now().Unix(),
+	}
+
+	handler, ok := p.handlers[cmd.Name]
+	switch {
+	case cmd.ID == "":
+		log.Warn("ignoring command without an id", "command", cmd.Name)
+		return
+	case cmd.ExpiresAt > 0 && time.Now().Unix() > cmd.ExpiresAt:
+		result.Status = "rejected"
+		result.Error = "command expired"
+	case !ok:
+		result.Status = "rejected"
+		result.Error = "unknown command"
+	default:
+		log.Info("running remote command", "command", cmd.Name, "id", cmd.ID)
+		if err := handler(cmd.Args); err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+		} else {
+			result.Status = "succeeded"
+		}
+	}
+	result.FinishedAt = time.Now().Unix()
+
+	p.state.Executed = append(p.state.Executed, cmd.ID)
+	if len(p.state.Executed) > maxExecuted {
+		p.state.Executed = p.state.Executed[len(p.state.Executed)-maxExecuted:]
+	}
+	p.save()
+
+	audit(auditEntry{Kind: "command", ID: cmd.ID, Name: cmd.Name, Args: cmd.Args, Status: result.Status, Error: result.Error})
+	go sender.SendCommandResult(result)
+}
+
+func (p *Poller) report(version int64, status string, err error) {
+	s := &models.RemoteConfigStatus{
+		MachineID: p.machineID,
+		Version:   version,
+		Status:    status,
+		Timestamp: time.Now().Unix(),
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	go sender.SendConfigStatus(s)
+}
+
+// load restores the persisted state and re-applies the last accepted
+// config, verifying it again since the file is outside our control.
+func (p *Poller) load() {
+	data, err := os.ReadFile(p.statePath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &p.state); err != nil {
+		log.Warn("could not parse remote config state", "err", err)
+		p.state = pollerState{}
+		return
+	}
+	if len(p.state.Applied) == 0 {
+		return
+	}
+
+	doc, err := p.verify(p.state.Applied)
+	if err != nil || doc.Config == nil {
+		log.Warn("stored remote config is invalid, using local settings", "err", err)
+		p.state.Applied = nil
+		p.state.Version = 0
+		return
+	}
+	if err := apply(doc.Config); err != nil {
+		log.Warn("could not re-apply stored remote config", "err", err)
+		return
+	}
+	log.Info("re-applied remote config", "version", doc.Version)
+}
+
+func (p *Poller) save() {
+	data, err := json.MarshalIndent(&p.state, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := p.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Error("failed to save remote config state", "err", err)
+		return
+	}
+	if err := os.Rename(tmp, p.statePath); err != nil {
+		log.Error("failed to save remote config state", "err", err)
+	}
+}
+
+// apply validates cfg completely before changing anything, so a bad
+// document leaves the running config untouched.
+func apply(cfg *DesiredConfig) error {
+	for name, seconds := range cfg.Intervals {
+		if lookupJob(name) == nil {
+			return fmt.Errorf("unknown collector %q in intervals", name)
+		}
+		if time.Duration(seconds)*time.Second < minInterval {
+			return fmt.Errorf("interval for %s must be at least %s", name, minInterval)
+		}
+	}
+	for name := range cfg.Collectors {
+		if lookupJob(name) == nil {
+			return fmt.Errorf("unknown collector %q in collectors", name)
+		}
+	}
+
+	for _, name := range jobNames() {
+		enabled, ok := cfg.Collectors[name]
+		if !ok {
+			enabled = true
+		}
+		lookupJob(name).set(time.Duration(cfg.Intervals[name])*time.Second, enabled)
+	}
+
+	if len(cfg.AlertRules) > 0 {
+		alerting.GetEngine().SetRules(cfg.AlertRules)
+	} else {
+		alerting.GetEngine().SetRules(configs.LoadSettings().AlertRules)
+	}
+	return nil
+}
+
+func publicKey() string {
+	if key := configs.LoadSettings().Remote.PublicKey; key != "" {
+		return key
+	}
+	return security.BackendPublicKey
+}
+
+// collectNow runs one collector, or all of them without a "collector"
+// argument.
+func collectNow(args map[string]string) error {
+	name := args["collector"]
+	if name == "" || name == "all" {
+		for _, n := range jobNames() {
+			lookupJob(n).Trigger()
+		}
+		return nil
+	}
+	j := lookupJob(name)
+	if j == nil {
+		return fmt.Errorf("unknown collector %q", name)
+	}
+	j.Trigger()
+	return nil
+}
+
+func sendFullProcessList(map[string]string) error {
+	sender.ResyncProcessList()
+	if j := lookupJob("process_details"); j != nil {
+		j.Trigger()
+	}
+	return nil
+}
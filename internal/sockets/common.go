@@ -0,0 +1,150 @@
+package sockets
+
+import (
+	"encoding/json"
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var log = logging.For("sockets")
+
+// listenerTracker remembers the listeners seen by the previous collection,
+// persisted to listeners.json so listeners opened while the agent was
+// stopped are still reported.
+type listenerTracker struct {
+	mu     sync.Mutex
+	path   string
+	loaded bool
+	known  map[string]models.ListeningSocket
+	listen int // TCP listeners seen by the last collection
+}
+
+var tracker = &listenerTracker{
+	path: filepath.Join(configs.DataDir(), "listeners.json"),
+}
+
+// listenerKey identifies a listener by protocol, address and port; the
+// owning process may change across restarts of the same service.
+func listenerKey(s models.ListeningSocket) string {
+	return fmt.Sprintf("%s %s:%d", s.Protocol, s.Address, s.Port)
+}
+
+// Collect lists the listening sockets and TCP state counts, and returns
+// events for listeners that opened or closed since the previous call. The
+// very first collection on a host only records the baseline.
+func Collect(userID, machineID, hostname string) (*models.SocketInventory, []*models.ListenerEvent, error) {
+	listeners, counts, err := listSockets(configs.LoadSettings().HostRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(listeners, func(i, j int) bool {
+		return listenerKey(listeners[i]) < listenerKey(listeners[j])
+	})
+
+	now := time.Now().Unix()
+	inv := &models.SocketInventory{
+		UserID:      userID,
+		MachineID:   machineID,
+		Hostname:    hostname,
+		Listeners:   listeners,
+		StateCounts: counts,
+		Timestamp:   now,
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.load()
+	tracker.listen = counts["LISTEN"]
+
+	current := make(map[string]models.ListeningSocket, len(listeners))
+	for _, l := range listeners {
+		current[listenerKey(l)] = l
+	}
+
+	var events []*models.ListenerEvent
+	event := func(kind string, s models.ListeningSocket) {
+		events = append(events, &models.ListenerEvent{
+			UserID:    userID,
+			MachineID: machineID,
+			Hostname:  hostname,
+			Event:     kind,
+			Socket:    s,
+			Timestamp: now,
+		})
+	}
+	if tracker.known != nil {
+		for _, l := range listeners {
+			if _, ok := tracker.known[listenerKey(l)]; !ok {
+				event("opened", l)
+			}
+		}
+		closed := make([]string, 0)
+		for key := range tracker.known {
+			if _, ok := current[key]; !ok {
+				closed = append(closed, key)
+			}
+		}
+		sort.Strings(closed)
+		for _, key := range closed {
+			event("closed", tracker.known[key])
+		}
+	}
+
+	if tracker.known == nil || len(events) > 0 {
+		tracker.known = current
+		tracker.save()
+	}
+	return inv, events, nil
+}
+
+// ListenCount returns the number of listening TCP sockets seen by the last
+// collection, or 0 before the first one; the system counters report it
+// instead of walking the socket tables on every interval.
+func ListenCount() int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return tracker.listen
+}
+
+func (t *listenerTracker) load() {
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return
+	}
+	var known []models.ListeningSocket
+	if err := json.Unmarshal(data, &known); err != nil {
+		log.Warn("ignoring corrupt listener state", "err", err)
+		return
+	}
+	t.known = make(map[string]models.ListeningSocket, len(known))
+	for _, s := range known {
+		t.known[listenerKey(s)] = s
+	}
+}
+
+func (t *listenerTracker) save() {
+	known := make([]models.ListeningSocket, 0, len(t.known))
+	for _, s := range t.known {
+		known = append(known, s)
+	}
+	data, err := json.Marshal(known)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(t.path), 0700)
+	if err := os.WriteFile(t.path, data, 0600); err != nil {
+		log.Error("failed to save listener state", "err", err)
+	}
+}
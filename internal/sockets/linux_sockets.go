@@ -0,0 +1,220 @@
+//go:build linux
+// +build linux
+
+package sockets
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"iDevopzAgent/models"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tcpStates maps the kernel's hex state codes in /proc/net/tcp.
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+	"0C": "NEW_SYN_RECV",
+}
+
+const (
+	stateListen = "0A"
+	stateClose  = "07" // an unconnected UDP socket
+)
+
+// procSocket is one line of /proc/net/{tcp,udp}[6].
+type procSocket struct {
+	protocol   string
+	localIP    net.IP
+	localPort  uint16
+	remotePort uint16
+	remoteIP   net.IP
+	state      string
+	uid        string
+	inode      string
+}
+
+// listSockets reads the socket tables below root and resolves the owner of
+// every listener through the fd links in /proc/<pid>/fd. The tables are
+// taken from /proc/1/net: /proc/net follows the reading process, so a
+// containerised agent would otherwise see its own network namespace
+// instead of the host's.
+func listSockets(root string) ([]models.ListeningSocket, map[string]int, error) {
+	netDir := filepath.Join(root, "proc/1/net")
+	counts := make(map[string]int)
+	var listening []procSocket
+	read := 0
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		socks, err := readProcNet(filepath.Join(netDir, proto), proto)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // no IPv6
+			}
+			return nil, nil, err
+		}
+		read++
+
+		for _, s := range socks {
+			tcp := strings.HasPrefix(proto, "tcp")
+			switch {
+			case tcp:
+				counts[tcpStates[s.state]]++
+				if s.state == stateListen {
+					listening = append(listening, s)
+				}
+			case s.state == stateClose && s.remotePort == 0 && s.remoteIP.IsUnspecified():
+				listening = append(listening, s)
+			}
+		}
+	}
+	if read == 0 {
+		return nil, nil, fmt.Errorf("no socket tables under %s", netDir)
+	}
+
+	inodes := make(map[string]bool, len(listening))
+	for _, s := range listening {
+		inodes[s.inode] = true
+	}
+	owners := socketOwners(root, inodes)
+
+	users := make(map[string]string)
+	listeners := make([]models.ListeningSocket, 0, len(listening))
+	for _, s := range listening {
+		l := models.ListeningSocket{
+			Protocol: s.protocol,
+			Address:  s.localIP.String(),
+			Port:     s.localPort,
+		}
+		if pid, ok := owners[s.inode]; ok {
+			l.PID = pid
+			l.Process = strings.TrimSpace(readFile(filepath.Join(root, "proc", strconv.Itoa(int(pid)), "comm")))
+		}
+		name, ok := users[s.uid]
+		if !ok {
+			name = s.uid
+			if u, err := user.LookupId(s.uid); err == nil {
+				name = u.Username
+			}
+			users[s.uid] = name
+		}
+		l.User = name
+		listeners = append(listeners, l)
+	}
+	return listeners, counts, nil
+}
+
+func readProcNet(path, proto string) ([]procSocket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var socks []procSocket
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		localIP, localPort, err := parseHexAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		remoteIP, remotePort, err := parseHexAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		socks = append(socks, procSocket{
+			protocol:   proto,
+			localIP:    localIP,
+			localPort:  localPort,
+			remoteIP:   remoteIP,
+			remotePort: remotePort,
+			state:      fields[3],
+			uid:        fields[7],
+			inode:      fields[9],
+		})
+	}
+	return socks, scanner.Err()
+}
+
+// parseHexAddr decodes "0100007F:0016". The address is stored as 32-bit
+// words in host (little endian) byte order.
+func parseHexAddr(s string) (net.IP, uint16, error) {
+	addr, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("bad address %q", s)
+	}
+	raw, err := hex.DecodeString(addr)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return nil, 0, fmt.Errorf("bad address %q", s)
+	}
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("bad port %q", s)
+	}
+	return net.IP(raw), uint16(p), nil
+}
+
+// socketOwners maps socket inodes to the first process holding them. Other
+// users' processes are only visible when the agent runs as root.
+func socketOwners(root string, inodes map[string]bool) map[string]int32 {
+	owners := make(map[string]int32, len(inodes))
+	procs, err := os.ReadDir(filepath.Join(root, "proc"))
+	if err != nil {
+		return owners
+	}
+	for _, p := range procs {
+		pid, err := strconv.ParseInt(p.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join(root, "proc", p.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			if _, seen := owners[inode]; inodes[inode] && !seen {
+				owners[inode] = int32(pid)
+			}
+		}
+		if len(owners) == len(inodes) {
+			break
+		}
+	}
+	return owners
+}
+
+func readFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,64 @@
+//go:build windows
+// +build windows
+
+package sockets
+
+import (
+	"iDevopzAgent/models"
+	"syscall"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// listSockets reads the TCP and UDP tables through the IP helper API.
+// root only applies to Linux.
+func listSockets(root string) ([]models.ListeningSocket, map[string]int, error) {
+	conns, err := psnet.Connections("inet")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counts := make(map[string]int)
+	names := make(map[int32][2]string)
+	var listeners []models.ListeningSocket
+	for _, c := range conns {
+		tcp := c.Type == syscall.SOCK_STREAM
+		if tcp {
+			counts[c.Status]++
+			if c.Status != "LISTEN" {
+				continue
+			}
+		} else if c.Raddr.Port != 0 {
+			continue // connected UDP socket
+		}
+
+		proto := "udp"
+		if tcp {
+			proto = "tcp"
+		}
+		if c.Family == syscall.AF_INET6 {
+			proto += "6"
+		}
+
+		l := models.ListeningSocket{
+			Protocol: proto,
+			Address:  c.Laddr.IP,
+			Port:     uint16(c.Laddr.Port),
+			PID:      c.Pid,
+		}
+		if c.Pid > 0 {
+			owner, ok := names[c.Pid]
+			if !ok {
+				if p, err := process.NewProcess(c.Pid); err == nil {
+					owner[0], _ = p.Name()
+					owner[1], _ = p.Username()
+				}
+				names[c.Pid] = owner
+			}
+			l.Process, l.User = owner[0], owner[1]
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, counts, nil
+}
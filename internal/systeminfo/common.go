@@ -1,8 +1,42 @@
 // internal/systeminfo/common.go
 package systeminfo
 
-import "iDevopzAgent/models"
+import (
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/models"
+)
 
+var log = logging.For("systeminfo")
+
+// Collector reads the static inventory and the volatile counters
+// separately, so the expensive inventory is only collected on its own,
+// slower schedule.
 type Collector interface {
-	GetSystemSummary(userID string, machineID string) (*models.Systeminfo, error)
+	GetInventory(userID string, machineID string) (*models.Inventory, error)
+	GetCounters(userID string, machineID string) (*models.SystemCounters, error)
+}
+
+// Summary combines an inventory and a counters reading into the system
+// summary the backend has always received.
+func Summary(inv *models.Inventory, c *models.SystemCounters) *models.Systeminfo {
+	return &models.Systeminfo{
+		UserID:            c.UserID,
+		MachineID:         c.MachineID,
+		Hostname:          c.Hostname,
+		IPAddress:         inv.IPAddress,
+		OS:                inv.OS,
+		CPUModel:          inv.CPUModel,
+		CPUCores:          inv.CPUCores,
+		RAMMB:             inv.RAMMB,
+		DiskCount:         inv.DiskCount,
+		SysLogsErrorCount: c.SysLogsErrorCount,
+		Uptime:            c.Uptime,
+		BootTime:          c.BootTime,
+		TotalProcesses:    c.TotalProcesses,
+		NICCount:          inv.NICCount,
+		LoginCount:        c.LoginCount,
+		OpenPortCount:     c.OpenPortCount,
+		CurrentUser:       c.CurrentUser,
+		OSDetails:         inv.OSDetails,
+	}
 }
@@ -0,0 +1,182 @@
+package systeminfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// inventoryTracker remembers the last inventory the backend accepted,
+// persisted to inventory.json, so changes made while the agent was stopped
+// or the backend was unreachable are still reported.
+type inventoryTracker struct {
+	mu     sync.Mutex
+	path   string
+	loaded bool
+	acked  *models.Inventory // baseline, advanced by AckInventory
+	last   *models.Inventory // latest collected
+	sent   bool              // acked at least once by this process
+}
+
+var tracker = &inventoryTracker{
+	path: filepath.Join(configs.DataDir(), "inventory.json"),
+}
+
+// CheckInventory collects the inventory and compares it with the last one
+// the backend accepted. send is true until the backend acknowledged an
+// inventory in this process and whenever the hash differs from the
+// accepted one; changes lists the fields that differ from it. The baseline
+// only moves on AckInventory, so a failed upload is retried with the same
+// changes on the next check.
+func CheckInventory(c Collector, userID, machineID string) (inv *models.Inventory, changes []*models.InventoryChange, send bool, err error) {
+	inv, err = c.GetInventory(userID, machineID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	inv.Hash = HashInventory(inv)
+	inv.CollectedAt = time.Now().Unix()
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.load()
+
+	prev := tracker.acked
+	if prev != nil && prev.Hash != inv.Hash {
+		changes = diffInventory(prev, inv)
+	}
+	send = !tracker.sent || prev == nil || prev.Hash != inv.Hash
+
+	tracker.last = inv
+	return inv, changes, send, nil
+}
+
+// AckInventory records inv as accepted by the backend and persists it as
+// the baseline for the next comparison.
+func AckInventory(inv *models.Inventory) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.load()
+
+	tracker.acked = inv
+	tracker.sent = true
+	tracker.save()
+}
+
+// LatestInventory returns the last collected inventory, or nil before the
+// first collection.
+func LatestInventory() *models.Inventory {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return tracker.last
+}
+
+// HashInventory returns the sha256 of the inventory content, ignoring the
+// hash itself and the collection time.
+func HashInventory(inv *models.Inventory) string {
+	c := *inv
+	c.Hash = ""
+	c.CollectedAt = 0
+	data, _ := json.Marshal(&c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *inventoryTracker) load() {
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return
+	}
+	var inv models.Inventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		log.Warn("ignoring corrupt inventory state", "err", err)
+		return
+	}
+	t.acked = &inv
+}
+
+func (t *inventoryTracker) save() {
+	data, err := json.Marshal(t.acked)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(t.path), 0700)
+	if err := os.WriteFile(t.path, data, 0600); err != nil {
+		log.Error("failed to save inventory state", "err", err)
+	}
+}
+
+// diffInventory compares the JSON form of two inventories field by field.
+// Objects are compared per key; lists and scalars as a whole.
+func diffInventory(prev, cur *models.Inventory) []*models.InventoryChange {
+	var a, b map[string]any
+	pa, _ := json.Marshal(prev)
+	pb, _ := json.Marshal(cur)
+	json.Unmarshal(pa, &a)
+	json.Unmarshal(pb, &b)
+	for _, skip := range []string{"hash", "collected_at"} {
+		delete(a, skip)
+		delete(b, skip)
+	}
+
+	var changes []*models.InventoryChange
+	diffValues("", a, b, func(field string, from, to any) {
+		changes = append(changes, &models.InventoryChange{
+			UserID:       cur.UserID,
+			MachineID:    cur.MachineID,
+			Hostname:     cur.Hostname,
+			Field:        field,
+			Old:          from,
+			New:          to,
+			PreviousHash: prev.Hash,
+			Hash:         cur.Hash,
+			Timestamp:    cur.CollectedAt,
+		})
+	})
+	return changes
+}
+
+func diffValues(path string, from, to any, emit func(field string, from, to any)) {
+	om, fromIsMap := from.(map[string]any)
+	tm, toIsMap := to.(map[string]any)
+	if !fromIsMap || !toIsMap {
+		if !reflect.DeepEqual(from, to) {
+			emit(path, from, to)
+		}
+		return
+	}
+
+	keys := make(map[string]bool)
+	for k := range om {
+		keys[k] = true
+	}
+	for k := range tm {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		field := k
+		if path != "" {
+			field = fmt.Sprintf("%s.%s", path, k)
+		}
+		diffValues(field, om[k], tm[k], emit)
+	}
+}
@@ -0,0 +1,308 @@
+//go:build linux
+// +build linux
+
+package systeminfo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hostFS reads the host's /sys and /proc below root, so a containerised
+// agent can read a mounted host tree and tests can use fixture trees.
+type hostFS struct {
+	root string
+}
+
+func (h hostFS) path(p string) string {
+	return filepath.Join(h.root, p)
+}
+
+// read returns the trimmed content of a file, or "" when it is missing or
+// unreadable (DMI serials are root-only).
+func (h hostFS) read(p string) string {
+	data, err := os.ReadFile(h.path(p))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (h hostFS) readInt(p string) (int64, bool) {
+	v, err := strconv.ParseInt(h.read(p), 10, 64)
+	return v, err == nil
+}
+
+// readHardware collects the hardware inventory from sysfs and procfs below
+// root.
+func readHardware(root string) *models.Hardware {
+	h := hostFS{root: root}
+	return &models.Hardware{
+		System:       h.dmi(),
+		CPU:          h.cpu(),
+		Memory:       h.dimms(),
+		BlockDevices: h.blockDevices(),
+		NICs:         h.nics(),
+	}
+}
+
+func (h hostFS) dmi() models.SystemHardware {
+	const dir = "sys/class/dmi/id"
+	return models.SystemHardware{
+		Manufacturer: h.read(dir + "/sys_vendor"),
+		Product:      h.read(dir + "/product_name"),
+		Serial:       h.read(dir + "/product_serial"),
+		UUID:         h.read(dir + "/product_uuid"),
+		BoardVendor:  h.read(dir + "/board_vendor"),
+		BoardName:    h.read(dir + "/board_name"),
+		BIOSVendor:   h.read(dir + "/bios_vendor"),
+		BIOSVersion:  h.read(dir + "/bios_version"),
+		BIOSDate:     h.read(dir + "/bios_date"),
+	}
+}
+
+// cpu takes the topology from sysfs and model, vendor and flags from
+// /proc/cpuinfo. The current clock is left out since it changes constantly.
+func (h hostFS) cpu() models.CPUHardware {
+	var c models.CPUHardware
+
+	cpus, _ := filepath.Glob(h.path("sys/devices/system/cpu/cpu[0-9]*"))
+	sockets := make(map[int64]bool)
+	cores := make(map[[2]int64]bool)
+	for _, dir := range cpus {
+		rel, _ := filepath.Rel(h.root, dir)
+		pkg, ok := h.readInt(rel + "/topology/physical_package_id")
+		if !ok {
+			continue
+		}
+		core, _ := h.readInt(rel + "/topology/core_id")
+		c.Threads++
+		sockets[pkg] = true
+		cores[[2]int64{pkg, core}] = true
+	}
+	c.Sockets = len(sockets)
+	c.PhysicalCores = len(cores)
+
+	if khz, ok := h.readInt("sys/devices/system/cpu/cpu0/cpufreq/cpuinfo_max_freq"); ok {
+		c.MaxMHz = float64(khz) / 1000
+	}
+
+	f, err := os.Open(h.path("proc/cpuinfo"))
+	if err != nil {
+		return c
+	}
+	defer f.Close()
+
+	processors := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "processor":
+			processors++
+		case "model name", "Model":
+			if c.Model == "" {
+				c.Model = value
+			}
+		case "vendor_id", "CPU implementer":
+			if c.Vendor == "" {
+				c.Vendor = value
+			}
+		case "flags", "Features":
+			if c.Flags == nil {
+				c.Flags = strings.Fields(value)
+				sort.Strings(c.Flags)
+			}
+		}
+	}
+
+	// no sysfs topology (some containers): count what cpuinfo lists
+	if c.Threads == 0 {
+		c.Threads = processors
+	}
+	return c
+}
+
+// dimms reads memory devices from the SMBIOS type 17 tables, which need
+// root, and falls back to the EDAC driver's view.
+func (h hostFS) dimms() []models.DIMM {
+	entries, _ := filepath.Glob(h.path("sys/firmware/dmi/entries/17-*/raw"))
+	var dimms []models.DIMM
+	for _, entry := range entries {
+		raw, err := os.ReadFile(entry)
+		if err != nil {
+			continue
+		}
+		if d, ok := parseMemoryDevice(raw); ok {
+			dimms = append(dimms, d)
+		}
+	}
+	if len(dimms) > 0 {
+		return dimms
+	}
+
+	edac, _ := filepath.Glob(h.path("sys/devices/system/edac/mc/mc*/dimm*"))
+	for _, dir := range edac {
+		rel, _ := filepath.Rel(h.root, dir)
+		size, _ := h.readInt(rel + "/size")
+		if size <= 0 {
+			continue
+		}
+		locator := h.read(rel + "/dimm_label")
+		if locator == "" {
+			locator = h.read(rel + "/dimm_location")
+		}
+		dimms = append(dimms, models.DIMM{
+			Locator: locator,
+			SizeMB:  uint64(size),
+			Type:    h.read(rel + "/dimm_mem_type"),
+		})
+	}
+	return dimms
+}
+
+// smbiosMemoryTypes maps the SMBIOS memory type byte to its name.
+var smbiosMemoryTypes = map[byte]string{
+	0x12: "DDR", 0x13: "DDR2", 0x18: "DDR3", 0x1A: "DDR4",
+	0x1B: "LPDDR", 0x1C: "LPDDR2", 0x1D: "LPDDR3", 0x1E: "LPDDR4",
+	0x22: "DDR5", 0x23: "LPDDR5",
+}
+
+// parseMemoryDevice decodes an SMBIOS type 17 structure. Empty slots
+// report size 0 and are skipped.
+func parseMemoryDevice(raw []byte) (models.DIMM, bool) {
+	if len(raw) < 0x1B || raw[0] != 17 {
+		return models.DIMM{}, false
+	}
+	length := int(raw[1])
+	if length > len(raw) {
+		return models.DIMM{}, false
+	}
+	strs := smbiosStrings(raw[length:])
+	str := func(offset int) string {
+		if offset >= length {
+			return ""
+		}
+		i := int(raw[offset])
+		if i == 0 || i > len(strs) {
+			return ""
+		}
+		return strs[i-1]
+	}
+
+	var sizeMB uint64
+	size := binary.LittleEndian.Uint16(raw[0x0C:])
+	switch {
+	case size == 0 || size == 0xFFFF:
+		return models.DIMM{}, false
+	case size == 0x7FFF && length >= 0x20:
+		sizeMB = uint64(binary.LittleEndian.Uint32(raw[0x1C:]) & 0x7FFFFFFF)
+	case size&0x8000 != 0:
+		sizeMB = uint64(size&0x7FFF) / 1024 // KB units
+	default:
+		sizeMB = uint64(size)
+	}
+
+	d := models.DIMM{
+		Locator:      str(0x10),
+		Bank:         str(0x11),
+		SizeMB:       sizeMB,
+		Type:         smbiosMemoryTypes[raw[0x12]],
+		Manufacturer: str(0x17),
+		Serial:       str(0x18),
+		PartNumber:   str(0x1A),
+	}
+	if length >= 0x17 {
+		d.SpeedMTs = uint32(binary.LittleEndian.Uint16(raw[0x15:]))
+	}
+	return d, true
+}
+
+// smbiosStrings splits the string set that follows a structure's formatted
+// area: NUL-terminated strings ending with an extra NUL.
+func smbiosStrings(b []byte) []string {
+	var strs []string
+	for len(b) > 0 && b[0] != 0 {
+		end := 0
+		for end < len(b) && b[end] != 0 {
+			end++
+		}
+		strs = append(strs, strings.TrimSpace(string(b[:end])))
+		if end >= len(b) {
+			break
+		}
+		b = b[end+1:]
+	}
+	return strs
+}
+
+func (h hostFS) blockDevices() []models.BlockDevice {
+	entries, _ := os.ReadDir(h.path("sys/block"))
+	var devices []models.BlockDevice
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "zram") {
+			continue
+		}
+		dir := "sys/block/" + name
+		sectors, _ := h.readInt(dir + "/size")
+
+		serial := h.read(dir + "/device/serial")
+		if serial == "" {
+			serial = h.read(dir + "/serial") // virtio
+		}
+		devices = append(devices, models.BlockDevice{
+			Name:       name,
+			Model:      h.read(dir + "/device/model"),
+			Vendor:     h.read(dir + "/device/vendor"),
+			Serial:     serial,
+			SizeBytes:  uint64(sectors) * 512, // always 512-byte units
+			Rotational: h.read(dir+"/queue/rotational") == "1",
+			Removable:  h.read(dir+"/removable") == "1",
+		})
+	}
+	return devices
+}
+
+// nics lists network interfaces backed by a device; virtual interfaces
+// (bridges, veths, tunnels) have no device link and are skipped.
+func (h hostFS) nics() []models.NIC {
+	entries, _ := os.ReadDir(h.path("sys/class/net"))
+	var nics []models.NIC
+	for _, e := range entries {
+		name := e.Name()
+		dir := "sys/class/net/" + name
+		if _, err := os.Stat(h.path(dir + "/device")); err != nil {
+			continue
+		}
+
+		nic := models.NIC{
+			Name:      name,
+			MAC:       h.read(dir + "/address"),
+			SpeedMbps: -1,
+			Duplex:    h.read(dir + "/duplex"),
+		}
+		if driver, err := os.Readlink(h.path(dir + "/device/driver")); err == nil {
+			nic.Driver = filepath.Base(driver)
+		}
+		if speed, ok := h.readInt(dir + "/speed"); ok && speed > 0 {
+			nic.SpeedMbps = int(speed)
+		}
+		if mtu, ok := h.readInt(dir + "/mtu"); ok {
+			nic.MTU = int(mtu)
+		}
+		nics = append(nics, nic)
+	}
+	return nics
+}
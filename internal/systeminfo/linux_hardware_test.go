@@ -0,0 +1,219 @@
+//go:build linux
+// +build linux
+
+package systeminfo
+
+import (
+	"encoding/binary"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files below a temp root and returns the root; content
+// "->target" makes a symlink instead.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if target, ok := strings.CutPrefix(content, "->"); ok {
+			if err := os.Symlink(target, path); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// memoryDevice builds an SMBIOS type 17 structure with a 0x28 byte
+// formatted area. Locator, bank, manufacturer, serial and part number
+// refer to strings 1-5.
+func memoryDevice(size uint16, extended uint32, memType byte, speed uint16, strs ...string) []byte {
+	raw := make([]byte, 0x28)
+	raw[0] = 17
+	raw[1] = 0x28
+	binary.LittleEndian.PutUint16(raw[0x0C:], size)
+	raw[0x10], raw[0x11] = 1, 2
+	raw[0x12] = memType
+	binary.LittleEndian.PutUint16(raw[0x15:], speed)
+	raw[0x17], raw[0x18], raw[0x1A] = 3, 4, 5
+	binary.LittleEndian.PutUint32(raw[0x1C:], extended)
+	for _, s := range strs {
+		raw = append(raw, s...)
+		raw = append(raw, 0)
+	}
+	return append(raw, 0)
+}
+
+func TestParseMemoryDevice(t *testing.T) {
+	strs := []string{"DIMM_A1", "BANK 0", "Samsung", "0x1234ABCD", "M393A2K43DB3-CWE "}
+
+	dimm, ok := parseMemoryDevice(memoryDevice(16384, 0, 0x1A, 3200, strs...))
+	if !ok {
+		t.Fatal("populated slot rejected")
+	}
+	want := models.DIMM{
+		Locator:      "DIMM_A1",
+		Bank:         "BANK 0",
+		SizeMB:       16384,
+		Type:         "DDR4",
+		SpeedMTs:     3200,
+		Manufacturer: "Samsung",
+		Serial:       "0x1234ABCD",
+		PartNumber:   "M393A2K43DB3-CWE",
+	}
+	if !reflect.DeepEqual(dimm, want) {
+		t.Fatalf("dimm = %+v, want %+v", dimm, want)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		size     uint16
+		extended uint32
+		want     uint64
+	}{
+		{"kilobyte units", 0x8000 | 0x4000, 0, 16},
+		{"extended size", 0x7FFF, 0x80000000 | 65536, 65536},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, ok := parseMemoryDevice(memoryDevice(tc.size, tc.extended, 0x22, 4800, strs...))
+			if !ok || d.SizeMB != tc.want || d.Type != "DDR5" {
+				t.Fatalf("dimm = %+v, %v; want %d MB DDR5", d, ok, tc.want)
+			}
+		})
+	}
+
+	// only a locator: the other string references point past the set
+	d, ok := parseMemoryDevice(memoryDevice(8192, 0, 0x99, 0, "ChannelA-DIMM0"))
+	if !ok || d.Locator != "ChannelA-DIMM0" || d.Bank != "" || d.Manufacturer != "" || d.Type != "" {
+		t.Fatalf("dimm = %+v, %v", d, ok)
+	}
+
+	truncated := memoryDevice(8192, 0, 0x1A, 0)
+	truncated[1] = 0xF0
+	wrongType := memoryDevice(8192, 0, 0x1A, 0)
+	wrongType[0] = 16
+	for name, raw := range map[string][]byte{
+		"empty slot":     memoryDevice(0, 0, 0x02, 0, strs...),
+		"unknown size":   memoryDevice(0xFFFF, 0, 0x02, 0, strs...),
+		"wrong type":     wrongType,
+		"short":          make([]byte, 0x10),
+		"length too big": truncated,
+	} {
+		if d, ok := parseMemoryDevice(raw); ok {
+			t.Errorf("%s: parsed as %+v", name, d)
+		}
+	}
+}
+
+func TestSMBIOSStrings(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"two strings", "first\x00 second \x00\x00", []string{"first", "second"}},
+		{"empty set", "\x00\x00", nil},
+		{"unterminated", "only", []string{"only"}},
+		{"trailing data ignored", "a\x00\x00b\x00\x00", []string{"a"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := smbiosStrings([]byte(tc.in)); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("smbiosStrings(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCPUTopology(t *testing.T) {
+	topology := func(cpu, pkg, core string) map[string]string {
+		dir := "sys/devices/system/cpu/" + cpu + "/topology/"
+		return map[string]string{dir + "physical_package_id": pkg + "\n", dir + "core_id": core + "\n"}
+	}
+	files := map[string]string{
+		"sys/devices/system/cpu/cpu0/cpufreq/cpuinfo_max_freq": "3600000\n",
+		"sys/devices/system/cpu/cpu4/online":                   "0\n", // offline, no topology
+		"sys/devices/system/cpu/cpuidle/current_driver":        "intel_idle\n",
+		"proc/cpuinfo": "processor\t: 0\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Xeon(R) Gold 6230\nflags\t\t: sse2 fpu avx\n\n" +
+			"processor\t: 1\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Xeon(R) Gold 6230\nflags\t\t: sse2 fpu avx\n\n",
+	}
+	// cpu0 and cpu1 are hyperthreads of one core
+	for _, m := range []map[string]string{
+		topology("cpu0", "0", "0"),
+		topology("cpu1", "0", "0"),
+		topology("cpu2", "1", "0"),
+		topology("cpu3", "1", "1"),
+	} {
+		for k, v := range m {
+			files[k] = v
+		}
+	}
+
+	c := hostFS{root: writeTree(t, files)}.cpu()
+	if c.Threads != 4 || c.Sockets != 2 || c.PhysicalCores != 3 {
+		t.Fatalf("topology = %d threads, %d sockets, %d cores", c.Threads, c.Sockets, c.PhysicalCores)
+	}
+	if c.MaxMHz != 3600 || c.Vendor != "GenuineIntel" || c.Model != "Intel(R) Xeon(R) Gold 6230" {
+		t.Fatalf("cpu = %+v", c)
+	}
+	if want := []string{"avx", "fpu", "sse2"}; !reflect.DeepEqual(c.Flags, want) {
+		t.Fatalf("flags = %q, want %q", c.Flags, want)
+	}
+}
+
+func TestCPUWithoutTopology(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"proc/cpuinfo": "processor\t: 0\nCPU implementer\t: 0x41\nFeatures\t: fp asimd\n\n" +
+			"processor\t: 1\nCPU implementer\t: 0x41\nFeatures\t: fp asimd\n\n" +
+			"processor\t: 2\n\nModel\t\t: Raspberry Pi 4 Model B\n",
+	})
+
+	c := hostFS{root: root}.cpu()
+	if c.Threads != 3 || c.Sockets != 0 || c.PhysicalCores != 0 {
+		t.Fatalf("topology = %d threads, %d sockets, %d cores", c.Threads, c.Sockets, c.PhysicalCores)
+	}
+	if c.Vendor != "0x41" || c.Model != "Raspberry Pi 4 Model B" || !reflect.DeepEqual(c.Flags, []string{"asimd", "fp"}) {
+		t.Fatalf("cpu = %+v", c)
+	}
+}
+
+func TestNICs(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"sys/class/net/eth0/device/vendor": "0x8086\n",
+		"sys/class/net/eth0/device/driver": "->../../../bus/pci/drivers/e1000e",
+		"sys/class/net/eth0/address":       "52:54:00:12:34:56\n",
+		"sys/class/net/eth0/speed":         "1000\n",
+		"sys/class/net/eth0/duplex":        "full\n",
+		"sys/class/net/eth0/mtu":           "1500\n",
+
+		// link down: speed reads -1
+		"sys/class/net/wlan0/device/vendor": "0x14e4\n",
+		"sys/class/net/wlan0/address":       "dc:a6:32:00:00:01\n",
+		"sys/class/net/wlan0/speed":         "-1\n",
+		"sys/class/net/wlan0/mtu":           "1500\n",
+
+		// virtual interfaces have no device
+		"sys/class/net/lo/address":      "00:00:00:00:00:00\n",
+		"sys/class/net/docker0/address": "02:42:ac:11:00:01\n",
+	})
+
+	got := hostFS{root: root}.nics()
+	want := []models.NIC{
+		{Name: "eth0", MAC: "52:54:00:12:34:56", Driver: "e1000e", SpeedMbps: 1000, Duplex: "full", MTU: 1500},
+		{Name: "wlan0", MAC: "dc:a6:32:00:00:01", SpeedMbps: -1, MTU: 1500},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("nics = %+v\nwant %+v", got, want)
+	}
+}
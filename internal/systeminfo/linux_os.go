@@ -0,0 +1,268 @@
+//go:build linux
+// +build linux
+
+package systeminfo
+
+import (
+	"bufio"
+	"bytes"
+	"iDevopzAgent/internal/hostfs"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// readOSDetails collects release, kernel, reboot and virtualization details
+// below root. hw supplies the DMI identity and CPU flags already read for
+// the hardware inventory.
+func readOSDetails(root string, hw *models.Hardware) *models.OSDetails {
+	h := hostFS{root: root}
+	d := &models.OSDetails{}
+
+	rel := h.osRelease()
+	d.Name = rel["NAME"]
+	d.ID = rel["ID"]
+	d.IDLike = rel["ID_LIKE"]
+	d.Version = rel["VERSION"]
+	d.VersionID = rel["VERSION_ID"]
+	d.VersionCodename = rel["VERSION_CODENAME"]
+	d.PrettyName = rel["PRETTY_NAME"]
+
+	d.KernelRelease = h.read("proc/sys/kernel/osrelease")
+	d.KernelCmdline = h.read("proc/cmdline")
+	d.InstalledKernel = h.newestKernel()
+	d.KernelMismatch = d.InstalledKernel != "" && d.KernelRelease != "" &&
+		compareKernelVersions(d.InstalledKernel, d.KernelRelease) > 0
+
+	for _, p := range []string{"run/reboot-required", "var/run/reboot-required"} {
+		if _, err := os.Stat(h.path(p)); err == nil {
+			d.RebootRequired = true
+			d.RebootReasons = h.rebootPackages(p + ".pkgs")
+			break
+		}
+	}
+	if d.KernelMismatch {
+		d.RebootRequired = true
+		d.RebootReasons = appendUnique(d.RebootReasons, "kernel "+d.InstalledKernel)
+	}
+
+	d.LastBootReason = h.lastBootReason()
+
+	id := machineIdentity{AssetTag: h.read("sys/class/dmi/id/chassis_asset_tag")}
+	if hw != nil {
+		id.Vendor, id.Product, id.BIOSVendor = hw.System.Manufacturer, hw.System.Product, hw.System.BIOSVendor
+	}
+	d.Virtualization, d.Cloud = classifyMachine(id)
+	if d.Virtualization == "" {
+		d.Virtualization = "none"
+		if h.read("sys/hypervisor/type") == "xen" {
+			d.Virtualization = "xen"
+		} else if hw != nil && containsString(hw.CPU.Flags, "hypervisor") {
+			d.Virtualization = "unknown"
+		}
+	}
+	d.Container = h.container()
+
+	return d
+}
+
+// osRelease parses /etc/os-release, falling back to /usr/lib/os-release.
+func (h hostFS) osRelease() map[string]string {
+	fields := make(map[string]string)
+	data, err := os.ReadFile(h.path("etc/os-release"))
+	if err != nil {
+		data, err = os.ReadFile(h.path("usr/lib/os-release"))
+		if err != nil {
+			return fields
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// newestKernel returns the highest kernel version installed in /boot or
+// /usr/lib/modules, or "" when none is recognisable.
+func (h hostFS) newestKernel() string {
+	var versions []string
+	images, _ := filepath.Glob(h.path("boot/vmlinuz-*"))
+	for _, img := range images {
+		v := strings.TrimPrefix(filepath.Base(img), "vmlinuz-")
+		if strings.Contains(v, "rescue") || !startsWithDigit(v) {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	modules, _ := filepath.Glob(h.path("usr/lib/modules/*/vmlinuz"))
+	for _, img := range modules {
+		versions = append(versions, filepath.Base(filepath.Dir(img)))
+	}
+
+	newest := ""
+	for _, v := range versions {
+		if newest == "" || compareKernelVersions(v, newest) > 0 {
+			newest = v
+		}
+	}
+	return newest
+}
+
+func (h hostFS) rebootPackages(p string) []string {
+	var pkgs []string
+	for _, line := range strings.Split(h.read(p), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			pkgs = appendUnique(pkgs, line)
+		}
+	}
+	return pkgs
+}
+
+// lastBootReason explains the last boot from what the previous one left
+// behind: crash records in pstore, the watchdog's boot status, or whether
+// wtmp recorded a shutdown before the boot.
+func (h hostFS) lastBootReason() string {
+	if entries, _ := os.ReadDir(h.path("sys/fs/pstore")); len(entries) > 0 {
+		return "kernel crash (pstore)"
+	}
+	if status, ok := h.readInt("sys/class/watchdog/watchdog0/bootstatus"); ok && status != 0 {
+		return "watchdog reset"
+	}
+
+	data, err := os.ReadFile(h.path("var/log/wtmp"))
+	if err != nil {
+		return ""
+	}
+	return bootReasonFromWtmp(data)
+}
+
+// bootReasonFromWtmp looks at the records between the last two boots: a
+// "shutdown" run level record means the previous boot ended cleanly.
+func bootReasonFromWtmp(data []byte) string {
+	boots := 0
+	for off := len(data) - hostfs.UtmpSize; off >= 0; off -= hostfs.UtmpSize {
+		rec := hostfs.ParseUtmp(data[off : off+hostfs.UtmpSize])
+		switch {
+		case rec.Type == hostfs.UtmpBootTime:
+			boots++
+			if boots == 2 {
+				return "unclean shutdown (crash or power loss)"
+			}
+		case rec.Type == hostfs.UtmpRunLevel && rec.User == "shutdown" && boots == 1:
+			return "clean shutdown"
+		}
+	}
+	return ""
+}
+
+// container names the container runtime the host tree belongs to, or ""
+// outside a container.
+func (h hostFS) container() string {
+	if env, err := os.ReadFile(h.path("proc/1/environ")); err == nil {
+		for _, kv := range bytes.Split(env, []byte{0}) {
+			if v, ok := bytes.CutPrefix(kv, []byte("container=")); ok && len(v) > 0 {
+				return string(v)
+			}
+		}
+	}
+	if _, err := os.Stat(h.path(".dockerenv")); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat(h.path("run/.containerenv")); err == nil {
+		return "podman"
+	}
+
+	cgroup := h.read("proc/1/cgroup")
+	switch {
+	case strings.Contains(cgroup, "kubepods"):
+		return "kubernetes"
+	case strings.Contains(cgroup, "docker"):
+		return "docker"
+	case strings.Contains(cgroup, "lxc"):
+		return "lxc"
+	case strings.Contains(cgroup, "containerd"):
+		return "containerd"
+	}
+	return ""
+}
+
+// compareKernelVersions compares numeric runs as numbers and everything
+// else as text, so 5.15.0-101 sorts after 5.15.0-91.
+func compareKernelVersions(a, b string) int {
+	for a != "" && b != "" {
+		ra, restA := versionRun(a)
+		rb, restB := versionRun(b)
+		if c := compareRun(ra, rb); c != 0 {
+			return c
+		}
+		a, b = restA, restB
+	}
+	switch {
+	case a != "":
+		return 1
+	case b != "":
+		return -1
+	}
+	return 0
+}
+
+func versionRun(s string) (run, rest string) {
+	digit := unicode.IsDigit(rune(s[0]))
+	i := 1
+	for i < len(s) && unicode.IsDigit(rune(s[i])) == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func compareRun(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case na > nb:
+			return 1
+		case na < nb:
+			return -1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && unicode.IsDigit(rune(s[0]))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	if containsString(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -0,0 +1,186 @@
+//go:build linux
+// +build linux
+
+package systeminfo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ListPackages returns the packages installed through dpkg, rpm and apk.
+// Managers that are not present are skipped; a host may have more than one
+// (e.g. rpm installed on a Debian box).
+func ListPackages() ([]*models.Package, error) {
+	root := configs.LoadSettings().HostRoot
+
+	var pkgs []*models.Package
+	var errs []string
+	for _, list := range []func(string) ([]*models.Package, error){listDpkg, listRpm, listApk} {
+		found, err := list(root)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		pkgs = append(pkgs, found...)
+	}
+	if len(pkgs) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("list packages: %s", strings.Join(errs, "; "))
+	}
+	for _, e := range errs {
+		log.Warn("package manager skipped", "err", e)
+	}
+	sortPackages(pkgs)
+	return pkgs, nil
+}
+
+// listDpkg parses the dpkg status file. The install time is the mtime of
+// the package's file list.
+func listDpkg(root string) ([]*models.Package, error) {
+	data, err := os.ReadFile(filepath.Join(root, "var/lib/dpkg/status"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dpkg: %w", err)
+	}
+
+	infoDir := filepath.Join(root, "var/lib/dpkg/info")
+	var pkgs []*models.Package
+	for _, stanza := range bytes.Split(data, []byte("\n\n")) {
+		fields := parseStanza(stanza)
+		if !strings.HasSuffix(fields["Status"], " installed") {
+			continue
+		}
+		p := &models.Package{
+			Name:    fields["Package"],
+			Version: fields["Version"],
+			Arch:    fields["Architecture"],
+			Manager: "dpkg",
+		}
+		if p.Name == "" {
+			continue
+		}
+		for _, list := range []string{p.Name + ":" + p.Arch + ".list", p.Name + ".list"} {
+			if info, err := os.Stat(filepath.Join(infoDir, list)); err == nil {
+				p.InstalledAt = info.ModTime().Unix()
+				break
+			}
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+// parseStanza reads the "Key: value" lines of a dpkg control stanza,
+// skipping continuation lines.
+func parseStanza(stanza []byte) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(stanza))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// rpmQueryFormat prints one tab-separated line per package. The epoch is
+// "(none)" for most packages.
+const rpmQueryFormat = `%{NAME}\t%{EPOCH}\t%{VERSION}-%{RELEASE}\t%{ARCH}\t%{INSTALLTIME}\n`
+
+// listRpm queries the rpm database through the rpm binary, which is the
+// only stable interface to its storage format.
+func listRpm(root string) ([]*models.Package, error) {
+	found := false
+	for _, db := range []string{"var/lib/rpm", "usr/lib/sysimage/rpm"} {
+		if _, err := os.Stat(filepath.Join(root, db)); err == nil {
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	if _, err := exec.LookPath("rpm"); err != nil {
+		return nil, nil
+	}
+
+	args := []string{"-qa", "--queryformat", rpmQueryFormat}
+	if root != "" && root != "/" {
+		args = append([]string{"--root", root}, args...)
+	}
+	out, err := exec.Command("rpm", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("rpm: %w", err)
+	}
+	return parseRpmOutput(out), nil
+}
+
+func parseRpmOutput(out []byte) []*models.Package {
+	var pkgs []*models.Package
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Split(line, "\t")
+		if len(f) != 5 || f[0] == "gpg-pubkey" {
+			continue // gpg-pubkey entries are imported signing keys
+		}
+		version := f[2]
+		if f[1] != "(none)" && f[1] != "" {
+			version = f[1] + ":" + version
+		}
+		installed, _ := strconv.ParseInt(f[4], 10, 64)
+		pkgs = append(pkgs, &models.Package{
+			Name:        f[0],
+			Version:     version,
+			Arch:        f[3],
+			Manager:     "rpm",
+			InstalledAt: installed,
+		})
+	}
+	return pkgs
+}
+
+// listApk parses the apk installed database. It records no install time.
+func listApk(root string) ([]*models.Package, error) {
+	data, err := os.ReadFile(filepath.Join(root, "lib/apk/db/installed"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("apk: %w", err)
+	}
+
+	var pkgs []*models.Package
+	for _, record := range bytes.Split(data, []byte("\n\n")) {
+		p := &models.Package{Manager: "apk"}
+		for _, line := range strings.Split(string(record), "\n") {
+			if len(line) < 2 || line[1] != ':' {
+				continue
+			}
+			switch line[0] {
+			case 'P':
+				p.Name = line[2:]
+			case 'V':
+				p.Version = line[2:]
+			case 'A':
+				p.Arch = line[2:]
+			}
+		}
+		if p.Name != "" {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs, nil
+}
@@ -5,21 +5,20 @@ package systeminfo
 
 import (
 	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/logtail"
+	"iDevopzAgent/internal/sockets"
 	"iDevopzAgent/internal/utils"
 	"iDevopzAgent/models"
 	"net"
-	"os"
 	"os/user"
 	"runtime"
-	"strings"
 	"time"
-
-	psnet "github.com/shirou/gopsutil/v3/net" // alias to avoid std lib conflict
 )
 
 type LinuxCollector struct{}
 
-func (l LinuxCollector) GetSystemSummary(userID string, machineId string) (*models.Systeminfo, error) {
+func (l LinuxCollector) GetInventory(userID string, machineId string) (*models.Inventory, error) {
 	hostInfo, err := utils.HostInfo()
 	if err != nil {
 		return nil, err
@@ -33,41 +32,64 @@ func (l LinuxCollector) GetSystemSummary(userID string, machineId string) (*mode
 	_, memTotal, _, _ := utils.GetMemoryUsage()
 	diskInfo, _ := utils.GetDiskPartitions(false)
 	netInterfaces, _ := utils.GetNetworkInterfaces()
-	procsCount, _ := utils.GetProcessCount()
-	currentUser, _ := user.Current()
 
-	errorLogCount := countSyslogErrors()
-	loginCount := getLoggedInUserCount()
-	openPortcount, err := GetOpenPortCount()
-	if err != nil {
-		fmt.Println("Error:", err)
+	root := configs.LoadSettings().HostRoot
+	hardware := readHardware(root)
+
+	inventory := &models.Inventory{
+		UserID:          userID,
+		MachineID:       machineId,
+		Hostname:        hostInfo.Hostname,
+		IPAddress:       getIP(),
+		OS:              fmt.Sprintf("%s %s (%s)", hostInfo.Platform, hostInfo.PlatformVersion, runtime.GOARCH),
+		Platform:        hostInfo.Platform,
+		PlatformVersion: hostInfo.PlatformVersion,
+		KernelVersion:   hostInfo.KernelVersion,
+		Arch:            runtime.GOARCH,
+		CPUModel:        cpuInfo[0].ModelName,
+		CPUCores:        runtime.NumCPU(),
+		RAMMB:           float64(memTotal) / (1024 * 1024),
+		DiskCount:       len(diskInfo),
+		NICCount:        len(netInterfaces),
+		Hardware:        hardware,
+		OSDetails:       readOSDetails(root, hardware),
+	}
+
+	return inventory, nil
+}
 
+func (l LinuxCollector) GetCounters(userID string, machineId string) (*models.SystemCounters, error) {
+	hostInfo, err := utils.HostInfo()
+	if err != nil {
+		return nil, err
 	}
 
-	ip := getIP()
+	procsCount, _ := utils.GetProcessCount()
+	currentUser, _ := user.Current()
+
+	errorLogCount := logtail.GetTailer().ErrorCount()
+
+	loginCount := getLoggedInUserCount()
 
-	summary := &models.Systeminfo{
+	counters := &models.SystemCounters{
 		UserID:            userID,
 		MachineID:         machineId,
 		Hostname:          hostInfo.Hostname,
-		IPAddress:         ip,
-		OS:                fmt.Sprintf("%s %s (%s)", hostInfo.Platform, hostInfo.PlatformVersion, runtime.GOARCH),
-		CPUModel:          cpuInfo[0].ModelName,
-		CPUCores:          runtime.NumCPU(),
-		RAMMB:             float64(memTotal) / (1024 * 1024),
-		DiskCount:         len(diskInfo),
 		SysLogsErrorCount: errorLogCount,
 		LoginCount:        loginCount,
-		OpenPortCount:     openPortcount,
+		OpenPortCount:     sockets.ListenCount(),
 		Uptime:            formatDuration(hostInfo.Uptime),
 		BootTime:          hostInfo.BootTime,
 		TotalProcesses:    procsCount,
-		NICCount:          len(netInterfaces),
-		CurrentUser:       currentUser.Username,
+		Timestamp:         time.Now().Unix(),
+	}
+	if currentUser != nil {
+		counters.CurrentUser = currentUser.Username
 	}
 
-	return summary, nil
+	return counters, nil
 }
+
 func formatDuration(seconds uint64) string {
 	d := time.Duration(seconds) * time.Second
 	days := d / (24 * time.Hour)
@@ -79,19 +101,6 @@ func formatDuration(seconds uint64) string {
 	secs := d / time.Second
 	return fmt.Sprintf("%d day(s) %d hr(s) %d min(s) %d sec(s)", days, hours, mins, secs)
 }
-func countSyslogErrors() int {
-	content, err := os.ReadFile("/var/log/syslog")
-	if err != nil {
-		return 0
-	}
-	count := 0
-	for _, line := range strings.Split(string(content), "\n") {
-		if strings.Contains(line, "error") || strings.Contains(line, "ERROR") {
-			count++
-		}
-	}
-	return count
-}
 
 func getIP() string {
 	ifaces, _ := net.Interfaces()
@@ -128,21 +137,6 @@ func getLoggedInUserCount() int {
 	return len(users)
 }
 
-func GetOpenPortCount() (int, error) {
-	conns, err := psnet.Connections("all") // "tcp", "udp", or "all"
-	if err != nil {
-		return 0, err
-	}
-
-	count := 0
-	for _, conn := range conns {
-		if conn.Status == "LISTEN" || conn.Status == "ESTABLISHED" {
-			count++
-		}
-	}
-	return count, nil
-}
-
 func GetSystemInfoCollector() Collector {
 	return LinuxCollector{}
 }
@@ -0,0 +1,17 @@
+package systeminfo
+
+import (
+	"iDevopzAgent/models"
+	"sort"
+)
+
+// sortPackages orders packages by manager, name, arch and version so the
+// list hashes the same on every run.
+func sortPackages(pkgs []*models.Package) {
+	key := func(p *models.Package) string {
+		return p.Manager + "\x00" + p.Name + "\x00" + p.Arch + "\x00" + p.Version
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return key(pkgs[i]) < key(pkgs[j])
+	})
+}
@@ -0,0 +1,64 @@
+package systeminfo
+
+import "strings"
+
+// azureAssetTag is the chassis asset tag every Azure VM carries.
+const azureAssetTag = "7783-7084-3265-9085-8269-3286-77"
+
+// classifyMachine derives the hypervisor and cloud provider from the DMI
+// identity. hypervisor is "" when the identity does not name one.
+func classifyMachine(hw machineIdentity) (hypervisor, cloud string) {
+	vendor := strings.ToLower(hw.Vendor)
+	product := strings.ToLower(hw.Product)
+	bios := strings.ToLower(hw.BIOSVendor)
+
+	switch {
+	case strings.Contains(vendor, "amazon") || strings.Contains(bios, "amazon"):
+		hypervisor, cloud = "kvm", "aws"
+		if strings.Contains(bios, "xen") || strings.Contains(product, "hvm domu") {
+			hypervisor = "xen"
+		}
+		return
+	case strings.Contains(product, "google compute engine"):
+		return "kvm", "gcp"
+	case hw.AssetTag == azureAssetTag:
+		return "hyperv", "azure"
+	case strings.Contains(hw.AssetTag, "OracleCloud"):
+		return "kvm", "oci"
+	case strings.Contains(vendor, "digitalocean"):
+		return "kvm", "digitalocean"
+	case strings.Contains(vendor, "hetzner"):
+		return "kvm", "hetzner"
+	case strings.Contains(vendor, "alibaba"):
+		return "kvm", "alibaba"
+	case strings.Contains(product, "openstack"):
+		return "kvm", "openstack"
+	}
+
+	switch {
+	case strings.Contains(vendor, "qemu") || strings.Contains(product, "kvm") || strings.Contains(product, "qemu"):
+		return "kvm", ""
+	case strings.Contains(vendor, "vmware") || strings.Contains(product, "vmware"):
+		return "vmware", ""
+	case strings.Contains(vendor, "microsoft") && strings.Contains(product, "virtual machine"):
+		return "hyperv", ""
+	case strings.Contains(vendor, "xen") || strings.Contains(product, "hvm domu"):
+		return "xen", ""
+	case strings.Contains(vendor, "innotek") || strings.Contains(product, "virtualbox"):
+		return "virtualbox", ""
+	case strings.Contains(vendor, "parallels"):
+		return "parallels", ""
+	case strings.Contains(vendor, "bochs"):
+		return "bochs", ""
+	}
+	return "", ""
+}
+
+// machineIdentity is the part of the DMI data used to recognise virtual
+// machines and clouds.
+type machineIdentity struct {
+	Vendor     string
+	Product    string
+	BIOSVendor string
+	AssetTag   string
+}
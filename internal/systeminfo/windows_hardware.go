@@ -0,0 +1,175 @@
+//go:build windows
+// +build windows
+
+package systeminfo
+
+import (
+	"iDevopzAgent/models"
+	"sort"
+	"strings"
+
+	"github.com/yusufpapurcu/wmi"
+)
+
+type Win32_ComputerSystemProduct struct {
+	Vendor            string
+	Name              string
+	IdentifyingNumber string
+	UUID              string
+}
+
+type Win32_BaseBoard struct {
+	Manufacturer string
+	Product      string
+}
+
+type Win32_BIOS struct {
+	Manufacturer      string
+	SMBIOSBIOSVersion string
+	ReleaseDate       string
+}
+
+type Win32_Processor struct {
+	Name                      string
+	Manufacturer              string
+	NumberOfCores             uint32
+	NumberOfLogicalProcessors uint32
+	MaxClockSpeed             uint32
+}
+
+type Win32_PhysicalMemory struct {
+	DeviceLocator    string
+	BankLabel        string
+	Capacity         uint64
+	Speed            uint32
+	Manufacturer     string
+	SerialNumber     string
+	PartNumber       string
+	SMBIOSMemoryType uint32
+}
+
+type Win32_DiskDrive struct {
+	DeviceID     string
+	Model        string
+	SerialNumber string
+	Size         uint64
+	MediaType    string
+}
+
+type MSFT_PhysicalDisk struct {
+	DeviceId  string
+	MediaType uint16 // 3 HDD, 4 SSD
+}
+
+type Win32_NetworkAdapter struct {
+	NetConnectionID string
+	MACAddress      string
+	ServiceName     string
+	Speed           uint64
+}
+
+// smbiosMemoryTypes maps the SMBIOS memory type to its name.
+var smbiosMemoryTypes = map[uint32]string{
+	0x12: "DDR", 0x13: "DDR2", 0x18: "DDR3", 0x1A: "DDR4",
+	0x1B: "LPDDR", 0x1C: "LPDDR2", 0x1D: "LPDDR3", 0x1E: "LPDDR4",
+	0x22: "DDR5", 0x23: "LPDDR5",
+}
+
+// readHardware collects the hardware inventory from WMI. root only applies
+// to Linux.
+func readHardware(root string) *models.Hardware {
+	hw := &models.Hardware{}
+
+	var products []Win32_ComputerSystemProduct
+	if err := wmi.Query("SELECT Vendor, Name, IdentifyingNumber, UUID FROM Win32_ComputerSystemProduct", &products); err == nil && len(products) > 0 {
+		hw.System.Manufacturer = products[0].Vendor
+		hw.System.Product = products[0].Name
+		hw.System.Serial = products[0].IdentifyingNumber
+		hw.System.UUID = products[0].UUID
+	}
+	var boards []Win32_BaseBoard
+	if err := wmi.Query("SELECT Manufacturer, Product FROM Win32_BaseBoard", &boards); err == nil && len(boards) > 0 {
+		hw.System.BoardVendor = boards[0].Manufacturer
+		hw.System.BoardName = boards[0].Product
+	}
+	var bios []Win32_BIOS
+	if err := wmi.Query("SELECT Manufacturer, SMBIOSBIOSVersion, ReleaseDate FROM Win32_BIOS", &bios); err == nil && len(bios) > 0 {
+		hw.System.BIOSVendor = bios[0].Manufacturer
+		hw.System.BIOSVersion = bios[0].SMBIOSBIOSVersion
+		hw.System.BIOSDate = bios[0].ReleaseDate
+	}
+
+	var procs []Win32_Processor
+	if err := wmi.Query("SELECT Name, Manufacturer, NumberOfCores, NumberOfLogicalProcessors, MaxClockSpeed FROM Win32_Processor", &procs); err == nil {
+		hw.CPU.Sockets = len(procs)
+		for _, p := range procs {
+			hw.CPU.PhysicalCores += int(p.NumberOfCores)
+			hw.CPU.Threads += int(p.NumberOfLogicalProcessors)
+			if hw.CPU.Model == "" {
+				hw.CPU.Model = strings.TrimSpace(p.Name)
+				hw.CPU.Vendor = p.Manufacturer
+				hw.CPU.MaxMHz = float64(p.MaxClockSpeed)
+			}
+		}
+	}
+
+	var dimms []Win32_PhysicalMemory
+	if err := wmi.Query("SELECT DeviceLocator, BankLabel, Capacity, Speed, Manufacturer, SerialNumber, PartNumber, SMBIOSMemoryType FROM Win32_PhysicalMemory", &dimms); err == nil {
+		for _, d := range dimms {
+			hw.Memory = append(hw.Memory, models.DIMM{
+				Locator:      d.DeviceLocator,
+				Bank:         d.BankLabel,
+				SizeMB:       d.Capacity / (1024 * 1024),
+				Type:         smbiosMemoryTypes[d.SMBIOSMemoryType],
+				SpeedMTs:     d.Speed,
+				Manufacturer: strings.TrimSpace(d.Manufacturer),
+				Serial:       strings.TrimSpace(d.SerialNumber),
+				PartNumber:   strings.TrimSpace(d.PartNumber),
+			})
+		}
+	}
+
+	// rotational is only known to the storage management provider
+	rotational := map[string]bool{}
+	var physical []MSFT_PhysicalDisk
+	if err := wmi.QueryNamespace("SELECT DeviceId, MediaType FROM MSFT_PhysicalDisk", &physical, `root\Microsoft\Windows\Storage`); err == nil {
+		for _, p := range physical {
+			rotational[p.DeviceId] = p.MediaType == 3
+		}
+	}
+	var disks []Win32_DiskDrive
+	if err := wmi.Query("SELECT DeviceID, Model, SerialNumber, Size, MediaType FROM Win32_DiskDrive", &disks); err == nil {
+		for _, d := range disks {
+			index := strings.TrimPrefix(strings.ToUpper(d.DeviceID), `\\.\PHYSICALDRIVE`)
+			hw.BlockDevices = append(hw.BlockDevices, models.BlockDevice{
+				Name:       d.DeviceID,
+				Model:      d.Model,
+				Serial:     strings.TrimSpace(d.SerialNumber),
+				SizeBytes:  d.Size,
+				Rotational: rotational[index],
+				Removable:  strings.Contains(d.MediaType, "Removable"),
+			})
+		}
+		sort.Slice(hw.BlockDevices, func(i, j int) bool { return hw.BlockDevices[i].Name < hw.BlockDevices[j].Name })
+	}
+
+	var adapters []Win32_NetworkAdapter
+	if err := wmi.Query("SELECT NetConnectionID, MACAddress, ServiceName, Speed FROM Win32_NetworkAdapter WHERE PhysicalAdapter = TRUE", &adapters); err == nil {
+		for _, a := range adapters {
+			nic := models.NIC{
+				Name:      a.NetConnectionID,
+				MAC:       strings.ToLower(a.MACAddress),
+				Driver:    a.ServiceName,
+				SpeedMbps: -1,
+			}
+			// Speed is bits per second; disconnected adapters report a
+			// placeholder maximum
+			if a.Speed > 0 && a.Speed < 1<<62 {
+				nic.SpeedMbps = int(a.Speed / 1000000)
+			}
+			hw.NICs = append(hw.NICs, nic)
+		}
+	}
+
+	return hw
+}
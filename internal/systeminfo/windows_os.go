@@ -0,0 +1,106 @@
+//go:build windows
+// +build windows
+
+package systeminfo
+
+import (
+	"bytes"
+	"iDevopzAgent/internal/utils"
+	"iDevopzAgent/models"
+	"os/exec"
+	"strings"
+
+	"github.com/yusufpapurcu/wmi"
+	"golang.org/x/sys/windows/registry"
+)
+
+type Win32_SystemEnclosure struct {
+	SMBIOSAssetTag string
+}
+
+// rebootPendingKeys are the registry keys whose presence means Windows
+// waits for a reboot, with the reason reported for each.
+var rebootPendingKeys = []struct {
+	path   string
+	reason string
+}{
+	{`SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending`, "component servicing"},
+	{`SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired`, "windows update"},
+}
+
+// readOSDetails collects release, kernel, reboot and virtualization details.
+// root only applies to Linux.
+func readOSDetails(root string, hw *models.Hardware) *models.OSDetails {
+	d := &models.OSDetails{}
+
+	if info, err := utils.HostInfo(); err == nil {
+		d.Name = info.Platform
+		d.ID = "windows"
+		d.Version = info.PlatformVersion
+		d.VersionID = info.PlatformVersion
+		d.PrettyName = strings.TrimSpace(info.Platform + " " + info.PlatformVersion)
+		d.KernelRelease = info.KernelVersion
+	}
+
+	for _, k := range rebootPendingKeys {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, k.path, registry.QUERY_VALUE)
+		if err == nil {
+			key.Close()
+			d.RebootRequired = true
+			d.RebootReasons = append(d.RebootReasons, k.reason)
+		}
+	}
+	if key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control\Session Manager`, registry.QUERY_VALUE); err == nil {
+		if renames, _, err := key.GetStringsValue("PendingFileRenameOperations"); err == nil && len(renames) > 0 {
+			d.RebootRequired = true
+			d.RebootReasons = append(d.RebootReasons, "pending file renames")
+		}
+		key.Close()
+	}
+
+	d.LastBootReason = lastBootReason()
+
+	id := machineIdentity{}
+	var enclosures []Win32_SystemEnclosure
+	if err := wmi.Query("SELECT SMBIOSAssetTag FROM Win32_SystemEnclosure", &enclosures); err == nil && len(enclosures) > 0 {
+		id.AssetTag = strings.TrimSpace(enclosures[0].SMBIOSAssetTag)
+	}
+	if hw != nil {
+		id.Vendor, id.Product, id.BIOSVendor = hw.System.Manufacturer, hw.System.Product, hw.System.BIOSVendor
+	}
+	d.Virtualization, d.Cloud = classifyMachine(id)
+	if d.Virtualization == "" {
+		d.Virtualization = "none"
+	}
+
+	return d
+}
+
+// lastBootReason reads the most recent shutdown event from the System log:
+// 1074 is a requested shutdown or restart (its message carries the reason),
+// 6008 an unexpected shutdown and 41 a reboot without a clean shutdown.
+func lastBootReason() string {
+	cmd := exec.Command("powershell", "-NoProfile", "-Command", `
+		$e = Get-WinEvent -FilterHashtable @{LogName='System'; Id=1074,6008,41} -MaxEvents 1 -ErrorAction SilentlyContinue
+		if ($e) { "$($e.Id)|$($e.Properties[2].Value)" }
+	`)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+
+	id, reason, _ := strings.Cut(strings.TrimSpace(out.String()), "|")
+	switch id {
+	case "1074":
+		if reason = strings.TrimSpace(reason); reason != "" {
+			return "requested: " + reason
+		}
+		return "requested shutdown"
+	case "6008":
+		return "unexpected shutdown"
+	case "41":
+		return "unclean shutdown (crash or power loss)"
+	}
+	return ""
+}
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package systeminfo
+
+import "iDevopzAgent/models"
+
+// ListPackages returns nothing on Windows: dpkg, rpm and apk are the only
+// package databases the agent reads.
+func ListPackages() ([]*models.Package, error) {
+	return nil, nil
+}
@@ -6,6 +6,8 @@ package systeminfo
 import (
 	"bytes"
 	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/internal/sockets"
 	"iDevopzAgent/internal/utils"
 	"iDevopzAgent/models"
 	"net"
@@ -15,8 +17,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	psnet "github.com/shirou/gopsutil/v3/net"
 )
 
 type WindowsCollector struct{}
@@ -33,7 +33,7 @@ func formatDuration(seconds uint64) string {
 	return fmt.Sprintf("%d day(s) %d hr(s) %d min(s) %d sec(s)", days, hours, mins, secs)
 }
 
-func (w WindowsCollector) GetSystemSummary(userID string, machineId string) (*models.Systeminfo, error) {
+func (w WindowsCollector) GetInventory(userID string, machineId string) (*models.Inventory, error) {
 	hostInfo, err := utils.HostInfo()
 	if err != nil {
 		return nil, err
@@ -47,6 +47,38 @@ func (w WindowsCollector) GetSystemSummary(userID string, machineId string) (*mo
 	_, memTotal, _, _ := utils.GetMemoryUsage()
 	diskInfo, _ := utils.GetDiskPartitions(false)
 	netInterfaces, _ := utils.GetNetworkInterfaces()
+
+	root := configs.LoadSettings().HostRoot
+	hardware := readHardware(root)
+
+	inventory := &models.Inventory{
+		UserID:          userID,
+		MachineID:       machineId,
+		Hostname:        hostInfo.Hostname,
+		IPAddress:       getIP(),
+		OS:              fmt.Sprintf("%s %s (%s)", hostInfo.Platform, hostInfo.PlatformVersion, runtime.GOARCH),
+		Platform:        hostInfo.Platform,
+		PlatformVersion: hostInfo.PlatformVersion,
+		KernelVersion:   hostInfo.KernelVersion,
+		Arch:            runtime.GOARCH,
+		CPUModel:        cpuInfo[0].ModelName,
+		CPUCores:        runtime.NumCPU(),
+		RAMMB:           float64(memTotal) / (1024 * 1024),
+		DiskCount:       len(diskInfo),
+		NICCount:        len(netInterfaces),
+		Hardware:        hardware,
+		OSDetails:       readOSDetails(root, hardware),
+	}
+
+	return inventory, nil
+}
+
+func (w WindowsCollector) GetCounters(userID string, machineId string) (*models.SystemCounters, error) {
+	hostInfo, err := utils.HostInfo()
+	if err != nil {
+		return nil, err
+	}
+
 	procsCount, _ := utils.GetProcessCount()
 	currentUser, _ := user.Current()
 
@@ -57,36 +89,23 @@ func (w WindowsCollector) GetSystemSummary(userID string, machineId string) (*mo
 
 	loginCount := getLoggedInUserCount()
 
-	portCount, err := getOpenPortCount()
-	if err != nil {
-		fmt.Println("Error getting open port count:", err)
-	} else {
-		fmt.Println("Open Port Count:", portCount)
-	}
-
-	ip := getIP()
-
-	summary := &models.Systeminfo{
+	counters := &models.SystemCounters{
 		UserID:            userID,
 		MachineID:         machineId,
 		Hostname:          hostInfo.Hostname,
-		IPAddress:         ip,
-		OS:                fmt.Sprintf("%s %s (%s)", hostInfo.Platform, hostInfo.PlatformVersion, runtime.GOARCH),
-		CPUModel:          cpuInfo[0].ModelName,
-		CPUCores:          runtime.NumCPU(),
-		RAMMB:             float64(memTotal) / (1024 * 1024),
-		DiskCount:         len(diskInfo),
 		SysLogsErrorCount: systemLogErrorCount,
 		LoginCount:        loginCount,
-		OpenPortCount:     portCount,
+		OpenPortCount:     sockets.ListenCount(),
 		Uptime:            formatDuration(hostInfo.Uptime),
 		BootTime:          hostInfo.BootTime,
 		TotalProcesses:    procsCount,
-		NICCount:          len(netInterfaces),
-		CurrentUser:       currentUser.Username,
+		Timestamp:         time.Now().Unix(),
+	}
+	if currentUser != nil {
+		counters.CurrentUser = currentUser.Username
 	}
 
-	return summary, nil
+	return counters, nil
 }
 
 func getIP() string {
@@ -145,21 +164,6 @@ func getLoggedInUserCount() int {
 	return len(users)
 }
 
-func getOpenPortCount() (int, error) {
-	conns, err := psnet.Connections("all") // "tcp", "udp", or "all"
-	if err != nil {
-		return 0, err
-	}
-
-	count := 0
-	for _, conn := range conns {
-		if conn.Status == "LISTEN" || conn.Status == "ESTABLISHED" {
-			count++
-		}
-	}
-	return count, nil
-}
-
 func GetSystemInfoCollector() Collector {
 	return WindowsCollector{}
 }
@@ -0,0 +1,69 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"iDevopzAgent/internal/localapi"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// Serve starts the local listener for /healthz and /debug/vars on addr. It
+// returns once the address is bound, so a bad address is reported at
+// startup. As for the local API, an address other than loopback requires
+// a token, which clients then send as a bearer token.
+func Serve(addr, token string) error {
+	ln, err := localapi.Listen(addr, token)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/debug/vars", handleVars)
+
+	srv := &http.Server{
+		Handler:           localapi.ReadOnly(token, mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			log.Error("telemetry listener stopped", "err", err)
+		}
+	}()
+	log.Info("telemetry listener started", "addr", ln.Addr().String(), "auth", token != "")
+	return nil
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ok, reason := Healthy()
+	body := map[string]any{
+		"status": "ok",
+	}
+	if !ok {
+		body["status"] = "unhealthy"
+		body["reason"] = reason
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// handleVars serves the stats in the expvar format. The expvar package
+// itself is not used because it publishes the command line, which carries
+// the device key.
+func handleVars(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(map[string]any{
+		"agent":    Snapshot(),
+		"memstats": &mem,
+	})
+}
@@ -0,0 +1,158 @@
+package telemetry
+
+import (
+	"iDevopzAgent/configs"
+	"iDevopzAgent/httpclient"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/internal/logtail"
+	"iDevopzAgent/models"
+	"iDevopzAgent/sender"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+var log = logging.For("telemetry")
+
+var (
+	mu         sync.Mutex
+	collectors = map[string]*models.CollectorStats{}
+	startedAt  = time.Now()
+
+	self     *process.Process
+	selfOnce sync.Once
+)
+
+// ObserveCollection records one run of a collector.
+func ObserveCollection(name string, d time.Duration, err error) {
+	ms := float64(d.Microseconds()) / 1000
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, ok := collectors[name]
+	if !ok {
+		s = &models.CollectorStats{}
+		collectors[name] = s
+	}
+	s.Runs++
+	s.LastDurationMs = ms
+	s.AvgDurationMs += (ms - s.AvgDurationMs) / float64(s.Runs)
+	if ms > s.MaxDurationMs {
+		s.MaxDurationMs = ms
+	}
+	s.LastRun = time.Now().Unix()
+	if err != nil {
+		s.Errors++
+		s.LastError = err.Error()
+	} else {
+		s.LastError = ""
+	}
+}
+
+// Snapshot returns the agent's current stats. Identity fields are left for
+// the caller.
+func Snapshot() *models.AgentHeartbeat {
+	now := time.Now()
+	conn := httpclient.Status()
+
+	hb := &models.AgentHeartbeat{
+		Version:        configs.Version,
+		StartedAt:      startedAt.Unix(),
+		UptimeSeconds:  int64(now.Sub(startedAt).Seconds()),
+		Goroutines:     runtime.NumGoroutine(),
+		QueueDepth:     sender.QueueDepth(),
+		LastProxyError: conn.LastProxyError,
+		Collectors:     map[string]models.CollectorStats{},
+		Endpoints:      map[string]models.EndpointStats{},
+		Timestamp:      now.Unix(),
+	}
+	if !conn.LastSuccessAt.IsZero() {
+		hb.LastSuccessfulSend = conn.LastSuccessAt.Unix()
+	}
+	hb.LogBytesDropped = logtail.GetForwarder().DroppedBytes()
+
+	selfOnce.Do(func() {
+		p, err := process.NewProcess(int32(os.Getpid()))
+		if err != nil {
+			log.Warn("cannot read own process stats", "err", err)
+			return
+		}
+		self = p
+	})
+	if self != nil {
+		if mem, err := self.MemoryInfo(); err == nil {
+			hb.RSSBytes = mem.RSS
+		}
+		// CPU since the previous snapshot
+		if cpu, err := self.Percent(0); err == nil {
+			hb.CPUPercent = cpu
+		}
+	}
+
+	mu.Lock()
+	for name, s := range collectors {
+		hb.Collectors[name] = *s
+	}
+	mu.Unlock()
+
+	for key, e := range httpclient.Endpoints() {
+		stat := models.EndpointStats{
+			Success:    e.Success,
+			Failure:    e.Failure,
+			LastStatus: e.LastStatus,
+			LastError:  e.LastError,
+		}
+		if !e.LastSuccessAt.IsZero() {
+			stat.LastSuccess = e.LastSuccessAt.Unix()
+		}
+		hb.Endpoints[key] = stat
+	}
+	return hb
+}
+
+// Healthy reports whether the agent is getting data to the backend, i.e.
+// the backend accepted a batch, heartbeat or metrics sample within
+// StaleSeconds. A freshly started agent gets StaleSeconds for its first.
+func Healthy() (bool, string) {
+	stale := time.Duration(configs.LoadSettings().Telemetry.StaleSeconds) * time.Second
+	if stale <= 0 {
+		return true, ""
+	}
+
+	last := sender.LastAck()
+	if last.IsZero() {
+		if time.Since(startedAt) < stale {
+			return true, ""
+		}
+		return false, "the backend has accepted no data since start"
+	}
+	if time.Since(last) > stale {
+		if proxyErr := httpclient.Status().LastProxyError; proxyErr != "" {
+			return false, "the backend accepted no data for " + time.Since(last).Round(time.Second).String() + ", last proxy error: " + proxyErr
+		}
+		return false, "the backend accepted no data for " + time.Since(last).Round(time.Second).String()
+	}
+	return true, ""
+}
+
+// RunHeartbeat sends the agent's stats every HeartbeatSeconds.
+func RunHeartbeat(userID, machineID, hostname string) {
+	interval := time.Duration(configs.LoadSettings().Telemetry.HeartbeatSeconds) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		hb := Snapshot()
+		hb.UserID = userID
+		hb.MachineID = machineID
+		hb.Hostname = hostname
+		sender.SendHeartbeat(hb)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package update
+
+import (
+	"os"
+	"syscall"
+)
+
+// restart replaces the running process with exe, keeping the pid so the
+// service manager does not notice.
+func restart(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package update
+
+import (
+	"os"
+	"os/exec"
+)
+
+// restart starts exe with the same arguments and exits, as Windows has no
+// exec.
+func restart(exe string) error {
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,212 @@
+package update
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iDevopzAgent/configs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	statusPending    = "pending"
+	statusCommitted  = "committed"
+	statusRolledBack = "rolled_back"
+)
+
+// maxStarts is how often a pending version may start before it counts as
+// crash-looping and is rolled back.
+const maxStarts = 3
+
+// state is persisted to update_state.json across the restart into the new
+// binary. Bad is the last version that was rolled back; it is not
+// installed again.
+type state struct {
+	Status      string `json:"status"`
+	FromVersion string `json:"from_version"`
+	ToVersion   string `json:"to_version"`
+	InstalledAt int64  `json:"installed_at"`
+	Deadline    int64  `json:"deadline"`
+	Starts      int    `json:"starts"`
+	Bad         string `json:"bad,omitempty"`
+	Reported    bool   `json:"reported,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// dataDir holds update_state.json.
+var dataDir = configs.DataDir
+
+func statePath() string {
+	return filepath.Join(dataDir(), "update_state.json")
+}
+
+func loadState() (*state, error) {
+	data, err := os.ReadFile(statePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func saveState(st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := statePath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statePath())
+}
+
+// Startup must run first in main. A freshly installed version that keeps
+// crashing, or that is started again after its health deadline passed, is
+// replaced by the previous binary before it can do anything else.
+func Startup() {
+	st, err := loadState()
+	if err != nil || st == nil || st.Status != statusPending || st.ToVersion != configs.Version {
+		return
+	}
+
+	st.Starts++
+	reason := ""
+	switch {
+	case st.Starts > maxStarts:
+		reason = fmt.Sprintf("restarted %d times without becoming healthy", st.Starts-1)
+	case time.Now().Unix() > st.Deadline:
+		reason = "not healthy before the deadline"
+	}
+	if reason == "" {
+		if err := saveState(st); err != nil {
+			log.Error("failed to save update state", "err", err)
+		}
+		return
+	}
+
+	log.Warn("update failed, rolling back", "version", st.ToVersion, "reason", reason, "previous", st.FromVersion)
+	exe, err := executable()
+	if err == nil {
+		err = rollbackState(exe, st, reason)
+	}
+	if err != nil {
+		log.Error("rollback failed", "err", err)
+		return
+	}
+	if err := restart(exe); err != nil {
+		// let the service manager start the restored binary
+		log.Error("restart after rollback failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+// watchHealth commits the running version once the backend accepted data
+// it sent, or rolls it back when the window passes first. Registering or
+// fetching the manifest is not enough: the version must deliver.
+func (u *Updater) watchHealth(window time.Duration) {
+	st, err := loadState()
+	if err != nil || st == nil {
+		return
+	}
+	if st.Status == statusRolledBack && !st.Reported {
+		u.reportState(st)
+		return
+	}
+	if st.Status != statusPending || st.ToVersion != u.Version {
+		return
+	}
+
+	started := time.Now()
+	deadline := time.Unix(st.Deadline, 0)
+	if min := started.Add(window); deadline.Before(min) && window > 0 {
+		deadline = min
+	}
+
+	ticker := time.NewTicker(u.healthPoll)
+	defer ticker.Stop()
+	for range ticker.C {
+		if u.Acked().After(started) {
+			st.Status = statusCommitted
+			if err := saveState(st); err != nil {
+				log.Error("failed to save update state", "err", err)
+			}
+			os.Remove(u.ExePath + ".prev")
+			log.Info("update is healthy", "version", u.Version)
+			u.report(u.Version, statusCommitted, nil)
+			return
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+	}
+
+	log.Warn("update did not deliver data in time, rolling back", "version", u.Version, "window", window)
+	if err := rollbackState(u.ExePath, st, "no data accepted by the backend before the deadline"); err != nil {
+		log.Error("rollback failed", "err", err)
+		return
+	}
+	if err := u.Restart(u.ExePath); err != nil {
+		log.Error("restart after rollback failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+// reportState sends the outcome of a rollback done by the new binary, now
+// that the restored one is running and registered.
+func (u *Updater) reportState(st *state) {
+	u.mu.Lock()
+	machineID := u.machineID
+	u.mu.Unlock()
+	if machineID == "" {
+		return
+	}
+
+	saved := u.Version
+	u.Version = st.FromVersion
+	u.report(st.ToVersion, statusRolledBack, errors.New(st.Error))
+	u.Version = saved
+
+	st.Reported = true
+	if err := saveState(st); err != nil {
+		log.Error("failed to save update state", "err", err)
+	}
+}
+
+func rollbackState(exe string, st *state, reason string) error {
+	if err := rollback(exe); err != nil {
+		return err
+	}
+	st.Status = statusRolledBack
+	st.Bad = st.ToVersion
+	st.Error = reason
+	return saveState(st)
+}
+
+// rollback puts the backup taken at install time back in place. The
+// running binary is renamed rather than overwritten, which Windows allows.
+func rollback(exe string) error {
+	backup := exe + ".prev"
+	if _, err := os.Stat(backup); err != nil {
+		return fmt.Errorf("no previous binary to roll back to: %w", err)
+	}
+	failed := exe + ".failed"
+	os.Remove(failed)
+	if err := os.Rename(exe, failed); err != nil {
+		return err
+	}
+	if err := os.Rename(backup, exe); err != nil {
+		os.Rename(failed, exe)
+		return err
+	}
+	os.Remove(failed)
+	return nil
+}
@@ -0,0 +1,361 @@
+package update
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/httpclient"
+	"iDevopzAgent/internal/logging"
+	"iDevopzAgent/models"
+	"iDevopzAgent/security"
+	"iDevopzAgent/sender"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var log = logging.For("update")
+
+// maxBinarySize bounds the download so a broken manifest cannot fill the disk.
+const maxBinarySize = 256 << 20
+
+// Artifact is the release binary for one platform.
+type Artifact struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"` // hex
+	Size   int64  `json:"size,omitempty"`
+}
+
+// Manifest describes the latest release of a channel. Artifacts is keyed by
+// GOOS/GOARCH, e.g. "linux/amd64".
+type Manifest struct {
+	Version   string              `json:"version"`
+	Channel   string              `json:"channel"`
+	Artifacts map[string]Artifact `json:"artifacts"`
+}
+
+// signedManifest is the manifest endpoint's response: the manifest exactly
+// as signed and the base64 ed25519 signature over those bytes. The
+// signature covers the artifact checksums, so a verified checksum ties the
+// binary to the release key.
+type signedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"`
+}
+
+// Updater checks the manifest and installs newer releases.
+type Updater struct {
+	ManifestURL string
+	PublicKey   string
+	Channel     string
+	ExePath     string // binary to replace
+	Version     string // running version
+
+	// Restart starts the installed binary; it does not return on success.
+	Restart func(exe string) error
+	// Acked returns when the backend last accepted collected data; a new
+	// version is committed once that happens after it started.
+	Acked func() time.Time
+	// Report sends the outcome of an update to the backend.
+	Report func(*models.UpdateStatus)
+
+	healthPoll time.Duration
+
+	mu        sync.Mutex
+	machineID string
+}
+
+var (
+	updaterOnce sync.Once
+	updater     *Updater
+)
+
+// GetUpdater returns the updater configured from the agent settings.
+func GetUpdater() *Updater {
+	updaterOnce.Do(func() {
+		s := configs.LoadSettings().Update
+		u := &Updater{
+			ManifestURL: s.ManifestURL,
+			PublicKey:   s.PublicKey,
+			Channel:     s.Channel,
+			Version:     configs.Version,
+			Restart:     restart,
+			Acked:       sender.LastAck,
+			Report:      sender.SendUpdateStatus,
+			healthPoll:  10 * time.Second,
+		}
+		if u.ManifestURL == "" {
+			u.ManifestURL = configs.LoadConfig().APIEndpoint + "/api/go/agent/update/manifest"
+		}
+		if u.PublicKey == "" {
+			u.PublicKey = security.ReleasePublicKey
+		}
+		if exe, err := executable(); err == nil {
+			u.ExePath = exe
+		}
+		updater = u
+	})
+	return updater
+}
+
+// Run checks for updates every CheckMinutes and commits or rolls back a
+// freshly installed version once its health is known.
+func (u *Updater) Run(machineID string) {
+	u.mu.Lock()
+	u.machineID = machineID
+	u.mu.Unlock()
+
+	settings := configs.LoadSettings().Update
+	go u.watchHealth(time.Duration(settings.HealthCheckMinutes) * time.Minute)
+
+	if !settings.Enabled {
+		return
+	}
+	if u.PublicKey == "" {
+		log.Warn("self-update disabled: no release public key configured")
+		return
+	}
+
+	interval := time.Duration(settings.CheckMinutes) * time.Minute
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	for {
+		if err := u.CheckAndInstall(); err != nil {
+			log.Error("self-update failed", "err", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// CheckAndInstall installs the manifest's release if it is newer than the
+// running version, then restarts into it.
+func (u *Updater) CheckAndInstall() error {
+	m, err := u.fetchManifest()
+	if err != nil {
+		return err
+	}
+	if compareVersions(m.Version, u.Version) <= 0 {
+		return nil
+	}
+	if st, _ := loadState(); st != nil && st.Bad == m.Version {
+		return nil // rolled back before, wait for the next release
+	}
+
+	artifact, ok := m.Artifacts[runtime.GOOS+"/"+runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s", m.Version, runtime.GOOS, runtime.GOARCH)
+	}
+
+	log.Info("updating agent", "from", u.Version, "to", m.Version)
+	if err := u.install(m.Version, artifact); err != nil {
+		u.report(m.Version, "failed", err)
+		return err
+	}
+	u.report(m.Version, "installed", nil)
+
+	if err := u.Restart(u.ExePath); err != nil {
+		// the new binary could not even be started, put the old one back
+		err = fmt.Errorf("restart into %s: %w", m.Version, err)
+		st := &state{FromVersion: u.Version, ToVersion: m.Version, Reported: true}
+		if rbErr := rollbackState(u.ExePath, st, err.Error()); rbErr != nil {
+			err = errors.Join(err, rbErr)
+		}
+		u.report(m.Version, statusRolledBack, err)
+		return err
+	}
+	return nil
+}
+
+func (u *Updater) fetchManifest() (*Manifest, error) {
+	resp, err := httpclient.SendGET(u.ManifestURL, map[string]string{
+		"os":      runtime.GOOS,
+		"arch":    runtime.GOARCH,
+		"channel": u.Channel,
+		"version": u.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("manifest: status %s", resp.Status)
+	}
+
+	var signed signedManifest
+	if err := json.Unmarshal(body, &signed); err != nil || len(signed.Manifest) == 0 {
+		return nil, errors.New("manifest: malformed response")
+	}
+	if err := security.VerifyEd25519(u.PublicKey, signed.Manifest, signed.Signature); err != nil {
+		return nil, fmt.Errorf("manifest signature: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(signed.Manifest, &m); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	if m.Channel != "" && u.Channel != "" && m.Channel != u.Channel {
+		return nil, fmt.Errorf("manifest is for channel %q, not %q", m.Channel, u.Channel)
+	}
+	return &m, nil
+}
+
+// install downloads and verifies the binary next to the running one, then
+// swaps it in with renames so the path always holds a complete binary.
+func (u *Updater) install(version string, a Artifact) error {
+	if u.ExePath == "" {
+		return errors.New("cannot locate the running binary")
+	}
+	want, err := hex.DecodeString(a.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("manifest checksum %q is not a hex SHA-256", a.SHA256)
+	}
+
+	newPath := u.ExePath + ".new"
+	if err := download(a.URL, newPath, want); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	if err := checkBinary(newPath, version); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+
+	backup := u.ExePath + ".prev"
+	os.Remove(backup)
+	if err := os.Rename(u.ExePath, backup); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("back up current binary: %w", err)
+	}
+	if err := os.Rename(newPath, u.ExePath); err != nil {
+		os.Rename(backup, u.ExePath)
+		return fmt.Errorf("install new binary: %w", err)
+	}
+
+	return saveState(&state{
+		Status:      statusPending,
+		FromVersion: u.Version,
+		ToVersion:   version,
+		InstalledAt: time.Now().Unix(),
+		Deadline:    time.Now().Add(time.Duration(configs.LoadSettings().Update.HealthCheckMinutes) * time.Minute).Unix(),
+	})
+}
+
+func download(url, path string, want []byte) error {
+	resp, err := httpclient.SendGET(url, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("download %s: status %s", url, resp.Status)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(resp.Body, maxBinarySize+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	if n > maxBinarySize {
+		return fmt.Errorf("download %s: binary larger than %d bytes", url, maxBinarySize)
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("checksum mismatch: got %x, want %x", got, want)
+	}
+	return nil
+}
+
+// checkBinary runs the new binary with --version to make sure it starts on
+// this host and is the release the manifest announced.
+func checkBinary(path, version string) error {
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("new binary does not run: %w", err)
+	}
+	if got := strings.TrimSpace(string(out)); strings.TrimPrefix(got, "v") != strings.TrimPrefix(version, "v") {
+		return fmt.Errorf("new binary reports version %q, manifest says %q", got, version)
+	}
+	return nil
+}
+
+func (u *Updater) report(to, status string, err error) {
+	u.mu.Lock()
+	machineID := u.machineID
+	u.mu.Unlock()
+
+	s := &models.UpdateStatus{
+		MachineID:   machineID,
+		FromVersion: u.Version,
+		ToVersion:   to,
+		Status:      status,
+		Timestamp:   time.Now().Unix(),
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	u.Report(s)
+}
+
+func executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// compareVersions compares dotted numeric versions, ignoring a leading "v".
+// A pre-release suffix ("1.2.0-rc1") sorts before the release.
+func compareVersions(a, b string) int {
+	split := func(v string) ([]string, string) {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+		v, pre, _ := strings.Cut(v, "-")
+		return strings.Split(v, "."), pre
+	}
+	pa, preA := split(a)
+	pb, preB := split(b)
+
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(pb[i])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return strings.Compare(preA, preB)
+}
@@ -0,0 +1,323 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"iDevopzAgent/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// releaseServer stands in for the manifest endpoint and the download host.
+type releaseServer struct {
+	*httptest.Server
+	priv   ed25519.PrivateKey
+	pub    string
+	binary []byte
+}
+
+func newReleaseServer(t *testing.T, version string) *releaseServer {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := &releaseServer{
+		priv:   priv,
+		pub:    base64.StdEncoding.EncodeToString(pub),
+		binary: versionScript(version),
+	}
+	rs.Server = httptest.NewServer(http.HandlerFunc(rs.serve))
+	t.Cleanup(rs.Close)
+	return rs
+}
+
+// manifest returns the signed manifest announcing version with the
+// checksum of the served binary.
+func (rs *releaseServer) manifest(version string, sum []byte, key ed25519.PrivateKey) []byte {
+	m, _ := json.Marshal(Manifest{
+		Version: version,
+		Channel: "stable",
+		Artifacts: map[string]Artifact{
+			runtime.GOOS + "/" + runtime.GOARCH: {URL: rs.URL + "/agent.bin", SHA256: hex.EncodeToString(sum)},
+		},
+	})
+	body, _ := json.Marshal(signedManifest{
+		Manifest:  m,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, m)),
+	})
+	return body
+}
+
+func (rs *releaseServer) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/agent.bin":
+		w.Write(rs.binary)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// versionScript is a stand-in agent binary that answers --version.
+func versionScript(version string) []byte {
+	return []byte("#!/bin/sh\necho " + version + "\n")
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	reports  []*models.UpdateStatus
+	restarts int
+}
+
+func (r *recorder) report(s *models.UpdateStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, s)
+}
+
+func (r *recorder) statuses() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, s := range r.reports {
+		out = append(out, s.Status)
+	}
+	return out
+}
+
+// newTestUpdater returns an updater replacing a 1.0.0 binary in a temp
+// directory, with update_state.json kept there too.
+func newTestUpdater(t *testing.T, manifestURL, pub string) (*Updater, *recorder) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("stand-in binaries are shell scripts")
+	}
+	dir := t.TempDir()
+	saved := dataDir
+	dataDir = func() string { return dir }
+	t.Cleanup(func() { dataDir = saved })
+
+	exe := filepath.Join(dir, "agent")
+	if err := os.WriteFile(exe, versionScript("1.0.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recorder{}
+	u := &Updater{
+		ManifestURL: manifestURL,
+		PublicKey:   pub,
+		Channel:     "stable",
+		ExePath:     exe,
+		Version:     "1.0.0",
+		Restart: func(string) error {
+			rec.mu.Lock()
+			rec.restarts++
+			rec.mu.Unlock()
+			return nil
+		},
+		Acked:      func() time.Time { return time.Time{} },
+		Report:     rec.report,
+		healthPoll: 10 * time.Millisecond,
+	}
+	return u, rec
+}
+
+func serveManifest(t *testing.T, body []byte) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCheckAndInstall(t *testing.T) {
+	rs := newReleaseServer(t, "1.1.0")
+	sum := sha256.Sum256(rs.binary)
+	u, rec := newTestUpdater(t, serveManifest(t, rs.manifest("1.1.0", sum[:], rs.priv)), rs.pub)
+
+	if err := u.CheckAndInstall(); err != nil {
+		t.Fatalf("CheckAndInstall: %v", err)
+	}
+	if got := readFile(t, u.ExePath); got != string(rs.binary) {
+		t.Fatalf("installed binary = %q", got)
+	}
+	if got := readFile(t, u.ExePath+".prev"); got != string(versionScript("1.0.0")) {
+		t.Fatalf("backup = %q", got)
+	}
+	st, err := loadState()
+	if err != nil || st == nil || st.Status != statusPending || st.ToVersion != "1.1.0" {
+		t.Fatalf("state = %+v, %v", st, err)
+	}
+	if rec.restarts != 1 {
+		t.Fatalf("restarts = %d", rec.restarts)
+	}
+	if got := strings.Join(rec.statuses(), ","); got != "installed" {
+		t.Fatalf("reports = %s", got)
+	}
+}
+
+func TestCheckAndInstallRejects(t *testing.T) {
+	rs := newReleaseServer(t, "1.1.0")
+	sum := sha256.Sum256(rs.binary)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	wrongSum := sha256.Sum256([]byte("another binary"))
+
+	for _, tc := range []struct {
+		name     string
+		manifest []byte
+		want     string
+		reported string
+	}{
+		{"bad signature", rs.manifest("1.1.0", sum[:], otherKey), "manifest signature", ""},
+		{"checksum mismatch", rs.manifest("1.1.0", wrongSum[:], rs.priv), "checksum mismatch", "failed"},
+		{"wrong version", rs.manifest("1.2.0", sum[:], rs.priv), "reports version", "failed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u, rec := newTestUpdater(t, serveManifest(t, tc.manifest), rs.pub)
+
+			err := u.CheckAndInstall()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("want error containing %q, got %v", tc.want, err)
+			}
+			if got := readFile(t, u.ExePath); got != string(versionScript("1.0.0")) {
+				t.Fatalf("binary replaced: %q", got)
+			}
+			if _, err := os.Stat(u.ExePath + ".new"); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("download left behind: %v", err)
+			}
+			if got := strings.Join(rec.statuses(), ","); got != tc.reported {
+				t.Fatalf("reports = %q, want %q", got, tc.reported)
+			}
+		})
+	}
+}
+
+func TestCheckAndInstallCurrentVersion(t *testing.T) {
+	rs := newReleaseServer(t, "1.0.0")
+	sum := sha256.Sum256(rs.binary)
+	u, rec := newTestUpdater(t, serveManifest(t, rs.manifest("1.0.0", sum[:], rs.priv)), rs.pub)
+
+	if err := u.CheckAndInstall(); err != nil {
+		t.Fatal(err)
+	}
+	if rec.restarts != 0 || len(rec.statuses()) != 0 {
+		t.Fatalf("installed the running version: %d restarts, reports %v", rec.restarts, rec.statuses())
+	}
+}
+
+// pendingInstall sets up the state left by installing 1.1.0 over 1.0.0.
+func pendingInstall(t *testing.T, u *Updater, deadline time.Time) {
+	t.Helper()
+	if err := os.WriteFile(u.ExePath+".prev", versionScript("1.0.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(u.ExePath, versionScript("1.1.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	u.Version = "1.1.0"
+	if err := saveState(&state{Status: statusPending, FromVersion: "1.0.0", ToVersion: "1.1.0", Deadline: deadline.Unix()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchHealthCommitsAfterAck(t *testing.T) {
+	u, rec := newTestUpdater(t, "", "")
+	pendingInstall(t, u, time.Now().Add(time.Minute))
+
+	var acked time.Time
+	var mu sync.Mutex
+	u.Acked = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return acked
+	}
+	done := make(chan struct{})
+	go func() {
+		u.watchHealth(0)
+		close(done)
+	}()
+
+	// no ack yet: still pending
+	time.Sleep(50 * time.Millisecond)
+	if st, _ := loadState(); st.Status != statusPending {
+		t.Fatalf("committed without an ack: %s", st.Status)
+	}
+
+	mu.Lock()
+	acked = time.Now()
+	mu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchHealth did not return after the ack")
+	}
+
+	if st, _ := loadState(); st.Status != statusCommitted {
+		t.Fatalf("state = %s", st.Status)
+	}
+	if _, err := os.Stat(u.ExePath + ".prev"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("backup kept after commit: %v", err)
+	}
+	if got := strings.Join(rec.statuses(), ","); got != statusCommitted {
+		t.Fatalf("reports = %s", got)
+	}
+}
+
+func TestWatchHealthRollsBackWithoutAck(t *testing.T) {
+	u, rec := newTestUpdater(t, "", "")
+	pendingInstall(t, u, time.Now().Add(-time.Second))
+
+	// an ack from before the new version started does not count
+	u.Acked = func() time.Time { return time.Now().Add(-time.Hour) }
+	u.watchHealth(0)
+
+	st, _ := loadState()
+	if st.Status != statusRolledBack || st.Bad != "1.1.0" {
+		t.Fatalf("state = %+v", st)
+	}
+	if got := readFile(t, u.ExePath); got != string(versionScript("1.0.0")) {
+		t.Fatalf("binary after rollback = %q", got)
+	}
+	if rec.restarts != 1 {
+		t.Fatalf("restarts = %d", rec.restarts)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.0", "1.2.0", 0},
+		{"v1.2.0", "1.2", 0},
+		{"1.10.0", "1.9.9", 1},
+		{"1.2.0-rc1", "1.2.0", -1},
+		{"1.2.0-rc2", "1.2.0-rc1", 1},
+	} {
+		if got := compareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
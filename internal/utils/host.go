@@ -30,3 +30,8 @@ func GetUptime() (uint64, error) {
 func HostInfo() (*host.InfoStat, error) {
 	return host.Info()
 }
+
+// GetBootTime returns the system boot time as unix seconds
+func GetBootTime() (uint64, error) {
+	return host.BootTime()
+}
@@ -0,0 +1,30 @@
+package models
+
+// AlertEvent is sent when an alert rule starts firing or resolves.
+type AlertEvent struct {
+	UserID    string  `json:"user_id"`
+	MachineID string  `json:"machineId"`
+	Hostname  string  `json:"hostname"`
+	Rule      string  `json:"rule"`
+	Metric    string  `json:"metric"`
+	Instance  string  `json:"instance,omitempty"` // e.g. mountpoint for partition rules
+	Severity  string  `json:"severity"`
+	State     string  `json:"state"` // firing, resolved
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	StartedAt int64   `json:"started_at"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// ActiveAlert is a rule/instance pair whose condition currently holds,
+// either still waiting out its "for" duration (pending) or firing.
+type ActiveAlert struct {
+	Rule      string  `json:"rule"`
+	Metric    string  `json:"metric"`
+	Instance  string  `json:"instance,omitempty"`
+	Severity  string  `json:"severity"`
+	State     string  `json:"state"` // pending, firing
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Since     int64   `json:"since"`
+}
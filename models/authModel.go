@@ -0,0 +1,55 @@
+package models
+
+// LoginEvent is a successful login. Origin names where it was read: wtmp,
+// auth_log or journal.
+type LoginEvent struct {
+	User     string `json:"user"`
+	SourceIP string `json:"source_ip,omitempty"`
+	Service  string `json:"service"` // e.g. sshd, login
+	Method   string `json:"method,omitempty"`
+	TTY      string `json:"tty,omitempty"`
+	Origin   string `json:"origin"`
+	Time     int64  `json:"time"`
+}
+
+// FailedLogins counts the failed attempts from one source IP against one
+// user during a report interval. InvalidUser is set when the user does not
+// exist on the host.
+type FailedLogins struct {
+	User        string `json:"user"`
+	SourceIP    string `json:"source_ip,omitempty"`
+	Service     string `json:"service"`
+	InvalidUser bool   `json:"invalid_user,omitempty"`
+	Count       int    `json:"count"`
+	FirstSeen   int64  `json:"first_seen"`
+	LastSeen    int64  `json:"last_seen"`
+}
+
+// SudoEvent is one sudo invocation, allowed or denied. FirstUse is set the
+// first time the agent sees User run sudo on the host.
+type SudoEvent struct {
+	User     string `json:"user"`
+	RunAs    string `json:"run_as,omitempty"`
+	Command  string `json:"command,omitempty"`
+	TTY      string `json:"tty,omitempty"`
+	PWD      string `json:"pwd,omitempty"`
+	Allowed  bool   `json:"allowed"`
+	Reason   string `json:"reason,omitempty"` // why it was denied
+	FirstUse bool   `json:"first_use,omitempty"`
+	Time     int64  `json:"time"`
+}
+
+// AuthReport is the authentication activity between IntervalStart and
+// IntervalEnd. Sessions is the number of logged-in sessions at the end.
+type AuthReport struct {
+	UserID        string         `json:"user_id"`
+	MachineID     string         `json:"machineId"`
+	Hostname      string         `json:"hostname"`
+	IntervalStart int64          `json:"interval_start"`
+	IntervalEnd   int64          `json:"interval_end"`
+	Logins        []LoginEvent   `json:"logins"`
+	Failures      []FailedLogins `json:"failures"`
+	Sudo          []SudoEvent    `json:"sudo"`
+	Sessions      int            `json:"sessions"`
+	Sources       []string       `json:"sources"` // auth_log, journal, wtmp, btmp
+}
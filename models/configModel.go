@@ -3,4 +3,10 @@ package models
 type Config struct {
 	UserID    string `json:"user_id"`
 	MachineID string `json:"MachineID"`
+
+	// Agent credentials issued by the backend at registration; the tokens
+	// are stored encrypted like the IDs above.
+	AgentToken   string `json:"agent_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenExpiry  int64  `json:"token_expiry,omitempty"`
 }
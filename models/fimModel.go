@@ -0,0 +1,32 @@
+package models
+
+// FileMeta is the baseline of one monitored file. SHA256 is empty for
+// files too large to hash. Mode is the octal permission and type bits.
+type FileMeta struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size"`
+	Mode   string `json:"mode"`
+	UID    int    `json:"uid"`
+	GID    int    `json:"gid"`
+	Owner  string `json:"owner,omitempty"`
+	Group  string `json:"group,omitempty"`
+	MTime  int64  `json:"mtime"`
+}
+
+// FileChangeEvent reports a monitored file that was created, modified
+// (content), changed attributes only, or deleted. Fields lists what
+// differs between Before and After; Before is nil for a created file and
+// After for a deleted one. DetectedBy is inotify or rescan.
+type FileChangeEvent struct {
+	UserID     string    `json:"user_id"`
+	MachineID  string    `json:"machineId"`
+	Hostname   string    `json:"hostname"`
+	Path       string    `json:"path"`
+	Change     string    `json:"change"` // created, modified, attributes, deleted
+	Fields     []string  `json:"fields,omitempty"`
+	Before     *FileMeta `json:"before,omitempty"`
+	After      *FileMeta `json:"after,omitempty"`
+	DetectedBy string    `json:"detected_by"`
+	Timestamp  int64     `json:"timestamp"`
+}
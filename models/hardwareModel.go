@@ -0,0 +1,65 @@
+package models
+
+// Hardware is the host's hardware inventory. Fields the agent cannot read
+// (e.g. serials without root) are left empty.
+type Hardware struct {
+	System       SystemHardware `json:"system"`
+	CPU          CPUHardware    `json:"cpu"`
+	Memory       []DIMM         `json:"memory"`
+	BlockDevices []BlockDevice  `json:"block_devices"`
+	NICs         []NIC          `json:"nics"`
+}
+
+// SystemHardware is the DMI identity of the machine.
+type SystemHardware struct {
+	Manufacturer string `json:"manufacturer"`
+	Product      string `json:"product"`
+	Serial       string `json:"serial"`
+	UUID         string `json:"uuid"`
+	BoardVendor  string `json:"board_vendor"`
+	BoardName    string `json:"board_name"`
+	BIOSVendor   string `json:"bios_vendor"`
+	BIOSVersion  string `json:"bios_version"`
+	BIOSDate     string `json:"bios_date"`
+}
+
+type CPUHardware struct {
+	Model         string   `json:"model"`
+	Vendor        string   `json:"vendor"`
+	Sockets       int      `json:"sockets"`
+	PhysicalCores int      `json:"physical_cores"`
+	Threads       int      `json:"threads"`
+	MaxMHz        float64  `json:"max_mhz"`
+	Flags         []string `json:"flags"`
+}
+
+// DIMM is one populated memory slot.
+type DIMM struct {
+	Locator      string `json:"locator"`
+	Bank         string `json:"bank,omitempty"`
+	SizeMB       uint64 `json:"size_mb"`
+	Type         string `json:"type,omitempty"`
+	SpeedMTs     uint32 `json:"speed_mts,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	PartNumber   string `json:"part_number,omitempty"`
+}
+
+type BlockDevice struct {
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	Vendor     string `json:"vendor,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	SizeBytes  uint64 `json:"size_bytes"`
+	Rotational bool   `json:"rotational"`
+	Removable  bool   `json:"removable"`
+}
+
+type NIC struct {
+	Name      string `json:"name"`
+	MAC       string `json:"mac"`
+	Driver    string `json:"driver,omitempty"`
+	SpeedMbps int    `json:"speed_mbps"` // -1 when unknown, e.g. link down
+	Duplex    string `json:"duplex,omitempty"`
+	MTU       int    `json:"mtu"`
+}
@@ -11,5 +11,18 @@ type HealthReport struct {
 	Downtimes     int     `json:"downtimes"`
 	MetricGetTime string  `json:"metric_get_time"`
 
-	SLA string `json:"sla_achieved"`
+	SLA     string               `json:"sla_achieved"`
+	Windows []AvailabilityWindow `json:"windows"`
+}
+
+// AvailabilityWindow is availability and SLA over one rolling window
+// (24h, 7d, 30d). Percentages are 0-100.
+type AvailabilityWindow struct {
+	Window          string  `json:"window"`
+	Availability    float64 `json:"availability"`
+	SLA             float64 `json:"sla"`
+	SLATarget       float64 `json:"sla_target"`
+	SLAMet          bool    `json:"sla_met"`
+	DowntimeSeconds int64   `json:"downtime_seconds"`
+	Outages         int     `json:"outages"`
 }
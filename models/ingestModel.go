@@ -0,0 +1,34 @@
+package models
+
+import "encoding/json"
+
+// IngestRecord is one payload inside an ingest batch. Type names the
+// collector it came from (metrics, health_report, ...).
+type IngestRecord struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	CollectedAt int64           `json:"collected_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// IngestBatch is the envelope posted to the ingest endpoint.
+type IngestBatch struct {
+	BatchID   string          `json:"batch_id"`
+	MachineID string          `json:"machineId"`
+	SentAt    int64           `json:"sent_at"`
+	Records   []*IngestRecord `json:"records"`
+}
+
+// IngestResult acknowledges a single record: accepted, rejected (dropped
+// for good) or retry.
+type IngestResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IngestResponse is returned by the ingest endpoint. Records missing from
+// Results are retried.
+type IngestResponse struct {
+	Results []IngestResult `json:"results"`
+}
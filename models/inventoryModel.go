@@ -0,0 +1,110 @@
+package models
+
+// Inventory is the static description of the host. It is sent at startup
+// and whenever its Hash changes.
+type Inventory struct {
+	UserID          string     `json:"user_id"`
+	MachineID       string     `json:"machineId"`
+	Hostname        string     `json:"hostname"`
+	IPAddress       string     `json:"ip_address"`
+	OS              string     `json:"os"`
+	Platform        string     `json:"platform"`
+	PlatformVersion string     `json:"platform_version"`
+	KernelVersion   string     `json:"kernel_version"`
+	Arch            string     `json:"arch"`
+	CPUModel        string     `json:"cpu_model"`
+	CPUCores        int        `json:"cpu_cores"`
+	RAMMB           float64    `json:"ram_mb"`
+	DiskCount       int        `json:"disk_count"`
+	NICCount        int        `json:"nic_count"`
+	Hardware        *Hardware  `json:"hardware,omitempty"`
+	OSDetails       *OSDetails `json:"os_details,omitempty"`
+	Hash            string     `json:"hash"` // sha256 of the fields above
+	CollectedAt     int64      `json:"collected_at"`
+}
+
+// OSDetails describes the running OS and kernel and whether the host waits
+// for a reboot. Release fields come from /etc/os-release on Linux.
+type OSDetails struct {
+	Name            string `json:"name"`
+	ID              string `json:"id"`
+	IDLike          string `json:"id_like,omitempty"`
+	Version         string `json:"version"`
+	VersionID       string `json:"version_id"`
+	VersionCodename string `json:"version_codename,omitempty"`
+	PrettyName      string `json:"pretty_name"`
+
+	KernelRelease   string `json:"kernel_release"`
+	KernelCmdline   string `json:"kernel_cmdline,omitempty"`
+	InstalledKernel string `json:"installed_kernel,omitempty"` // newest kernel on disk
+	KernelMismatch  bool   `json:"kernel_mismatch"`
+
+	RebootRequired bool     `json:"reboot_required"`
+	RebootReasons  []string `json:"reboot_reasons,omitempty"`
+	LastBootReason string   `json:"last_boot_reason,omitempty"`
+
+	Virtualization string `json:"virtualization"` // none, kvm, vmware, hyperv, xen, virtualbox, ...
+	Container      string `json:"container,omitempty"`
+	Cloud          string `json:"cloud,omitempty"`
+}
+
+// SystemCounters are the volatile system values, sent on their own
+// interval. On Linux SysLogsErrorCount counts the lines matching an error
+// or critical log rule, plus journal entries of priority err or worse,
+// since the agent started; it resets to 0 when the agent restarts.
+type SystemCounters struct {
+	UserID            string `json:"user_id"`
+	MachineID         string `json:"machineId"`
+	Hostname          string `json:"hostname"`
+	SysLogsErrorCount int    `json:"sys_logs_errors"`
+	Uptime            string `json:"uptime"`
+	BootTime          uint64 `json:"boot_time"`
+	TotalProcesses    int    `json:"total_processes"`
+	LoginCount        int    `json:"login_count"`
+	OpenPortCount     int    `json:"open_port_count"` // TCP listeners seen by the last socket collection
+	CurrentUser       string `json:"current_user"`
+	Timestamp         int64  `json:"timestamp"`
+}
+
+// InventoryChange reports one inventory field that changed between two
+// inventories. Field is a dotted path into the inventory JSON.
+type InventoryChange struct {
+	UserID       string `json:"user_id"`
+	MachineID    string `json:"machineId"`
+	Hostname     string `json:"hostname"`
+	Field        string `json:"field"`
+	Old          any    `json:"old"`
+	New          any    `json:"new"`
+	PreviousHash string `json:"previous_hash"`
+	Hash         string `json:"hash"`
+	Timestamp    int64  `json:"timestamp"`
+}
+
+// Package is one installed package. Manager is dpkg, rpm or apk.
+type Package struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Arch        string `json:"arch"`
+	Manager     string `json:"manager"`
+	InstalledAt int64  `json:"installed_at,omitempty"` // unknown for apk
+}
+
+// PackageListEnvelope is the package inventory upload. A "full" envelope
+// carries every package; a "delta" envelope carries Added and Removed
+// against the list acknowledged as BaseSeq. An upgrade shows up as the old
+// version removed and the new one added. Hash covers the complete list so
+// the backend can detect a diverged base.
+type PackageListEnvelope struct {
+	UserID    string     `json:"user_id"`
+	MachineID string     `json:"machineId"`
+	Hostname  string     `json:"hostname"`
+	Mode      string     `json:"mode"` // full, delta
+	Seq       uint64     `json:"seq"`
+	BaseSeq   uint64     `json:"base_seq,omitempty"`
+	Hash      string     `json:"hash"`
+	Count     int        `json:"count"`
+	Timestamp int64      `json:"timestamp"`
+	Packages  []*Package `json:"packages,omitempty"`
+	Added     []*Package `json:"added,omitempty"`
+	Removed   []*Package `json:"removed,omitempty"`
+}
@@ -0,0 +1,71 @@
+package models
+
+// LogSample is one log line that matched a rule.
+type LogSample struct {
+	File string `json:"file"`
+	Line string `json:"line"`
+	Time int64  `json:"time"` // when the agent read it
+}
+
+// LogRuleStats counts the lines that matched a rule during one interval,
+// with a bounded sample of them.
+type LogRuleStats struct {
+	Rule     string      `json:"rule"`
+	Severity string      `json:"severity"`
+	Count    int         `json:"count"`
+	Samples  []LogSample `json:"samples,omitempty"`
+}
+
+// JournalEntry is a forwarded systemd journal entry. Time is the entry's
+// realtime timestamp in microseconds.
+type JournalEntry struct {
+	Time       int64  `json:"time"`
+	Priority   int    `json:"priority"`
+	Unit       string `json:"unit,omitempty"`
+	Identifier string `json:"identifier,omitempty"`
+	PID        string `json:"pid,omitempty"`
+	Message    string `json:"message"`
+}
+
+// JournalBatch carries the journal entries forwarded during one report
+// interval. Dropped counts the entries beyond the per-report limit.
+type JournalBatch struct {
+	UserID    string         `json:"user_id"`
+	MachineID string         `json:"machineId"`
+	Hostname  string         `json:"hostname"`
+	Entries   []JournalEntry `json:"entries"`
+	Dropped   int            `json:"dropped,omitempty"`
+}
+
+// LogRecord is a forwarded log record: one line, or several joined lines
+// for multiline sources. Time is the time parsed from the record in unix
+// milliseconds, 0 when it had none.
+type LogRecord struct {
+	Source  string            `json:"source"`
+	File    string            `json:"file"`
+	Time    int64             `json:"time,omitempty"`
+	Level   string            `json:"level,omitempty"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	ReadAt  int64             `json:"read_at"`
+}
+
+// LogForwardBatch is one upload of forwarded log records.
+type LogForwardBatch struct {
+	UserID    string      `json:"user_id"`
+	MachineID string      `json:"machineId"`
+	Hostname  string      `json:"hostname"`
+	Records   []LogRecord `json:"records"`
+}
+
+// LogReport summarises the lines read from the tailed logs between
+// IntervalStart and IntervalEnd. Lines counts the lines read per source.
+type LogReport struct {
+	UserID        string         `json:"user_id"`
+	MachineID     string         `json:"machineId"`
+	Hostname      string         `json:"hostname"`
+	IntervalStart int64          `json:"interval_start"`
+	IntervalEnd   int64          `json:"interval_end"`
+	Lines         map[string]int `json:"lines"`
+	Rules         []LogRuleStats `json:"rules"`
+}
@@ -28,11 +28,12 @@ type Metrics struct {
 	SwapMemUsagePercent  float64         `json:"swap_mem_usage_percent"`
 	DiskUsed             uint64          `json:"disk_used"`
 	DiskTotal            uint64          `json:"disk_total"`
+	DiskUsedPercent      float64         `json:"disk_used_percent"`
 	Uptime               uint64          `json:"uptime_seconds"`
 	MetricGetTime        string          `json:"metric_get_time"`
 	Status               string          `json:"status"` // up, down, trouble, critical
 	Timestamp            int64           `json:"timestamp"`
-	Os                   string          `json:"os`
+	Os                   string          `json:"Os"`
 	Interrupts           uint64          `json:"interrupts"`
 	ContextSwitches      uint64          `json:"context_switches"`
 	PagesReads           uint            `json:"pages_reads"`
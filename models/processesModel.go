@@ -6,6 +6,7 @@ type ProcessInfo struct {
 	Hostname      string  `json:"hostname"`
 	Path          string  `json:"path"`
 	PID           int32   `json:"pid"`
+	ParentPID     int32   `json:"ppid"`
 	Name          string  `json:"name"`
 	Username      string  `json:"user_name"`
 	CPUPercent    float64 `json:"cpu_percent"`
@@ -24,3 +25,55 @@ type Process struct {
 	Usage     float64 `json:"usage"`
 	Command   string  `json:"command"`
 }
+
+// ProcessGroup is the rolled-up resource usage of every process that belongs
+// to one application (all chrome workers, all php-fpm children, ...).
+type ProcessGroup struct {
+	UserID        string  `json:"user_id"`
+	MachineID     string  `json:"machineId"`
+	Hostname      string  `json:"hostname"`
+	Group         string  `json:"group"`
+	GroupBy       string  `json:"group_by"` // exe, unit, rule
+	ProcessCount  int     `json:"process_count"`
+	RootPIDs      []int32 `json:"root_pids"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float32 `json:"memory_percent"`
+	MemoryRSS     uint64  `json:"memory_rss"`
+	ReadBytes     uint64  `json:"read_bytes"`
+	WriteBytes    uint64  `json:"write_bytes"`
+	FDCount       uint32  `json:"fd_count"`
+	ThreadCount   int32   `json:"thread_count"`
+	Timestamp     int64   `json:"timestamp"`
+}
+
+// ProcessEntry is one process inside a ProcessListEnvelope. Host identity is
+// carried once by the envelope instead of on every process.
+type ProcessEntry struct {
+	PID           int32   `json:"pid"`
+	ParentPID     int32   `json:"ppid"`
+	Path          string  `json:"path,omitempty"`
+	Name          string  `json:"name"`
+	Username      string  `json:"user_name"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float32 `json:"memory_percent"`
+	ThreadCount   int32   `json:"thread_count"`
+	HandleCount   uint32  `json:"handle_count"`
+	Priority      any     `json:"priority"`
+}
+
+// ProcessListEnvelope is the process list upload. A "full" envelope carries
+// every process in Processes; a "delta" envelope carries Added, Changed and
+// Removed against the snapshot acknowledged as BaseSeq.
+type ProcessListEnvelope struct {
+	UserID    string          `json:"user_id"`
+	MachineID string          `json:"machineId"`
+	Hostname  string          `json:"hostname"`
+	Mode      string          `json:"mode"` // full, delta
+	Seq       uint64          `json:"seq"`
+	BaseSeq   uint64          `json:"base_seq,omitempty"`
+	Timestamp int64           `json:"timestamp"`
+	Processes []*ProcessEntry `json:"processes,omitempty"`
+	Added     []*ProcessEntry `json:"added,omitempty"`
+	Changed   []*ProcessEntry `json:"changed,omitempty"`
+	Removed   []int32         `json:"removed,omitempty"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// RemoteConfigStatus reports whether a desired-config version was applied.
+type RemoteConfigStatus struct {
+	MachineID string `json:"machineId"`
+	Version   int64  `json:"version"`
+	Status    string `json:"status"` // applied, rejected
+	Error     string `json:"error,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// CommandResult reports the outcome of a command sent by the backend.
+type CommandResult struct {
+	MachineID  string `json:"machineId"`
+	CommandID  string `json:"command_id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"` // succeeded, failed, rejected
+	Error      string `json:"error,omitempty"`
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at"`
+}
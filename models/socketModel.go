@@ -0,0 +1,34 @@
+package models
+
+// ListeningSocket is a TCP socket in LISTEN state or a bound, unconnected
+// UDP socket, with the process that owns it when it could be resolved.
+type ListeningSocket struct {
+	Protocol string `json:"protocol"` // tcp, tcp6, udp, udp6
+	Address  string `json:"address"`
+	Port     uint16 `json:"port"`
+	PID      int32  `json:"pid,omitempty"`
+	Process  string `json:"process,omitempty"`
+	User     string `json:"user,omitempty"`
+}
+
+// SocketInventory lists the host's listeners and counts TCP connections
+// per state (ESTABLISHED, TIME_WAIT, CLOSE_WAIT, ...).
+type SocketInventory struct {
+	UserID      string            `json:"user_id"`
+	MachineID   string            `json:"machineId"`
+	Hostname    string            `json:"hostname"`
+	Listeners   []ListeningSocket `json:"listeners"`
+	StateCounts map[string]int    `json:"state_counts"`
+	Timestamp   int64             `json:"timestamp"`
+}
+
+// ListenerEvent reports a listener that appeared ("opened") or went away
+// ("closed") since the previous collection.
+type ListenerEvent struct {
+	UserID    string          `json:"user_id"`
+	MachineID string          `json:"machineId"`
+	Hostname  string          `json:"hostname"`
+	Event     string          `json:"event"`
+	Socket    ListeningSocket `json:"socket"`
+	Timestamp int64           `json:"timestamp"`
+}
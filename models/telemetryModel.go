@@ -0,0 +1,41 @@
+package models
+
+// CollectorStats describes the recent runs of one collector.
+type CollectorStats struct {
+	Runs           int64   `json:"runs"`
+	Errors         int64   `json:"errors"`
+	LastDurationMs float64 `json:"last_duration_ms"`
+	AvgDurationMs  float64 `json:"avg_duration_ms"`
+	MaxDurationMs  float64 `json:"max_duration_ms"`
+	LastRun        int64   `json:"last_run"`
+	LastError      string  `json:"last_error,omitempty"`
+}
+
+// EndpointStats counts the requests to one backend endpoint.
+type EndpointStats struct {
+	Success     int64  `json:"success"`
+	Failure     int64  `json:"failure"`
+	LastStatus  string `json:"last_status,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	LastSuccess int64  `json:"last_success,omitempty"`
+}
+
+// AgentHeartbeat is the agent's report about itself.
+type AgentHeartbeat struct {
+	UserID             string                    `json:"user_id"`
+	MachineID          string                    `json:"machineId"`
+	Hostname           string                    `json:"hostname"`
+	Version            string                    `json:"version"`
+	StartedAt          int64                     `json:"started_at"`
+	UptimeSeconds      int64                     `json:"uptime_seconds"`
+	Goroutines         int                       `json:"goroutines"`
+	RSSBytes           uint64                    `json:"rss_bytes"`
+	CPUPercent         float64                   `json:"cpu_percent"`
+	QueueDepth         int                       `json:"queue_depth"`
+	LastSuccessfulSend int64                     `json:"last_successful_send"`
+	LastProxyError     string                    `json:"last_proxy_error,omitempty"`
+	Collectors         map[string]CollectorStats `json:"collectors"`
+	Endpoints          map[string]EndpointStats  `json:"endpoints"`
+	LogBytesDropped    int64                     `json:"log_bytes_dropped,omitempty"`
+	Timestamp          int64                     `json:"timestamp"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// UpdateStatus reports a self-update step to the backend.
+type UpdateStatus struct {
+	MachineID   string `json:"machineId"`
+	FromVersion string `json:"from_version"`
+	ToVersion   string `json:"to_version"`
+	Status      string `json:"status"` // installed, committed, rolled_back, failed
+	Error       string `json:"error,omitempty"`
+	Timestamp   int64  `json:"timestamp"`
+}
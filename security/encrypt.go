@@ -9,12 +9,14 @@ import (
 	"io"
 )
 
-// Key must be 16, 24, or 32 bytes long
-var encryptionKey = []byte("12345678901234567890123456789012")
+// Encrypt text with AES-GCM using the key from kp
+func Encrypt(kp KeyProvider, text string) (string, error) {
+	key, err := kp.Key()
+	if err != nil {
+		return "", err
+	}
 
-// Encrypt text with AES
-func Encrypt(text string) (string, error) {
-	block, err := aes.NewCipher(encryptionKey)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
@@ -33,14 +35,19 @@ func Encrypt(text string) (string, error) {
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-// Decrypt text with AES
-func Decrypt(encText string) (string, error) {
+// Decrypt text with AES-GCM using the key from kp
+func Decrypt(kp KeyProvider, encText string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(encText)
 	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(encryptionKey)
+	key, err := kp.Key()
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,127 @@
+package security
+
+import (
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// KeySize is the AES-256 key length used for everything the agent encrypts.
+const KeySize = 32
+
+// KeyProvider supplies the AES key used by Encrypt and Decrypt.
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// ErrNoKey is returned by FileKeyProvider when no key has been generated yet.
+var ErrNoKey = errors.New("encryption key not found")
+
+// FileKeyProvider reads a per-install random key stored base64-encoded in
+// Path with 0600 permissions.
+type FileKeyProvider struct {
+	Path string
+
+	mu  sync.Mutex
+	key []byte
+}
+
+// NewFileKeyProvider returns a provider for the key file at path.
+func NewFileKeyProvider(path string) *FileKeyProvider {
+	return &FileKeyProvider{Path: path}
+}
+
+// Key loads the key file on first use and caches it.
+func (f *FileKeyProvider) Key() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.key != nil {
+		return f.key, nil
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", f.Path, err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key file %s: want %d bytes, got %d", f.Path, KeySize, len(key))
+	}
+	f.key = key
+	return key, nil
+}
+
+// Generate creates a new random key and writes it to Path, replacing any
+// existing key.
+func (f *FileKeyProvider) Generate() error {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := WriteKeyFile(f.Path, key); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.key = key
+	f.mu.Unlock()
+	return nil
+}
+
+// WriteKeyFile atomically stores key at path with 0600 permissions.
+func WriteKeyFile(path string, key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	encoded := base64.StdEncoding.EncodeToString(key) + "\n"
+	if err := os.WriteFile(tmp, []byte(encoded), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// DerivedKeyProvider derives the key from the machine ID and a
+// server-issued secret with HKDF-SHA256, so nothing key-like is stored on
+// disk besides the secret.
+type DerivedKeyProvider struct {
+	MachineID string
+	Secret    []byte
+}
+
+// Key derives the AES key.
+func (d *DerivedKeyProvider) Key() ([]byte, error) {
+	if d.MachineID == "" || len(d.Secret) == 0 {
+		return nil, errors.New("derived key needs a machine ID and a server secret")
+	}
+	return hkdf.Key(sha256.New, d.Secret, []byte(d.MachineID), "idevopzagent config key", KeySize)
+}
+
+// StaticKeyProvider returns a fixed key. It exists to read values encrypted
+// by older agents and during key rotation.
+type StaticKeyProvider []byte
+
+// Key returns the fixed key.
+func (s StaticKeyProvider) Key() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// LegacyKey is the key compiled into agents before per-install keys. It is
+// only used to migrate existing config files.
+var LegacyKey = StaticKeyProvider("12345678901234567890123456789012")
@@ -0,0 +1,36 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BackendPublicKey is the base64 ed25519 key the backend signs remote
+// config documents with. It is set at build time with
+// -ldflags "-X iDevopzAgent/security.BackendPublicKey=..." and can be
+// overridden in settings.json.
+var BackendPublicKey string
+
+// ReleasePublicKey is the base64 ed25519 key agent releases are signed
+// with, set at build time like BackendPublicKey.
+var ReleasePublicKey string
+
+// VerifyEd25519 checks the base64 signature sig of data against the base64
+// ed25519 publicKey.
+func VerifyEd25519(publicKey string, data []byte, sig string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid ed25519 public key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sig))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, signature) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package sender
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// lastAck is when the backend last accepted collected data, in unix
+// nanoseconds.
+var lastAck atomic.Int64
+
+// LastAck returns when the backend last acknowledged collected data: a
+// batch, a heartbeat or a metrics sample. Registration and other calls do
+// not count, so it shows the agent is actually delivering.
+func LastAck() time.Time {
+	n := lastAck.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+func recordAck() {
+	lastAck.Store(time.Now().UnixNano())
+}
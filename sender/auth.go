@@ -0,0 +1,209 @@
+package sender
+
+import (
+	"encoding/json"
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/httpclient"
+	"iDevopzAgent/security"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	startupPath = "/api/vm/moniters/create-update"
+	refreshPath = "/api/vm/moniters/token/refresh"
+
+	// refresh this long before the token expires
+	refreshMargin = time.Minute
+)
+
+// registrationResponse is returned by the startup and refresh calls. Older
+// backends return no token; the agent then keeps sending unauthenticated.
+type registrationResponse struct {
+	AgentToken   string `json:"agent_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds
+	ServerSecret string `json:"server_secret"`
+}
+
+// agentAuth holds the agent token and signs every backend request with it.
+type agentAuth struct {
+	mu             sync.Mutex
+	token          string
+	refreshToken   string
+	expiry         time.Time
+	machineID      string
+	startupPayload map[string]string
+}
+
+var auth = &agentAuth{}
+
+// Sign implements httpclient.Authenticator.
+func (a *agentAuth) Sign(req *http.Request, body []byte) (bool, error) {
+	if !httpclient.IsBackend(req.URL) {
+		return false, nil
+	}
+	if strings.HasSuffix(req.URL.Path, startupPath) || strings.HasSuffix(req.URL.Path, refreshPath) {
+		return false, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == "" {
+		// unauthenticated until registration; a 401 makes Renew register
+		return true, nil
+	}
+	if !a.expiry.IsZero() && time.Until(a.expiry) < refreshMargin {
+		if err := a.refreshLocked(); err != nil {
+			log.Warn("token refresh failed, re-registering", "err", err)
+			if err := a.registerLocked(); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	req.Header.Set("X-Agent-Id", a.machineID)
+	req.Header.Set("X-Agent-Timestamp", ts)
+	// the signature is keyed with the server secret, so a leaked bearer
+	// token alone cannot produce it
+	if secret := configs.ServerSecret(); secret != "" {
+		req.Header.Set("X-Agent-Signature", security.SignRequest(secret, ts, req.Method, req.URL.RequestURI(), body))
+	}
+	return true, nil
+}
+
+// Renew implements httpclient.Authenticator: the backend rejected the token,
+// so refresh it or register again.
+func (a *agentAuth) Renew(failed *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// another request already renewed the token this one was signed with
+	if a.token != "" && failed.Header.Get("Authorization") != "Bearer "+a.token {
+		return nil
+	}
+	if a.refreshToken != "" {
+		if err := a.refreshLocked(); err == nil {
+			return nil
+		}
+	}
+	return a.registerLocked()
+}
+
+func (a *agentAuth) registerLocked() error {
+	if a.startupPayload == nil {
+		return fmt.Errorf("agent has not registered yet")
+	}
+
+	url := configs.LoadConfig().APIEndpoint + startupPath
+	resp, err := httpclient.SendPOST(url, a.startupPayload)
+	if err != nil {
+		return err
+	}
+	return a.storeLocked(resp)
+}
+
+func (a *agentAuth) refreshLocked() error {
+	return a.refreshWithLocked(false)
+}
+
+// refreshWithLocked refreshes the token; with rotateSecret the backend is
+// asked to issue a new server secret along with it.
+func (a *agentAuth) refreshWithLocked(rotateSecret bool) error {
+	if a.refreshToken == "" {
+		return fmt.Errorf("no refresh token")
+	}
+
+	url := configs.LoadConfig().APIEndpoint + refreshPath
+	payload := map[string]string{
+		"machineId":     a.machineID,
+		"refresh_token": a.refreshToken,
+	}
+	if rotateSecret {
+		payload["rotate_secret"] = "true"
+	}
+	resp, err := httpclient.SendPOST(url, payload)
+	if err != nil {
+		return err
+	}
+	return a.storeLocked(resp)
+}
+
+// RotateServerSecret asks the backend for a new server secret, which
+// re-encrypts config.json when the key is derived from it.
+func RotateServerSecret(machineID string) error {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
+	auth.machineID = machineID
+	auth.loadStored()
+	before := configs.ServerSecret()
+	if err := auth.refreshWithLocked(true); err != nil {
+		return err
+	}
+	if configs.ServerSecret() == before {
+		return fmt.Errorf("backend did not issue a new server secret")
+	}
+	return nil
+}
+
+// storeLocked reads a registration/refresh response and persists the new
+// credentials encrypted in config.json.
+func (a *agentAuth) storeLocked(resp *http.Response) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s: %s", resp.Status, string(body))
+	}
+
+	var reg registrationResponse
+	if err := json.Unmarshal(body, &reg); err != nil || reg.AgentToken == "" {
+		// backend without token support
+		return nil
+	}
+
+	a.token = reg.AgentToken
+	if reg.RefreshToken != "" {
+		a.refreshToken = reg.RefreshToken
+	}
+	a.expiry = time.Time{}
+	if reg.ExpiresIn > 0 {
+		a.expiry = time.Now().Add(time.Duration(reg.ExpiresIn) * time.Second)
+	}
+
+	// the secret may change the config key, so it is stored first
+	if reg.ServerSecret != "" {
+		if err := configs.SaveServerSecret(reg.ServerSecret); err != nil {
+			log.Warn("failed to store server secret", "err", err)
+		}
+	}
+	var expiry int64
+	if !a.expiry.IsZero() {
+		expiry = a.expiry.Unix()
+	}
+	if err := configs.SaveAgentToken(a.token, a.refreshToken, expiry); err != nil {
+		log.Warn("failed to store agent token", "err", err)
+	}
+	return nil
+}
+
+// loadStored restores credentials saved by a previous run.
+func (a *agentAuth) loadStored() {
+	token, refresh, expiry, err := configs.LoadAgentToken()
+	if err != nil || token == "" {
+		return
+	}
+	a.token = token
+	a.refreshToken = refresh
+	if expiry > 0 {
+		a.expiry = time.Unix(expiry, 0)
+	}
+}
@@ -0,0 +1,305 @@
+package sender
+
+import (
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/httpclient"
+	"iDevopzAgent/models"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ingestPath = "/api/go/ingest"
+
+	// legacyRecheck is how long a backend without the ingest endpoint is
+	// sent per-type requests before the endpoint is tried again.
+	legacyRecheck = time.Hour
+)
+
+// queuedRecord is a record waiting for the next batch. Path is the
+// per-type endpoint used when the backend has no ingest endpoint yet.
+type queuedRecord struct {
+	*models.IngestRecord
+	path     string
+	attempts int
+}
+
+// batcher accumulates collector payloads of every type and posts them as
+// one compressed envelope to the ingest endpoint.
+type batcher struct {
+	mu    sync.Mutex
+	queue []*queuedRecord
+	bytes int
+	limit int // records per batch, lowered when the backend answers 413
+	seq   uint64
+	idPfx string
+
+	legacyUntil time.Time // per-type endpoints until then, after a 404
+
+	start    sync.Once
+	flushNow chan struct{}
+}
+
+var batch = &batcher{flushNow: make(chan struct{}, 1)}
+
+// add queues payload for the next batch. It returns false when batching is
+// disabled, in which case the caller posts the payload itself.
+func (b *batcher) add(kind, path string, payload interface{}) bool {
+	cfg := configs.LoadSettings().Batch
+	if !cfg.Enabled {
+		return false
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Error("encode record failed", "type", kind, "err", err)
+		return true
+	}
+
+	b.start.Do(func() {
+		b.idPfx = strconv.FormatInt(time.Now().UnixNano(), 36)
+		b.limit = cfg.MaxRecords
+		go b.run(cfg)
+	})
+
+	b.mu.Lock()
+	b.seq++
+	b.queue = append(b.queue, &queuedRecord{
+		IngestRecord: &models.IngestRecord{
+			ID:          b.idPfx + "-" + strconv.FormatUint(b.seq, 10),
+			Type:        kind,
+			CollectedAt: time.Now().Unix(),
+			Data:        data,
+		},
+		path: path,
+	})
+	b.bytes += len(data)
+	b.trimLocked(cfg.MaxQueue)
+	full := b.fullLocked(cfg)
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.flushNow <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// run flushes on every tick and whenever a batch fills up.
+func (b *batcher) run(cfg configs.BatchSettings) {
+	ticker := time.NewTicker(time.Duration(cfg.FlushSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.flushNow:
+		}
+		for b.flush(cfg) {
+		}
+	}
+}
+
+func (b *batcher) fullLocked(cfg configs.BatchSettings) bool {
+	return len(b.queue) >= b.limit || b.bytes >= cfg.MaxBytes
+}
+
+// trimLocked drops the oldest records beyond max so a long backend outage
+// cannot grow the queue without bound.
+func (b *batcher) trimLocked(max int) {
+	if max <= 0 || len(b.queue) <= max {
+		return
+	}
+	drop := len(b.queue) - max
+	for _, rec := range b.queue[:drop] {
+		b.bytes -= len(rec.Data)
+	}
+	b.queue = append([]*queuedRecord(nil), b.queue[drop:]...)
+	log.Warn("batch queue full, dropped oldest records", "dropped", drop)
+}
+
+// takeLocked removes the next batch from the queue: up to limit records
+// and roughly maxBytes of data, but always at least one record.
+func (b *batcher) takeLocked(maxBytes int) []*queuedRecord {
+	n, size := 0, 0
+	for n < len(b.queue) && n < b.limit {
+		size += len(b.queue[n].Data)
+		if n > 0 && size > maxBytes {
+			break
+		}
+		n++
+	}
+	records := b.queue[:n:n]
+	b.queue = b.queue[n:]
+	for _, rec := range records {
+		b.bytes -= len(rec.Data)
+	}
+	return records
+}
+
+// requeue puts records back at the front of the queue, in their original
+// order, dropping those that used up their attempts.
+func (b *batcher) requeue(records []*queuedRecord, cfg configs.BatchSettings, failed bool) {
+	keep := records[:0:0]
+	for _, rec := range records {
+		if failed {
+			rec.attempts++
+		}
+		if cfg.MaxAttempts > 0 && rec.attempts >= cfg.MaxAttempts {
+			log.Warn("dropping record after max attempts", "type", rec.Type, "id", rec.ID, "attempts", rec.attempts)
+			continue
+		}
+		keep = append(keep, rec)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, rec := range keep {
+		b.bytes += len(rec.Data)
+	}
+	b.queue = append(keep, b.queue...)
+	b.trimLocked(cfg.MaxQueue)
+}
+
+// flush sends one batch. It returns true when another full batch is
+// waiting and the backend is accepting data.
+func (b *batcher) flush(cfg configs.BatchSettings) bool {
+	b.mu.Lock()
+	records := b.takeLocked(cfg.MaxBytes)
+	b.mu.Unlock()
+	if len(records) == 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	legacy := time.Now().Before(b.legacyUntil)
+	b.mu.Unlock()
+	if legacy {
+		return b.sendLegacy(records, cfg)
+	}
+
+	auth.mu.Lock()
+	machineID := auth.machineID
+	auth.mu.Unlock()
+
+	envelope := &models.IngestBatch{
+		BatchID:   records[0].ID,
+		MachineID: machineID,
+		SentAt:    time.Now().Unix(),
+		Records:   make([]*models.IngestRecord, len(records)),
+	}
+	for i, rec := range records {
+		envelope.Records[i] = rec.IngestRecord
+	}
+
+	url := configs.LoadConfig().APIEndpoint + ingestPath
+	var resp *http.Response
+	var err error
+	if cfg.Compression == "none" {
+		resp, err = httpclient.SendPOST(url, envelope)
+	} else {
+		resp, err = httpclient.SendPOSTGzip(url, envelope)
+	}
+	if err != nil {
+		log.Error("send batch failed", "err", err)
+		b.requeue(records, cfg, true)
+		return false
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// backend without the ingest endpoint
+		log.Info("backend has no ingest endpoint, sending records one by one")
+		b.mu.Lock()
+		b.legacyUntil = time.Now().Add(legacyRecheck)
+		b.mu.Unlock()
+		return b.sendLegacy(records, cfg)
+	case resp.StatusCode == http.StatusRequestEntityTooLarge:
+		if len(records) == 1 {
+			log.Warn("dropping record too large for the backend", "type", records[0].Type, "id", records[0].ID)
+			return true
+		}
+		b.mu.Lock()
+		b.limit = max(1, len(records)/2)
+		b.mu.Unlock()
+		b.requeue(records, cfg, false)
+		return true
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		log.Warn("send batch rejected", "status", resp.Status, "response", string(body))
+		b.requeue(records, cfg, true)
+		return false
+	}
+
+	retry, rejected := ackRecords(records, body)
+	if len(retry)+rejected < len(records) {
+		recordAck()
+	}
+	b.requeue(retry, cfg, true)
+	log.Debug("batch sent", "records", len(records), "rejected", rejected, "retry", len(retry), "status", resp.Status)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = min(cfg.MaxRecords, b.limit*2)
+	return len(retry) < len(records) && b.fullLocked(cfg)
+}
+
+// ackRecords matches the per-record results against the batch and returns
+// the records to retry. An empty result list acknowledges the whole batch.
+func ackRecords(records []*queuedRecord, body []byte) (retry []*queuedRecord, rejected int) {
+	var ack models.IngestResponse
+	if err := json.Unmarshal(body, &ack); err != nil || len(ack.Results) == 0 {
+		return nil, 0
+	}
+
+	results := make(map[string]models.IngestResult, len(ack.Results))
+	for _, r := range ack.Results {
+		results[r.ID] = r
+	}
+	for _, rec := range records {
+		r, ok := results[rec.ID]
+		switch {
+		case !ok || r.Status == "retry":
+			retry = append(retry, rec)
+		case r.Status == "rejected":
+			rejected++
+			log.Warn("record rejected by the backend", "type", rec.Type, "id", rec.ID, "error", r.Error)
+		}
+	}
+	return retry, rejected
+}
+
+// sendLegacy posts every record to its own endpoint, as agents did before
+// batching.
+func (b *batcher) sendLegacy(records []*queuedRecord, cfg configs.BatchSettings) bool {
+	base := configs.LoadConfig().APIEndpoint
+	for i, rec := range records {
+		resp, err := httpclient.SendPOSTBody(base+rec.path, "application/json", rec.Data, nil)
+		if err != nil {
+			log.Error("send record failed", "type", rec.Type, "err", err)
+			b.requeue(records[i:], cfg, true)
+			return false
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			log.Warn("send record rejected", "type", rec.Type, "status", resp.Status)
+			continue
+		}
+		recordAck()
+	}
+	return false
+}
+
+// QueueDepth returns the number of records waiting to be sent.
+func QueueDepth() int {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	return len(batch.queue)
+}
@@ -0,0 +1,153 @@
+package sender
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// packageDeltaState tracks the package list the backend acknowledged. It
+// is persisted to packages_state.json so a restarted agent keeps sending
+// deltas instead of the full list.
+type packageDeltaState struct {
+	mu     sync.Mutex
+	path   string
+	loaded bool
+
+	Seq      uint64                     `json:"seq"`
+	AckedSeq uint64                     `json:"acked_seq"`
+	Acked    map[string]*models.Package `json:"acked"`
+	Resync   bool                       `json:"resync"`
+}
+
+var packageDelta = &packageDeltaState{
+	path: filepath.Join(configs.DataDir(), "packages_state.json"),
+}
+
+// packageKey identifies one installed package version. Some hosts keep
+// several versions of a package (e.g. kernels) installed side by side.
+func packageKey(p *models.Package) string {
+	return p.Manager + "\x00" + p.Name + "\x00" + p.Arch + "\x00" + p.Version
+}
+
+// hashPackages hashes the sorted package keys.
+func hashPackages(pkgs []*models.Package) string {
+	h := sha256.New()
+	for _, p := range pkgs {
+		h.Write([]byte(packageKey(p)))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *packageDeltaState) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		log.Warn("ignoring corrupt package state", "err", err)
+		s.Seq, s.AckedSeq, s.Acked, s.Resync = 0, 0, nil, false
+	}
+}
+
+func (s *packageDeltaState) save() {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(s.path), 0700)
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		log.Error("failed to save package state", "err", err)
+	}
+}
+
+// build returns the envelope for pkgs, or nil when nothing changed since
+// the acknowledged list, and the snapshot that becomes the new base once
+// the backend acknowledges it. pkgs must be sorted.
+func (s *packageDeltaState) build(userID, machineID, hostname string, pkgs []*models.Package) (*models.PackageListEnvelope, map[string]*models.Package) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+
+	next := make(map[string]*models.Package, len(pkgs))
+	for _, p := range pkgs {
+		next[packageKey(p)] = p
+	}
+
+	env := &models.PackageListEnvelope{
+		UserID:    userID,
+		MachineID: machineID,
+		Hostname:  hostname,
+		Hash:      hashPackages(pkgs),
+		Count:     len(pkgs),
+		Timestamp: time.Now().Unix(),
+	}
+
+	if s.Resync || s.Acked == nil {
+		env.Mode = "full"
+		env.Packages = pkgs
+	} else {
+		env.Mode = "delta"
+		env.BaseSeq = s.AckedSeq
+		for _, p := range pkgs {
+			if _, ok := s.Acked[packageKey(p)]; !ok {
+				env.Added = append(env.Added, p)
+			}
+		}
+		for key, p := range s.Acked {
+			if _, ok := next[key]; !ok {
+				env.Removed = append(env.Removed, p)
+			}
+		}
+		sort.Slice(env.Removed, func(i, j int) bool {
+			return packageKey(env.Removed[i]) < packageKey(env.Removed[j])
+		})
+		if len(env.Added) == 0 && len(env.Removed) == 0 {
+			return nil, nil
+		}
+	}
+
+	s.Seq++
+	env.Seq = s.Seq
+	return env, next
+}
+
+// ack makes snapshot the new delta base after a successful upload.
+func (s *packageDeltaState) ack(env *models.PackageListEnvelope, snapshot map[string]*models.Package, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if needsResync(body, env.Seq) {
+		s.Resync = true
+		s.save()
+		return
+	}
+
+	s.Acked = snapshot
+	s.AckedSeq = env.Seq
+	s.Resync = false
+	s.save()
+}
+
+// fail forces a full upload when the backend rejected a delta outright.
+func (s *packageDeltaState) fail(statusCode int) {
+	if statusCode == 409 || statusCode == 410 {
+		s.mu.Lock()
+		s.Resync = true
+		s.save()
+		s.mu.Unlock()
+	}
+}
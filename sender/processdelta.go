@@ -0,0 +1,168 @@
+package sender
+
+import (
+	"encoding/json"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/models"
+	"math"
+	"sync"
+	"time"
+)
+
+// processDeltaState tracks the last process snapshot the backend acknowledged
+// so the next upload only carries what changed since then.
+type processDeltaState struct {
+	mu       sync.Mutex
+	seq      uint64
+	ackedSeq uint64
+	acked    map[int32]*models.ProcessEntry
+	lastFull time.Time
+	resync   bool
+}
+
+var processDelta = &processDeltaState{resync: true}
+
+// processSyncResponse is the optional body returned by the process sync
+// endpoint. The backend sets Resync when it lost the base snapshot and
+// AckSeq to the sequence it applied.
+type processSyncResponse struct {
+	AckSeq uint64 `json:"ack_seq"`
+	Resync bool   `json:"resync"`
+}
+
+// needsResync reports whether the answer to the upload of seq asks for a
+// full resync: the backend lost the base snapshot, or it applied another
+// sequence than the one sent, so its base is not the snapshot just built.
+// A missing body or ack_seq leaves the upload acknowledged.
+func needsResync(body []byte, seq uint64) bool {
+	var resp processSyncResponse
+	if len(body) == 0 || json.Unmarshal(body, &resp) != nil {
+		return false
+	}
+	if resp.AckSeq != 0 && resp.AckSeq != seq {
+		log.Warn("backend acknowledged another sequence, resyncing", "sent", seq, "acked", resp.AckSeq)
+		return true
+	}
+	return resp.Resync
+}
+
+func toProcessEntry(p *models.ProcessInfo) *models.ProcessEntry {
+	return &models.ProcessEntry{
+		PID:           p.PID,
+		ParentPID:     p.ParentPID,
+		Path:          p.Path,
+		Name:          p.Name,
+		Username:      p.Username,
+		CPUPercent:    p.CPUPercent,
+		MemoryPercent: p.MemoryPercent,
+		ThreadCount:   p.ThreadCount,
+		HandleCount:   p.HandleCount,
+		Priority:      p.Priority,
+	}
+}
+
+// significantChange reports whether cur differs enough from the acknowledged
+// entry to be worth sending.
+func significantChange(old, cur *models.ProcessEntry, settings *configs.Settings) bool {
+	if old.Name != cur.Name || old.ParentPID != cur.ParentPID || old.Username != cur.Username {
+		return true // pid reused by another process
+	}
+	if countChanged(float64(old.ThreadCount), float64(cur.ThreadCount), settings.ProcessCountDelta) ||
+		countChanged(float64(old.HandleCount), float64(cur.HandleCount), settings.ProcessCountDelta) {
+		return true
+	}
+	if math.Abs(cur.CPUPercent-old.CPUPercent) >= settings.ProcessCPUDelta {
+		return true
+	}
+	return math.Abs(float64(cur.MemoryPercent-old.MemoryPercent)) >= settings.ProcessMemoryDelta
+}
+
+// countChanged reports whether a thread or handle count moved by at least
+// percent of its acknowledged value, and by at least one.
+func countChanged(old, cur, percent float64) bool {
+	return math.Abs(cur-old) >= math.Max(1, old*percent/100)
+}
+
+// build returns the envelope for procs and the snapshot that becomes the new
+// base once the backend acknowledges it.
+func (s *processDeltaState) build(procs []*models.ProcessInfo) (*models.ProcessListEnvelope, map[int32]*models.ProcessEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings := configs.LoadSettings()
+	s.seq++
+	env := &models.ProcessListEnvelope{
+		Seq:       s.seq,
+		Timestamp: time.Now().Unix(),
+	}
+	if len(procs) > 0 {
+		env.UserID = procs[0].UserID
+		env.MachineID = procs[0].MachineID
+		env.Hostname = procs[0].Hostname
+	}
+
+	resyncEvery := time.Duration(settings.ProcessResyncMinutes) * time.Minute
+	full := s.resync || s.acked == nil || time.Since(s.lastFull) >= resyncEvery
+
+	next := make(map[int32]*models.ProcessEntry, len(procs))
+	if full {
+		env.Mode = "full"
+		for _, p := range procs {
+			entry := toProcessEntry(p)
+			next[p.PID] = entry
+			env.Processes = append(env.Processes, entry)
+		}
+		return env, next
+	}
+
+	env.Mode = "delta"
+	env.BaseSeq = s.ackedSeq
+	for _, p := range procs {
+		entry := toProcessEntry(p)
+		old, ok := s.acked[p.PID]
+		switch {
+		case !ok:
+			env.Added = append(env.Added, entry)
+			next[p.PID] = entry
+		case significantChange(old, entry, settings):
+			env.Changed = append(env.Changed, entry)
+			next[p.PID] = entry
+		default:
+			// keep the acknowledged values so small drifts accumulate
+			next[p.PID] = old
+		}
+	}
+	for pid := range s.acked {
+		if _, ok := next[pid]; !ok {
+			env.Removed = append(env.Removed, pid)
+		}
+	}
+	return env, next
+}
+
+// ack makes snapshot the new delta base after a successful upload.
+func (s *processDeltaState) ack(env *models.ProcessListEnvelope, snapshot map[int32]*models.ProcessEntry, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if needsResync(body, env.Seq) {
+		s.resync = true
+		return
+	}
+
+	s.acked = snapshot
+	s.ackedSeq = env.Seq
+	s.resync = false
+	if env.Mode == "full" {
+		s.lastFull = time.Now()
+	}
+}
+
+// fail forces a full resync when the backend rejected a delta outright.
+func (s *processDeltaState) fail(statusCode int) {
+	if statusCode == 409 || statusCode == 410 {
+		s.mu.Lock()
+		s.resync = true
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,88 @@
+package sender
+
+import (
+	"fmt"
+	"iDevopzAgent/configs"
+	"iDevopzAgent/httpclient"
+	"iDevopzAgent/models"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const (
+	remoteConfigPath  = "/api/go/agent/config"
+	configStatusPath  = "/api/go/agent/config/status"
+	commandResultPath = "/api/go/agent/commands/result"
+)
+
+// FetchRemoteConfig asks the backend for the desired config. version is the
+// last applied version; with wait > 0 the backend may hold the request that
+// many seconds until something newer exists. A nil body means nothing new.
+func FetchRemoteConfig(machineID string, version int64, wait int) ([]byte, error) {
+	url := configs.LoadConfig().APIEndpoint + remoteConfigPath
+
+	query := map[string]string{
+		"machineId": machineID,
+		"version":   strconv.FormatInt(version, 10),
+	}
+	if wait > 0 {
+		query["wait"] = strconv.Itoa(wait)
+	}
+
+	resp, err := httpclient.SendGET(url, query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified:
+		return nil, nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, fmt.Errorf("status %s: %s", resp.Status, string(body))
+	}
+	return body, nil
+}
+
+func SendConfigStatus(status *models.RemoteConfigStatus) {
+
+	url := configs.LoadConfig().APIEndpoint + configStatusPath
+
+	resp, err := httpclient.SendPOST(url, status)
+	if err != nil {
+		log.Error("send config status failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Warn("send config status rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendCommandResult(result *models.CommandResult) {
+
+	url := configs.LoadConfig().APIEndpoint + commandResultPath
+
+	resp, err := httpclient.SendPOST(url, result)
+	if err != nil {
+		log.Error("send command result failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Warn("send command result rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+// ResyncProcessList makes the next process upload a full list.
+func ResyncProcessList() {
+	processDelta.mu.Lock()
+	processDelta.resync = true
+	processDelta.mu.Unlock()
+}
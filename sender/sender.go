@@ -1,87 +1,137 @@
 package sender
 
 import (
-	"fmt"
 	"iDevopzAgent/configs"
 	"iDevopzAgent/httpclient"
+	"iDevopzAgent/internal/logging"
 	"iDevopzAgent/models"
 	"io"
+	"net/http"
 )
 
+var log = logging.For("sender")
+
+// SendStartupAPI registers the agent: the device key and machine ID in
+// payload are exchanged for an agent token that signs every later request.
 func SendStartupAPI(payload map[string]string) {
-	url := configs.LoadConfig().APIEndpoint + "/api/vm/moniters/create-update"
+	url := configs.LoadConfig().APIEndpoint + startupPath
+
+	auth.mu.Lock()
+	auth.machineID = payload["machineId"]
+	auth.startupPayload = payload
+	auth.loadStored()
+	auth.mu.Unlock()
+	httpclient.SetAuthenticator(auth)
 
 	resp, err := httpclient.SendPOST(url, payload)
 	if err != nil {
-		fmt.Println("Error sending startup API:", err)
+		log.Error("send startup API failed", "err", err)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println("Startup API sent successfully:", resp.Status)
+	status := resp.Status
+	auth.mu.Lock()
+	err = auth.storeLocked(resp)
+	auth.mu.Unlock()
+	if err == nil {
+		log.Info("startup API sent", "status", status)
 	} else {
-		fmt.Printf("Startup API failed.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Error("startup API failed", "err", err)
 	}
 }
 
 func SendToMetricsAPI(metrics *models.Metrics) {
 
+	if batch.add("metrics", "/api/go/system/metrics/create", metrics) {
+		return
+	}
+
 	url := configs.LoadConfig().APIEndpoint + "/api/go/system/metrics/create"
 
-	fmt.Println(" Sending payload to:", url)
+	log.Debug("sending metrics", "url", url)
 
 	resp, err := httpclient.SendPOST(url, metrics)
 	if err != nil {
-		fmt.Println(" Error sending data:", err)
+		log.Error("send metrics failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" Metrics sent! Status:", resp.Status)
+		recordAck()
+		log.Debug("metrics sent", "status", resp.Status)
 	} else {
-		fmt.Printf(" Failed to send metrics.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send metrics rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendAlertEvents(events []*models.AlertEvent) {
+
+	if batch.add("alert_events", "/api/go/system/alerts/create", events) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/system/alerts/create"
+
+	resp, err := httpclient.SendPOST(url, events)
+	if err != nil {
+		log.Error("send alert events failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("alert events sent", "status", resp.Status)
+	} else {
+		log.Warn("send alert events rejected", "status", resp.Status, "response", string(body))
 	}
 }
 
 func SendToHealthReportAPI(healthReport *models.HealthReport) {
 
+	if batch.add("health_report", "/api/go/system/health-report", healthReport) {
+		return
+	}
+
 	url := configs.LoadConfig().APIEndpoint + "/api/go/system/health-report"
 
 	resp, err := httpclient.SendPOST(url, healthReport)
 	if err != nil {
-		fmt.Println(" Error sending healthReport:", err)
+		log.Error("send health report failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" healthReport sent! Status:", resp.Status)
+		log.Debug("health report sent", "status", resp.Status)
 	} else {
-		fmt.Printf(" Failed to send healthReport.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send health report rejected", "status", resp.Status, "response", string(body))
 	}
 }
 
 func SendSystemSummaryToAPI(report *models.Systeminfo) {
 
+	if batch.add("system_summary", "/api/go/system/summary", report) {
+		return
+	}
+
 	url := configs.LoadConfig().APIEndpoint + "/api/go/system/summary"
 
 	resp, err := httpclient.SendPOST(url, report)
 	if err != nil {
-		fmt.Println(" Error sending system summary:", err)
+		log.Error("send system summary failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" System summary sent! Status:", resp.Status)
+		log.Debug("system summary sent", "status", resp.Status)
 	} else {
-		fmt.Printf(" Failed to send system summary.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send system summary rejected", "status", resp.Status, "response", string(body))
 	}
 }
 
@@ -108,112 +158,430 @@ func SendSystemSummaryToAPI(report *models.Systeminfo) {
 
 func SendCpuUtilizationToAPI(report *models.CpuUtilization) {
 
+	if batch.add("cpu_utilization", "/api/go/send-cpu-utilization", report) {
+		return
+	}
+
 	url := configs.LoadConfig().APIEndpoint + "/api/go/send-cpu-utilization"
 
 	resp, err := httpclient.SendPOST(url, report)
 	if err != nil {
-		fmt.Println(" Error sending CPU utilization:", err)
+		log.Error("send CPU utilization failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" CPU utilization sent! Status:", resp.Status)
+		log.Debug("CPU utilization sent", "status", resp.Status)
 	} else {
-		fmt.Printf(" Failed to send CPU utilization.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send CPU utilization rejected", "status", resp.Status, "response", string(body))
 	}
 }
 func SendMemmoryUtilizationToAPI(report *models.MemoryUtilization) {
 
+	if batch.add("memory_utilization", "/api/go/send-memory-utilization", report) {
+		return
+	}
+
 	url := configs.LoadConfig().APIEndpoint + "/api/go/send-memory-utilization"
 
 	resp, err := httpclient.SendPOST(url, report)
 	if err != nil {
-		fmt.Println(" Error sending Memory utilization:", err)
+		log.Error("send memory utilization failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" Memory utilization sent! Status:", resp.Status)
+		log.Debug("memory utilization sent", "status", resp.Status)
 	} else {
-		fmt.Printf(" Failed to send Memory utilization.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send memory utilization rejected", "status", resp.Status, "response", string(body))
 	}
 }
 func SendDiskUtilizationToAPI(report *models.DiskUtilization) {
 
+	if batch.add("disk_utilization", "/api/go/send-disk-utilization", report) {
+		return
+	}
+
 	url := configs.LoadConfig().APIEndpoint + "/api/go/send-disk-utilization"
 
 	resp, err := httpclient.SendPOST(url, report)
 	if err != nil {
-		fmt.Println(" Error sending disk utilization:", err)
+		log.Error("send disk utilization failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" Disk utilization sent! Status:", resp.Status)
+		log.Debug("disk utilization sent", "status", resp.Status)
 	} else {
-		fmt.Printf(" Failed to send disk utilization.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send disk utilization rejected", "status", resp.Status, "response", string(body))
 	}
 }
+
+// SendProcessList uploads the process list as a gzip-compressed envelope,
+// sending only the differences against the last acknowledged snapshot.
 func SendProcessList(report []*models.ProcessInfo) {
 
-	url := configs.LoadConfig().APIEndpoint + "/api/go/system/create-processes"
+	url := configs.LoadConfig().APIEndpoint + "/api/go/system/processes/sync"
+
+	envelope, snapshot := processDelta.build(report)
+
+	resp, err := httpclient.SendPOSTGzip(url, envelope)
+	if err != nil {
+		log.Error("send process list failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		processDelta.ack(envelope, snapshot, body)
+		log.Debug("process list sent", "mode", envelope.Mode, "added", len(envelope.Added),
+			"changed", len(envelope.Changed), "removed", len(envelope.Removed), "status", resp.Status)
+	} else {
+		processDelta.fail(resp.StatusCode)
+		log.Warn("send process list rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendProcessGroups(report []*models.ProcessGroup) {
+
+	if batch.add("process_groups", "/api/go/system/processes/groups-create", report) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/system/processes/groups-create"
 
 	resp, err := httpclient.SendPOST(url, report)
-	fmt.Println("response", resp)
 	if err != nil {
-		fmt.Println(" Error sending process list :", err)
+		log.Error("send process groups failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" Process List  Status:", resp.Status)
+		log.Debug("process groups sent", "status", resp.Status)
 	} else {
-		fmt.Printf(" Failed to Process info list .\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send process groups rejected", "status", resp.Status, "response", string(body))
 	}
 }
 
 func Top5Cpu(report []*models.Process) {
 
+	if batch.add("top_cpu", "/api/go/system/processes/topcpu-create", report) {
+		return
+	}
+
 	url := configs.LoadConfig().APIEndpoint + "/api/go/system/processes/topcpu-create"
 
 	resp, err := httpclient.SendPOST(url, report)
 	if err != nil {
-		fmt.Println(" Error sending Top 5 Cpu List :", err)
+		log.Error("send top 5 CPU list failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" Process Top 5 Cpu list:", resp.Status)
+		log.Debug("top 5 CPU list sent", "status", resp.Status)
 	} else {
-		fmt.Printf("Process Top 5 Cpu list.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send top 5 CPU list rejected", "status", resp.Status, "response", string(body))
 	}
 }
 
 func Top5Memory(report []*models.Process) {
 
+	if batch.add("top_memory", "/api/go/system/processes/topmemory-create", report) {
+		return
+	}
+
 	url := configs.LoadConfig().APIEndpoint + "/api/go/system/processes/topmemory-create"
 
 	resp, err := httpclient.SendPOST(url, report)
 	if err != nil {
-		fmt.Println(" Error sending Top 5 Memory List :", err)
+		log.Error("send top 5 memory list failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("top 5 memory list sent", "status", resp.Status)
+	} else {
+		log.Warn("send top 5 memory list rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendHeartbeat(heartbeat *models.AgentHeartbeat) {
+
+	if batch.add("agent_heartbeat", "/api/go/agent/heartbeat", heartbeat) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/agent/heartbeat"
+
+	resp, err := httpclient.SendPOST(url, heartbeat)
+	if err != nil {
+		log.Error("send heartbeat failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		recordAck()
+		log.Debug("heartbeat sent", "status", resp.Status)
+	} else {
+		log.Warn("send heartbeat rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+// SendPackageList uploads the installed packages: the full list the first
+// time, afterwards only what was added or removed since the last
+// acknowledged list. Nothing is sent while the list is unchanged.
+func SendPackageList(userID, machineID, hostname string, pkgs []*models.Package) {
+
+	envelope, snapshot := packageDelta.build(userID, machineID, hostname, pkgs)
+	if envelope == nil {
+		log.Debug("package list unchanged", "count", len(pkgs))
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/system/packages/sync"
+
+	resp, err := httpclient.SendPOSTGzip(url, envelope)
+	if err != nil {
+		log.Error("send package list failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		packageDelta.ack(envelope, snapshot, body)
+		log.Debug("package list sent", "mode", envelope.Mode, "count", envelope.Count,
+			"added", len(envelope.Added), "removed", len(envelope.Removed), "status", resp.Status)
+	} else {
+		packageDelta.fail(resp.StatusCode)
+		log.Warn("send package list rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendSocketInventory(report *models.SocketInventory) {
+
+	if batch.add("sockets", "/api/go/system/sockets", report) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/system/sockets"
+
+	resp, err := httpclient.SendPOST(url, report)
+	if err != nil {
+		log.Error("send socket inventory failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("socket inventory sent", "status", resp.Status, "listeners", len(report.Listeners))
+	} else {
+		log.Warn("send socket inventory rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendListenerEvents(events []*models.ListenerEvent) {
+
+	if batch.add("listener_events", "/api/go/system/sockets/events", events) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/system/sockets/events"
+
+	resp, err := httpclient.SendPOST(url, events)
+	if err != nil {
+		log.Error("send listener events failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("listener events sent", "status", resp.Status, "count", len(events))
+	} else {
+		log.Warn("send listener events rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendLogReport(report *models.LogReport) {
+
+	if batch.add("log_report", "/api/go/logs/report", report) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/logs/report"
+
+	resp, err := httpclient.SendPOST(url, report)
+	if err != nil {
+		log.Error("send log report failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("log report sent", "status", resp.Status)
+	} else {
+		log.Warn("send log report rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendJournalEntries(entries *models.JournalBatch) {
+
+	if batch.add("journal_entries", "/api/go/logs/journal", entries) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/logs/journal"
+
+	resp, err := httpclient.SendPOST(url, entries)
+	if err != nil {
+		log.Error("send journal entries failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("journal entries sent", "status", resp.Status, "entries", len(entries.Entries))
+	} else {
+		log.Warn("send journal entries rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+// SendLogRecords uploads one batch of forwarded log records and reports
+// whether the batch is done with. A batch the backend refuses as malformed
+// or too large is dropped rather than retried forever.
+func SendLogRecords(records *models.LogForwardBatch) bool {
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/logs/forward"
+
+	resp, err := httpclient.SendPOSTGzip(url, records)
+	if err != nil {
+		log.Error("send log records failed", "err", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		log.Debug("log records sent", "status", resp.Status, "records", len(records.Records))
+		return true
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge:
+		log.Warn("send log records rejected, dropping batch", "status", resp.Status,
+			"records", len(records.Records), "response", string(body))
+		return true
+	default:
+		log.Warn("send log records rejected", "status", resp.Status, "response", string(body))
+		return false
+	}
+}
+
+func SendAuthReport(report *models.AuthReport) {
+
+	if batch.add("auth_report", "/api/go/security/auth", report) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/security/auth"
+
+	resp, err := httpclient.SendPOST(url, report)
+	if err != nil {
+		log.Error("send auth report failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("auth report sent", "status", resp.Status)
+	} else {
+		log.Warn("send auth report rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+func SendFileChanges(events []*models.FileChangeEvent) {
+
+	if batch.add("file_changes", "/api/go/security/files/changes", events) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/security/files/changes"
+
+	resp, err := httpclient.SendPOST(url, events)
+	if err != nil {
+		log.Error("send file changes failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("file changes sent", "status", resp.Status, "count", len(events))
+	} else {
+		log.Warn("send file changes rejected", "status", resp.Status, "response", string(body))
+	}
+}
+
+// SendInventory uploads the inventory directly, bypassing the batcher, and
+// reports whether the backend accepted it so the caller can advance its
+// baseline.
+func SendInventory(inventory *models.Inventory) bool {
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/system/inventory"
+
+	resp, err := httpclient.SendPOST(url, inventory)
+	if err != nil {
+		log.Error("send inventory failed", "err", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		log.Debug("inventory sent", "status", resp.Status, "hash", inventory.Hash)
+		return true
+	}
+	log.Warn("send inventory rejected", "status", resp.Status, "response", string(body))
+	return false
+}
+
+func SendInventoryChanges(changes []*models.InventoryChange) {
+
+	if batch.add("inventory_changes", "/api/go/system/inventory/changes", changes) {
+		return
+	}
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/system/inventory/changes"
+
+	resp, err := httpclient.SendPOST(url, changes)
+	if err != nil {
+		log.Error("send inventory changes failed", "err", err)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		fmt.Println(" Process Top 5 Memory list:", resp.Status)
+		log.Debug("inventory changes sent", "status", resp.Status, "count", len(changes))
 	} else {
-		fmt.Printf("Process Top 5 Memory list.\nStatus: %s\nResponse: %s\n", resp.Status, string(body))
+		log.Warn("send inventory changes rejected", "status", resp.Status, "response", string(body))
 	}
 }
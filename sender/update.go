@@ -0,0 +1,25 @@
+package sender
+
+import (
+	"iDevopzAgent/configs"
+	"iDevopzAgent/httpclient"
+	"iDevopzAgent/models"
+	"io"
+)
+
+func SendUpdateStatus(status *models.UpdateStatus) {
+
+	url := configs.LoadConfig().APIEndpoint + "/api/go/agent/update/status"
+
+	resp, err := httpclient.SendPOST(url, status)
+	if err != nil {
+		log.Error("send update status failed", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Warn("send update status rejected", "status", resp.Status, "response", string(body))
+	}
+}